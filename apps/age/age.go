@@ -0,0 +1,211 @@
+package age
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cosmos/btcutil/bech32"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/log"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+//go:generate stringer -type command
+type command byte
+
+const (
+	appName      = "AGE"
+	appID   byte = 0x41
+
+	minDataLen = 5
+
+	// age identities are derived at m/44'/coinType'/<account>'/0/0
+	coinType = 0x616765 // "age"
+
+	recipientHRP = "age"
+
+	// x25519Label is the HKDF info string of the age X25519 recipient type.
+	x25519Label = "age-encryption.org/v1/X25519"
+
+	fileKeySize = 16
+
+	claGetRecipient command = 0x02
+	claUnwrap       command = 0x04
+)
+
+// Age holds age X25519 identities, and unwraps file keys addressed to them.
+type Age struct {
+	// Token is set by apps.Handler.Register, restricted to KeyScope.
+	Token crypto.Token
+
+	// TODO: figure out how to better handle logger instance
+	l *zap.SugaredLogger
+}
+
+func (a *Age) initLog() {
+	if a.l != nil {
+		return
+	}
+
+	a.l = log.Development(
+		zap.Fields(zap.String("app_name", a.Name())),
+	).Sugar()
+}
+
+// Name implements the apps.App interface
+func (a *Age) Name() string {
+	return appName
+}
+
+// ID implements the apps.App interface
+func (a *Age) ID() byte {
+	return appID
+}
+
+// KeyScope implements the apps.KeyUser interface
+func (a *Age) KeyScope() crypto.ScopeID {
+	return crypto.ScopeAge
+}
+
+// SetToken implements the apps.KeyUser interface
+func (a *Age) SetToken(t crypto.Token) {
+	a.Token = t
+}
+
+// Commands implements the apps.App interface
+func (a *Age) Commands() (commandIDs []byte) {
+	ret := []byte{
+		byte(claGetRecipient),
+		byte(claUnwrap),
+	}
+
+	return ret
+}
+
+// Handle implements the apps.App interface
+func (a *Age) Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error) {
+	a.initLog()
+
+	if len(data) < minDataLen {
+		return nil, apps.APDUWrongLength, fmt.Errorf("data is too small to be processed")
+	}
+
+	a.l.Debugw("handling command", "name", command(cmd).String())
+	switch cmd {
+	case byte(claGetRecipient):
+		return a.handleGetRecipient(data)
+	case byte(claUnwrap):
+		return a.handleUnwrap(data)
+	default:
+		return nil, apps.APDUINSNotSupported, fmt.Errorf("command not found")
+	}
+}
+
+func derivationPath(account uint32) crypto.DerivationPath {
+	return crypto.BIP44Path(coinType, account, 0, 0)
+}
+
+// accountFromData reads the little-endian account index found right after the APDU header.
+func accountFromData(data []byte) (uint32, error) {
+	if len(data) < minDataLen+4 {
+		return 0, fmt.Errorf("missing account index")
+	}
+
+	return binary.LittleEndian.Uint32(data[minDataLen : minDataLen+4]), nil
+}
+
+// publicKey returns the X25519 public key of the identity of account.
+func (a *Age) publicKey(account uint32) ([]byte, error) {
+	return a.Token.PublicKey(derivationPath(account), crypto.AlgoX25519)
+}
+
+// recipient returns the age1... encoding of the X25519 public key pubkey.
+func recipient(pubkey []byte) (string, error) {
+	converted, err := bech32.ConvertBits(pubkey, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.Encode(recipientHRP, converted)
+}
+
+func (a *Age) handleGetRecipient(data []byte) (response []byte, code apps.APDUCode, err error) {
+	account, err := accountFromData(data)
+	if err != nil {
+		return nil, apps.APDUWrongLength, err
+	}
+
+	pubkey, err := a.publicKey(account)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	encoded, err := recipient(pubkey)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	a.l.Debugw("should display on device", "value", data[2] == 0x01)
+	a.l.Debugw("recipient generation complete", "recipient", encoded)
+
+	r := &bytes.Buffer{}
+	r.Write(pubkey)
+	r.WriteString(encoded)
+
+	return r.Bytes(), apps.APDUSuccess, nil
+}
+
+// handleUnwrap decrypts the file key found in the body of an age X25519 recipient stanza.
+// Data holds account index || ephemeral share || stanza body.
+func (a *Age) handleUnwrap(data []byte) (response []byte, code apps.APDUCode, err error) {
+	account, err := accountFromData(data)
+	if err != nil {
+		return nil, apps.APDUWrongLength, err
+	}
+
+	payload := data[minDataLen+4:]
+	if len(payload) != curve25519.PointSize+fileKeySize+chacha20poly1305.Overhead {
+		return nil, apps.APDUWrongLength, fmt.Errorf("wrong unwrap payload length %v", len(payload))
+	}
+
+	share := payload[:curve25519.PointSize]
+	body := payload[curve25519.PointSize:]
+
+	pubkey, err := a.publicKey(account)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	// X25519 returns an error on low order points, which yield an all-zero shared secret.
+	// The wrapping key is derived on the Token, so that the shared secret never leaves it.
+	wrappingKey, err := a.Token.ECDH(derivationPath(account), crypto.AlgoX25519, crypto.KDF{
+		Algorithm: crypto.KDFHKDFSHA256,
+		Salt:      append(append([]byte{}, share...), pubkey...),
+		Info:      []byte(x25519Label),
+		Length:    chacha20poly1305.KeySize,
+	}, share)
+	if err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	defer crypto.Wipe(wrappingKey)
+
+	aead, err := chacha20poly1305.New(wrappingKey)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), body, nil)
+	if err != nil {
+		// the stanza is addressed to some other recipient
+		return nil, apps.APDUDataInvalid, fmt.Errorf("cannot unwrap file key, %w", err)
+	}
+
+	a.l.Infow("unwrapped file key", "account", account)
+
+	return fileKey, apps.APDUSuccess, nil
+}
@@ -0,0 +1,107 @@
+package age
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/appstest"
+)
+
+// accountData returns the data of a command on account.
+func accountData(account uint32, payload []byte) []byte {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, account)
+
+	return append(data, payload...)
+}
+
+func newTestAge(t *testing.T) *Age {
+	t.Helper()
+
+	a := &Age{}
+	appstest.SetToken(a, appstest.Token(t))
+
+	return a
+}
+
+// deviceIdentity is an age.Identity unwrapping X25519 stanzas through the app.
+type deviceIdentity struct {
+	a       *Age
+	account uint32
+}
+
+func (d deviceIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != "X25519" || len(s.Args) != 1 {
+			continue
+		}
+
+		share, err := base64.RawStdEncoding.DecodeString(s.Args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		fileKey, code, err := appstest.Handle(d.a, byte(claUnwrap), 0x00, 0x00, accountData(d.account, append(share, s.Body...)))
+		if code == apps.APDUDataInvalid {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return fileKey, nil
+	}
+
+	return nil, age.ErrIncorrectIdentity
+}
+
+func TestUnwrapAgeFile(t *testing.T) {
+	a := newTestAge(t)
+
+	response, code, err := appstest.Handle(a, byte(claGetRecipient), 0x00, 0x00, accountData(0, nil))
+	require.NoError(t, err)
+	require.Equal(t, apps.APDUSuccess, code)
+
+	recipient, err := age.ParseX25519Recipient(string(response[32:]))
+	require.NoError(t, err)
+
+	// a file for the device and another recipient
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	file := &bytes.Buffer{}
+	w, err := age.Encrypt(file, other.Recipient(), recipient)
+	require.NoError(t, err)
+
+	_, err = fmt.Fprint(w, "age file content")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := age.Decrypt(bytes.NewReader(file.Bytes()), deviceIdentity{a: a})
+	require.NoError(t, err)
+
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "age file content", string(content))
+
+	// the identity of another account can't unwrap it
+	_, err = age.Decrypt(bytes.NewReader(file.Bytes()), deviceIdentity{a: a, account: 1})
+	require.Error(t, err)
+}
+
+func TestUnwrapLowOrderShare(t *testing.T) {
+	a := newTestAge(t)
+
+	payload := make([]byte, 32+fileKeySize+16)
+	_, code, err := appstest.Handle(a, byte(claUnwrap), 0x00, 0x00, accountData(0, payload))
+	require.Error(t, err)
+	require.Equal(t, apps.APDUDataInvalid, code)
+}
@@ -14,4 +14,7 @@ const (
 	APDUSuccess              APDUCode = 0x9000 // Success
 	APDUWrongLength          APDUCode = 0x6700 // Wrong length
 	APDUDataInvalid          APDUCode = 0x6984 // Data invalid
+	APDUFileNotFound         APDUCode = 0x6A82 // File not found
+	APDUDeviceLocked         APDUCode = 0x5515 // Device locked
+	APDUWrongPIN             APDUCode = 0x63C0 // Wrong PIN, the low nibble holds the remaining retries
 )
@@ -18,43 +18,32 @@ func _() {
 	_ = x[APDUSuccess-36864]
 	_ = x[APDUWrongLength-26368]
 	_ = x[APDUDataInvalid-27012]
+	_ = x[APDUFileNotFound-27266]
+	_ = x[APDUDeviceLocked-21781]
+	_ = x[APDUWrongPIN-25536]
 }
 
-const (
-	_APDUCode_name_0 = "APDUExecutionError"
-	_APDUCode_name_1 = "APDUWrongLength"
-	_APDUCode_name_2 = "APDUEmptyBufferAPDUOutputBufferTooSmallAPDUDataInvalid"
-	_APDUCode_name_3 = "APDUCommandNotAllowed"
-	_APDUCode_name_4 = "APDUINSNotSupported"
-	_APDUCode_name_5 = "APDUCLANotSupported"
-	_APDUCode_name_6 = "APDUUnknown"
-	_APDUCode_name_7 = "APDUSuccess"
-)
+const _APDUCode_name = "APDUDeviceLockedAPDUWrongPINAPDUExecutionErrorAPDUWrongLengthAPDUEmptyBufferAPDUOutputBufferTooSmallAPDUDataInvalidAPDUCommandNotAllowedAPDUFileNotFoundAPDUINSNotSupportedAPDUCLANotSupportedAPDUUnknownAPDUSuccess"
 
-var (
-	_APDUCode_index_2 = [...]uint8{0, 15, 39, 54}
-)
+var _APDUCode_map = map[APDUCode]string{
+	21781: _APDUCode_name[0:16],
+	25536: _APDUCode_name[16:28],
+	25600: _APDUCode_name[28:46],
+	26368: _APDUCode_name[46:61],
+	27010: _APDUCode_name[61:76],
+	27011: _APDUCode_name[76:100],
+	27012: _APDUCode_name[100:115],
+	27014: _APDUCode_name[115:136],
+	27266: _APDUCode_name[136:152],
+	27904: _APDUCode_name[152:171],
+	28160: _APDUCode_name[171:190],
+	28416: _APDUCode_name[190:201],
+	36864: _APDUCode_name[201:212],
+}
 
 func (i APDUCode) String() string {
-	switch {
-	case i == 25600:
-		return _APDUCode_name_0
-	case i == 26368:
-		return _APDUCode_name_1
-	case 27010 <= i && i <= 27012:
-		i -= 27010
-		return _APDUCode_name_2[_APDUCode_index_2[i]:_APDUCode_index_2[i+1]]
-	case i == 27014:
-		return _APDUCode_name_3
-	case i == 27904:
-		return _APDUCode_name_4
-	case i == 28160:
-		return _APDUCode_name_5
-	case i == 28416:
-		return _APDUCode_name_6
-	case i == 36864:
-		return _APDUCode_name_7
-	default:
-		return "APDUCode(" + strconv.FormatInt(int64(i), 10) + ")"
+	if str, ok := _APDUCode_map[i]; ok {
+		return str
 	}
+	return "APDUCode(" + strconv.FormatInt(int64(i), 10) + ")"
 }
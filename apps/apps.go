@@ -7,6 +7,7 @@ import (
 	"io"
 
 	"github.com/hsanjuan/go-nfctype4/apdu"
+	"github.com/wallera-computer/wallera/crypto"
 )
 
 // App represents an application, in charge of handling a given AppID and a set of commands.
@@ -22,22 +23,66 @@ type App interface {
 	Handle(command byte, data []byte) (response []byte, code APDUCode, err error)
 }
 
+// KeyUser is implemented by Apps which use keys of a crypto.Token.
+// Apps never get the Token itself: KeyScope names the fixed key domain of the App, and Handler.Register
+// hands it a handle restricted to that domain through SetToken, so that an App can't use the keys of
+// another one.
+type KeyUser interface {
+	KeyScope() crypto.ScopeID
+	SetToken(t crypto.Token)
+}
+
 type commandMapping struct {
 	appID   byte
 	command byte
 }
 
+// Lock tells whether the device has been unlocked by its user.
+type Lock interface {
+	Unlocked() bool
+}
+
 // Handler keeps track of all the supported apps, and their commands.
 type Handler struct {
 	appMap        map[byte]App
 	commandAppMap map[commandMapping]struct{}
+
+	lock       Lock
+	lockExempt map[byte]struct{}
+
+	token crypto.Token
 }
 
-func NewHandler() *Handler {
+// NewHandler returns a Handler which hands scoped handles of t to the KeyUser apps registered into it.
+func NewHandler(t crypto.Token) *Handler {
 	return &Handler{
+		token:         t,
 		appMap:        map[byte]App{},
 		commandAppMap: map[commandMapping]struct{}{},
+		lockExempt:    map[byte]struct{}{},
+	}
+}
+
+// Protect makes h refuse every command while l is locked, except the ones
+// handled by the exempt apps, which must enforce l on their own.
+func (h *Handler) Protect(l Lock, exempt ...App) {
+	h.lock = l
+
+	for _, app := range exempt {
+		h.lockExempt[app.ID()] = struct{}{}
+	}
+}
+
+func (h Handler) locked(appID byte) bool {
+	if h.lock == nil {
+		return false
+	}
+
+	if _, exempt := h.lockExempt[appID]; exempt {
+		return false
 	}
+
+	return !h.lock.Unlocked()
 }
 
 func (h Handler) mappingExists(appID byte) bool {
@@ -54,7 +99,8 @@ func (h Handler) commandAppMappingExists(appID, command byte) bool {
 	return exists
 }
 
-// Register registers apps into h.
+// Register registers apps into h, handing each KeyUser, including the applets of a Selector,
+// a Token restricted to the scope it declares.
 // If an app was already registered, an error will be returned.
 func (h *Handler) Register(apps ...App) error {
 	for _, app := range apps {
@@ -65,6 +111,10 @@ func (h *Handler) Register(apps ...App) error {
 			return fmt.Errorf("mapping for %s already exists", app.Name())
 		}
 
+		for _, ku := range keyUsers(app) {
+			ku.SetToken(crypto.NewScopedToken(h.token, ku.KeyScope()))
+		}
+
 		h.appMap[appID] = app
 
 		for _, cmd := range cmds {
@@ -76,7 +126,27 @@ func (h *Handler) Register(apps ...App) error {
 	}
 
 	return nil
+}
+
+// keyUsers returns the KeyUsers app is made of: app itself, or the applets of a Selector.
+func keyUsers(app App) []KeyUser {
+	ret := []KeyUser{}
+
+	candidates := []App{app}
+	if s, ok := app.(*Selector); ok {
+		candidates = nil
+		for _, a := range s.applets {
+			candidates = append(candidates, a)
+		}
+	}
 
+	for _, c := range candidates {
+		if ku, ok := c.(KeyUser); ok {
+			ret = append(ret, ku)
+		}
+	}
+
+	return ret
 }
 
 // unmarshalCAPDU returns a command APDU packet from data.
@@ -112,6 +182,11 @@ func (h *Handler) Handle(data []byte) ([]byte, error) {
 			fmt.Errorf("command ID %v not supported in app %v", command, appID)
 	}
 
+	if h.locked(appID) {
+		return PackageResponse(nil, APDUDeviceLocked),
+			fmt.Errorf("device is locked, refusing command ID %v of app %v", command, appID)
+	}
+
 	app := h.appMap[appID]
 
 	respData, respCode, err := app.Handle(command, data)
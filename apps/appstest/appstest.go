@@ -0,0 +1,63 @@
+// Package appstest implements utilities for testing apps.
+package appstest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// Handler handles command APDUs, like apps do.
+type Handler interface {
+	Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error)
+}
+
+// Mnemonic seeds the tokens returned by Token.
+const Mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// Token returns a Token seeded with Mnemonic, for the apps under test.
+func Token(t *testing.T) crypto.DeviceToken {
+	t.Helper()
+
+	return TokenFromMnemonic(t, Mnemonic)
+}
+
+// TokenFromMnemonic returns a Token seeded with mnemonic, for apps tested against vectors of their own.
+func TokenFromMnemonic(t *testing.T, mnemonic string) crypto.DeviceToken {
+	t.Helper()
+
+	token := crypto.NewDumbToken(storage.NewMemory())
+	require.NoError(t, token.ImportSeed(strings.Fields(mnemonic)))
+
+	return token
+}
+
+// SetToken hands token to ku, restricted to its KeyScope like apps.Handler.Register does.
+func SetToken(ku apps.KeyUser, token crypto.Token) {
+	ku.SetToken(crypto.NewScopedToken(token, ku.KeyScope()))
+}
+
+// APDU returns a short command APDU, whose data is preceded by its length.
+func APDU(ins, p1, p2 byte, data []byte) []byte {
+	return append([]byte{0x00, ins, p1, p2, byte(len(data))}, data...)
+}
+
+// Handle sends a command APDU to h.
+func Handle(h Handler, ins, p1, p2 byte, data []byte) ([]byte, apps.APDUCode, error) {
+	return h.Handle(ins, APDU(ins, p1, p2, data))
+}
+
+// Run sends a command APDU to h, and fails the test unless it succeeds.
+func Run(t *testing.T, h Handler, ins, p1, p2 byte, data []byte) []byte {
+	t.Helper()
+
+	response, code, err := Handle(h, ins, p1, p2, data)
+	require.NoError(t, err)
+	require.Equal(t, apps.APDUSuccess, code)
+
+	return response
+}
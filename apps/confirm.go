@@ -0,0 +1,33 @@
+package apps
+
+// Confirmer asks the user to approve an operation on the device, describing it with prompt.
+// It returns true only if the user explicitly approved it.
+type Confirmer interface {
+	Confirm(prompt string) (bool, error)
+}
+
+// ConfirmFunc adapts a function to the Confirmer interface.
+type ConfirmFunc func(prompt string) (bool, error)
+
+// Confirm implements the Confirmer interface.
+func (f ConfirmFunc) Confirm(prompt string) (bool, error) {
+	return f(prompt)
+}
+
+// SecretConfirmer shows a secret, like a mnemonic share or a password, to the user on the device,
+// along with prompt, and asks them to confirm it has been backed up.
+// The secret is ASCII text, mnemonics being made of words separated by spaces, held in a buffer the
+// caller wipes once done: implementations must not keep copies of it, nor turn it into a string.
+// It's the only way secrets leave the device: they're never sent back in responses.
+// It returns true only if the user explicitly confirmed it.
+type SecretConfirmer interface {
+	ConfirmSecret(prompt string, secret []byte) (bool, error)
+}
+
+// SecretConfirmFunc adapts a function to the SecretConfirmer interface.
+type SecretConfirmFunc func(prompt string, secret []byte) (bool, error)
+
+// ConfirmSecret implements the SecretConfirmer interface.
+func (f SecretConfirmFunc) ConfirmSecret(prompt string, secret []byte) (bool, error) {
+	return f(prompt, secret)
+}
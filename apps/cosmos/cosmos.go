@@ -26,6 +26,9 @@ const (
 
 	minDataLen = 5
 
+	// keys are derived at m/44'/coinType'/..., like the Ledger Cosmos app does
+	coinType = 118
+
 	claGetVersion       command = 0x00
 	claSignSecp256K1    command = 0x02
 	claGetAddrSecp256K1 command = 0x04
@@ -42,6 +45,7 @@ const (
 
 // Cosmos handles Cosmos SDK commands.
 type Cosmos struct {
+	// Token is set by apps.Handler.Register, restricted to KeyScope.
 	Token                   crypto.Token
 	currentSignatureSession *signatureSession
 
@@ -69,6 +73,16 @@ func (c *Cosmos) ID() byte {
 	return appID
 }
 
+// KeyScope implements the apps.KeyUser interface
+func (c *Cosmos) KeyScope() crypto.ScopeID {
+	return crypto.ScopeCosmos
+}
+
+// SetToken implements the apps.KeyUser interface
+func (c *Cosmos) SetToken(t crypto.Token) {
+	c.Token = t
+}
+
 // Commands implements the apps.App interface
 func (c *Cosmos) Commands() (commandIDs []byte) {
 	ret := []byte{
@@ -139,6 +153,23 @@ type signatureSession struct {
 	data           *bytes.Buffer
 }
 
+// wipe zeroes the data received so far.
+// The buffers data outgrew while receiving it have been left to the garbage collector.
+func (s *signatureSession) wipe() {
+	b := s.data.Bytes()
+	crypto.Wipe(b[:cap(b)])
+	s.data.Reset()
+}
+
+// endSignatureSession wipes and closes the signature session.
+func (c *Cosmos) endSignatureSession() {
+	if c.currentSignatureSession != nil {
+		c.currentSignatureSession.wipe()
+	}
+
+	c.currentSignatureSession = nil
+}
+
 func (c *Cosmos) handleSignSecp256K1(data []byte) (response []byte, code apps.APDUCode, err error) {
 	// TODO: check validity of signature payload
 	// https://github.com/LedgerHQ/app-cosmos/blob/master/docs/TXSPEC.md
@@ -150,6 +181,7 @@ func (c *Cosmos) handleSignSecp256K1(data []byte) (response []byte, code apps.AP
 	}
 
 	if payloadDescription == signInit {
+		c.endSignatureSession()
 		c.currentSignatureSession = &signatureSession{
 			data: &bytes.Buffer{},
 		}
@@ -158,13 +190,13 @@ func (c *Cosmos) handleSignSecp256K1(data []byte) (response []byte, code apps.AP
 	data = data[5:]
 	switch payloadDescription {
 	case signInit:
-		c.currentSignatureSession.derivationPath = crypto.NewDerivationPathFromBytes(
-			data[0:4],
-			data[4:8],
-			data[8:12],
-			data[12:16],
-			data[16:20],
-		)
+		dp, err := ledgerDerivationPath(data)
+		if err != nil {
+			c.endSignatureSession()
+			return nil, apps.APDUDataInvalid, err
+		}
+
+		c.currentSignatureSession.derivationPath = dp
 
 		c.l.Debugw("read derivation path in sign init", "derivation path", c.currentSignatureSession.derivationPath.String())
 	case signAdd, signLast:
@@ -177,16 +209,7 @@ func (c *Cosmos) handleSignSecp256K1(data []byte) (response []byte, code apps.AP
 		return nil, apps.APDUSuccess, nil
 	}
 
-	defer func(c *Cosmos) {
-		c.currentSignatureSession = nil
-	}(c)
-
-	// we need to clone the Token instance because the derivation path passed as argument in signInit
-	// might differ from the one we used to initialize the token before.
-	sessionToken := c.Token.Clone()
-	if err := sessionToken.Initialize(c.currentSignatureSession.derivationPath); err != nil {
-		return nil, apps.APDUExecutionError, err
-	}
+	defer c.endSignatureSession()
 
 	// len(sigBytes) will be always 10 bytes less than the session data as a whole,
 	// because we're trimming the APDU header for signAdd and signLast.
@@ -197,8 +220,7 @@ func (c *Cosmos) handleSignSecp256K1(data []byte) (response []byte, code apps.AP
 		return nil, apps.APDUDataInvalid, fmt.Errorf("provided signature data isn't JSON")
 	}
 
-	sbHash := sha256.Sum256(sigBytes)
-	resp, err := sessionToken.Sign(sbHash[:], crypto.AlgoSecp256K1)
+	resp, err := c.Token.SignMessage(c.currentSignatureSession.derivationPath, crypto.AlgoSecp256K1, crypto.HashSHA256, sigBytes)
 	if err != nil {
 		return nil, apps.APDUExecutionError, err
 	}
@@ -246,17 +268,34 @@ func hrpFromGetAddressRequest(r getAddressRequest, data []byte) string {
 	return string(data[6 : 6+r.HRPLength])
 }
 
-func derivationPathFromGetAddressRequest(r getAddressRequest, data []byte) crypto.DerivationPath {
-	offset := 6 + r.HRPLength
-	base := data[offset : offset+20]
-
-	return crypto.NewDerivationPathFromBytes(
-		base[0:4],
-		base[4:8],
-		base[8:12],
-		base[12:16],
-		base[16:20],
-	)
+func derivationPathFromGetAddressRequest(r getAddressRequest, data []byte) (crypto.DerivationPath, error) {
+	offset := 6 + int(r.HRPLength)
+	if len(data) < offset {
+		return nil, fmt.Errorf("get address request is truncated")
+	}
+
+	return ledgerDerivationPath(data[offset:])
+}
+
+// ledgerDerivationPath reads the path found at the beginning of data, as encoded by the Ledger Cosmos app:
+// five little-endian uint32 components, the first three of them being hardened.
+func ledgerDerivationPath(data []byte) (crypto.DerivationPath, error) {
+	const depth = 5
+
+	if len(data) < depth*4 {
+		return nil, fmt.Errorf("derivation path is truncated")
+	}
+
+	ret := make(crypto.DerivationPath, depth)
+	for i := range ret {
+		ret[i] = binary.LittleEndian.Uint32(data[i*4:])
+
+		if i < 3 && ret[i] < crypto.HardenedKeyStart {
+			return nil, fmt.Errorf("derivation path %v must have its first three components hardened", ret[:i+1])
+		}
+	}
+
+	return ret, nil
 }
 
 func displayAddrOnDevice(r getAddressRequest) bool {
@@ -278,16 +317,14 @@ func (c *Cosmos) handleGetAddrSecp256K1(data []byte) (response []byte, code apps
 	hrp := hrpFromGetAddressRequest(req, data)
 	c.l.Debugw("request hrp", "value", string(hrp))
 
-	dp := derivationPathFromGetAddressRequest(req, data)
-	c.l.Debugw("derivation path", "value", dp.String())
-
-	sessionToken := c.Token.Clone()
-
-	if err := sessionToken.Initialize(dp); err != nil {
-		return nil, apps.APDUExecutionError, err
+	dp, err := derivationPathFromGetAddressRequest(req, data)
+	if err != nil {
+		return nil, apps.APDUDataInvalid, err
 	}
 
-	pubkey, err := sessionToken.PublicKey()
+	c.l.Debugw("derivation path", "value", dp.String())
+
+	pubkey, err := c.Token.PublicKey(dp, crypto.AlgoSecp256K1)
 	if err != nil {
 		return nil, apps.APDUExecutionError, err
 	}
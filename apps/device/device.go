@@ -0,0 +1,1140 @@
+package device
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/pin"
+	"go.uber.org/zap"
+)
+
+//go:generate stringer -type command
+type command byte
+
+const (
+	appName      = "DEVICE"
+	appID   byte = 0xE0
+
+	minDataLen = 5
+
+	claGetStatus      command = 0x02
+	claGenerateSeed   command = 0x04
+	claImportMnemonic command = 0x06
+	claSetPassphrase  command = 0x08
+	claSetPIN         command = 0x0A
+	claSetDuressPIN   command = 0x0C
+	claVerifyPIN      command = 0x0E
+	claChangePIN      command = 0x10
+	claExportAccount  command = 0x12
+	claSLIP39Backup   command = 0x14
+	claImportSLIP39   command = 0x16
+	claDeriveBIP85    command = 0x18
+	claListProfiles   command = 0x1A
+	claSelectProfile  command = 0x1C
+	claDeleteProfile  command = 0x1E
+
+	claGetAttestation      command = 0x20
+	claAttestationCSR      command = 0x22
+	claSetAttestationChain command = 0x24
+)
+
+// GET_STATUS flags.
+const (
+	statusSeeded   byte = 1 << 0
+	statusPINSet   byte = 1 << 1
+	statusUnlocked byte = 1 << 2
+)
+
+//go:generate stringer -type importStep
+type importStep byte
+
+// IMPORT_MNEMONIC and IMPORT_SLIP39 steps, as found in P1.
+const (
+	importBegin  importStep = 0
+	importWord   importStep = 1
+	importFinish importStep = 2
+)
+
+// EXPORT_ACCOUNT formats, as found in P1.
+const (
+	exportXPub        byte = 0x00
+	exportDescriptors byte = 0x01
+)
+
+// DERIVE_BIP85 applications, as found in P1.
+var bip85Applications = map[byte]crypto.BIP85Application{
+	0x00: crypto.BIP85Mnemonic,
+	0x01: crypto.BIP85Hex,
+	0x02: crypto.BIP85WIF,
+	0x03: crypto.BIP85Password,
+}
+
+// SET_ATTESTATION_CHAIN chunks: every chunk but the last one holds maxChainChunkSize bytes, and P2
+// flags the last one.
+const (
+	maxChainChunkSize = 255
+
+	chainMoreChunks byte = 0x00
+	chainLastChunk  byte = 0x01
+)
+
+// Seed entropy sizes, as found in GENERATE_SEED P1.
+const (
+	entropy128 byte = 0x00
+	entropy256 byte = 0x01
+)
+
+// importSession holds the mnemonic words received so far while restoring a seed.
+// Words are references to the BIP-39 wordlist, never copies of the APDU payloads.
+type importSession struct {
+	wordCount int
+	words     []string
+}
+
+// maxImportShares bounds the amount of SLIP-39 shares an import session holds: 16 shares of 16 groups.
+const maxImportShares = 16 * 16
+
+// sharesImportSession holds the SLIP-39 shares received so far while restoring a seed.
+// Like importSession, words are references to the SLIP-39 wordlist.
+type sharesImportSession struct {
+	shareWords int
+	shares     [][]string
+	current    []string
+}
+
+// Device handles device onboarding and management.
+type Device struct {
+	Token crypto.DeviceToken
+	PIN   *pin.Manager
+
+	// Confirm asks the user to approve exporting account keys, deleting profiles and replacing seeds.
+	// When nil, those are refused.
+	Confirm apps.Confirmer
+
+	// Reveal shows secrets, like SLIP-39 shares, to the user.
+	// When nil, backups are refused.
+	Reveal apps.SecretConfirmer
+
+	// Revision is the firmware revision attestations sign, unless Token is a crypto.MeasuredAttester.
+	Revision string
+
+	currentImportSession       *importSession
+	currentSharesImportSession *sharesImportSession
+
+	// attestationChain holds the chunks of the attestation chain received so far while provisioning.
+	attestationChain []byte
+
+	// TODO: figure out how to better handle logger instance
+	l *zap.SugaredLogger
+}
+
+func (d *Device) initLog() {
+	if d.l != nil {
+		return
+	}
+
+	d.l = log.Development(
+		zap.Fields(zap.String("app_name", d.Name())),
+	).Sugar()
+}
+
+// Name implements the apps.App interface
+func (d *Device) Name() string {
+	return appName
+}
+
+// ID implements the apps.App interface
+func (d *Device) ID() byte {
+	return appID
+}
+
+// Commands implements the apps.App interface
+func (d *Device) Commands() (commandIDs []byte) {
+	ret := []byte{
+		byte(claGetStatus),
+		byte(claGenerateSeed),
+		byte(claImportMnemonic),
+		byte(claSetPassphrase),
+		byte(claSetPIN),
+		byte(claSetDuressPIN),
+		byte(claVerifyPIN),
+		byte(claChangePIN),
+		byte(claExportAccount),
+		byte(claSLIP39Backup),
+		byte(claImportSLIP39),
+		byte(claDeriveBIP85),
+		byte(claListProfiles),
+		byte(claSelectProfile),
+		byte(claDeleteProfile),
+		byte(claGetAttestation),
+		byte(claAttestationCSR),
+		byte(claSetAttestationChain),
+	}
+
+	return ret
+}
+
+// Handle implements the apps.App interface
+func (d *Device) Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error) {
+	d.initLog()
+
+	if len(data) < minDataLen {
+		return nil, apps.APDUWrongLength, fmt.Errorf("data is too small to be processed")
+	}
+
+	d.l.Debugw("handling command", "name", command(cmd).String())
+
+	if requiresUnlock(command(cmd)) {
+		accessible, err := d.accessible()
+		if err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		if !accessible {
+			return nil, apps.APDUDeviceLocked, fmt.Errorf("device is locked")
+		}
+	}
+
+	switch cmd {
+	case byte(claGetStatus):
+		return d.handleGetStatus()
+	case byte(claGenerateSeed):
+		return d.handleGenerateSeed(data)
+	case byte(claImportMnemonic):
+		return d.handleImportMnemonic(data)
+	case byte(claSetPassphrase):
+		return d.handleSetPassphrase(data)
+	case byte(claSetPIN):
+		return d.handleSetPIN(data)
+	case byte(claSetDuressPIN):
+		return d.handleSetDuressPIN(data)
+	case byte(claVerifyPIN):
+		return d.handleVerifyPIN(data)
+	case byte(claChangePIN):
+		return d.handleChangePIN(data)
+	case byte(claExportAccount):
+		return d.handleExportAccount(data)
+	case byte(claSLIP39Backup):
+		return d.handleSLIP39Backup(data)
+	case byte(claImportSLIP39):
+		return d.handleImportSLIP39(data)
+	case byte(claDeriveBIP85):
+		return d.handleDeriveBIP85(data)
+	case byte(claListProfiles):
+		return d.handleListProfiles()
+	case byte(claSelectProfile):
+		return d.handleSelectProfile(data)
+	case byte(claDeleteProfile):
+		return d.handleDeleteProfile(data)
+	case byte(claGetAttestation):
+		return d.handleGetAttestation(data)
+	case byte(claAttestationCSR):
+		return d.handleAttestationCSR()
+	case byte(claSetAttestationChain):
+		return d.handleSetAttestationChain(data)
+	default:
+		return nil, apps.APDUINSNotSupported, fmt.Errorf("command not found")
+	}
+}
+
+// requiresUnlock returns true if cmd can only be run once the device has been unlocked, if it has a PIN.
+// This app is exempt from the apps.Handler lock, since it's the one unlocking the device.
+func requiresUnlock(cmd command) bool {
+	switch cmd {
+	case claGenerateSeed, claImportMnemonic, claSetPassphrase, claSetDuressPIN, claExportAccount,
+		claSLIP39Backup, claImportSLIP39, claDeriveBIP85, claListProfiles, claSelectProfile, claDeleteProfile,
+		claAttestationCSR, claSetAttestationChain:
+		return true
+	default:
+		return false
+	}
+}
+
+// accessible returns true if the device has neither a PIN nor a seed yet, or has been unlocked.
+func (d *Device) accessible() (bool, error) {
+	if d.PIN.Unlocked() {
+		return true, nil
+	}
+
+	found, err := d.PIN.IsSet()
+	if err != nil || found {
+		return false, err
+	}
+
+	// seeds are only set up once a PIN protects them: a device holding seeds without a PIN had its
+	// PIN state removed from the storage, and stays locked rather than falling open
+	seeded, err := d.Token.HasAnySeed()
+	if err != nil {
+		return false, err
+	}
+
+	return !seeded, nil
+}
+
+// handleGetStatus returns a byte holding the status flags.
+// Set up devices which are accessible follow it with the 4 bytes fingerprint of the active wallet.
+func (d *Device) handleGetStatus() (response []byte, code apps.APDUCode, err error) {
+	var status byte
+
+	pinSet, err := d.PIN.IsSet()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if pinSet {
+		status |= statusPINSet
+	}
+
+	if d.PIN.Unlocked() {
+		status |= statusUnlocked
+	}
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return []byte{status}, apps.APDUSuccess, nil
+	}
+
+	status |= statusSeeded
+
+	if !d.PIN.Unlocked() {
+		return []byte{status}, apps.APDUSuccess, nil
+	}
+
+	fp, err := d.Token.Fingerprint()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return append([]byte{status}, fp...), apps.APDUSuccess, nil
+}
+
+// handleGenerateSeed sets the device up with a new seed, whose mnemonic is revealed to the user through
+// Reveal: the seed is only stored once the user confirmed the mnemonic has been written down, so that
+// no wallet is ever created without a backup.
+func (d *Device) handleGenerateSeed(data []byte) (response []byte, code apps.APDUCode, err error) {
+	var entropyBits int
+	switch data[2] {
+	case entropy128:
+		entropyBits = 128
+	case entropy256:
+		entropyBits = 256
+	default:
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown entropy size %X", data[2])
+	}
+
+	if d.Reveal == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to reveal the mnemonic to the user")
+	}
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device has already been set up")
+	}
+
+	entropy, err := d.Token.RandomBytes(uint64(entropyBits / 8))
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	defer crypto.Wipe(entropy)
+
+	indexes, err := crypto.MnemonicIndexes(entropy)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	defer crypto.WipeIndexes(indexes)
+
+	words, err := crypto.MnemonicWords(indexes)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	defer crypto.WipeWords(words)
+
+	mnemonic := crypto.JoinWords(words)
+	defer crypto.Wipe(mnemonic)
+
+	confirmed, err := d.Reveal.ConfirmSecret(fmt.Sprintf("Mnemonic of the new wallet, %v words. Written down?", len(words)), mnemonic)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !confirmed {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("mnemonic backup aborted by the user, seed discarded")
+	}
+
+	if err := d.Token.ImportSeed(words); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	d.l.Infow("device set up with a new seed", "entropy_bits", entropyBits, "mnemonic_words", len(words))
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleImportMnemonic restores a seed from a BIP-39 mnemonic, sent one word per command:
+//   - importBegin opens a session for P2 words, either 12, 18 or 24
+//   - importWord carries a single word, which is checked against the wordlist before being accepted
+//   - importFinish verifies the mnemonic checksum, and replaces the device seed
+//
+// importWord responds with the amount of words received so far.
+func (d *Device) handleImportMnemonic(data []byte) (response []byte, code apps.APDUCode, err error) {
+	step := importStep(data[2])
+	d.l.Debugw("import mnemonic", "step", step.String())
+
+	if d.currentImportSession == nil && step != importBegin {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("wrong import step with no session initialized, %v", step.String())
+	}
+
+	switch step {
+	case importBegin:
+		wordCount := int(data[3])
+		if !crypto.ValidMnemonicLength(wordCount) {
+			return nil, apps.APDUDataInvalid, fmt.Errorf("unsupported mnemonic length %v", wordCount)
+		}
+
+		d.endImportSession()
+		d.currentImportSession = &importSession{
+			wordCount: wordCount,
+			words:     make([]string, 0, wordCount),
+		}
+
+		return nil, apps.APDUSuccess, nil
+	case importWord:
+		if len(d.currentImportSession.words) == d.currentImportSession.wordCount {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf("all %v words have already been received", d.currentImportSession.wordCount)
+		}
+
+		word, found := crypto.MnemonicWord(data[minDataLen:])
+		crypto.Wipe(data[minDataLen:])
+
+		if !found {
+			return nil, apps.APDUDataInvalid, fmt.Errorf("word %v is not in the BIP-39 wordlist", len(d.currentImportSession.words)+1)
+		}
+
+		d.currentImportSession.words = append(d.currentImportSession.words, word)
+
+		return []byte{byte(len(d.currentImportSession.words))}, apps.APDUSuccess, nil
+	case importFinish:
+		defer d.endImportSession()
+
+		if len(d.currentImportSession.words) != d.currentImportSession.wordCount {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf(
+				"received %v words out of %v", len(d.currentImportSession.words), d.currentImportSession.wordCount,
+			)
+		}
+
+		if code, err := d.confirmSeedReplacement("mnemonic"); err != nil {
+			return nil, code, err
+		}
+
+		if err := d.Token.ImportSeed(d.currentImportSession.words); err != nil {
+			return nil, apps.APDUDataInvalid, err
+		}
+
+		d.l.Infow("device seed restored from mnemonic", "mnemonic_words", d.currentImportSession.wordCount)
+
+		return nil, apps.APDUSuccess, nil
+	default:
+		d.endImportSession()
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown import step %X", data[2])
+	}
+}
+
+// confirmSeedReplacement asks the user to approve replacing the seed of the active profile with the
+// one imported from source, if there is one.
+func (d *Device) confirmSeedReplacement(source string) (apps.APDUCode, error) {
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return apps.APDUSuccess, nil
+	}
+
+	if d.Confirm == nil {
+		return apps.APDUCommandNotAllowed, fmt.Errorf("no way to ask for user confirmation")
+	}
+
+	approved, err := d.Confirm.Confirm(fmt.Sprintf("Replace the current wallet with the one of the imported %v?", source))
+	if err != nil {
+		return apps.APDUExecutionError, err
+	}
+
+	if !approved {
+		return apps.APDUCommandNotAllowed, fmt.Errorf("seed replacement refused by the user")
+	}
+
+	return apps.APDUSuccess, nil
+}
+
+// endImportSession wipes the words received so far, and closes the import session.
+func (d *Device) endImportSession() {
+	if d.currentImportSession != nil {
+		crypto.WipeWords(d.currentImportSession.words)
+	}
+
+	d.currentImportSession = nil
+}
+
+// handleSLIP39Backup splits the device seed into SLIP-39 shares, as described by the payload: the group
+// threshold, followed by the member threshold and the member count of each group.
+// Shares never leave the device through responses: each one is revealed to the user through Reveal,
+// and the backup is aborted as soon as one of them isn't confirmed.
+func (d *Device) handleSLIP39Backup(data []byte) (response []byte, code apps.APDUCode, err error) {
+	payload := data[minDataLen:]
+	if len(payload) < 3 || len(payload)%2 != 1 {
+		return nil, apps.APDUWrongLength, fmt.Errorf("malformed SLIP-39 backup payload")
+	}
+
+	groupThreshold := int(payload[0])
+
+	groups := []crypto.SLIP39Group{}
+	for i := 1; i < len(payload); i += 2 {
+		groups = append(groups, crypto.SLIP39Group{
+			Threshold: int(payload[i]),
+			Count:     int(payload[i+1]),
+		})
+	}
+
+	if d.Reveal == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to reveal secrets to the user")
+	}
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device has not been set up")
+	}
+
+	shares, err := d.Token.SLIP39Shares(groupThreshold, groups)
+	if err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	defer func() {
+		for _, group := range shares {
+			for _, words := range group {
+				crypto.WipeWords(words)
+			}
+		}
+	}()
+
+	for gi, group := range shares {
+		for mi, words := range group {
+			prompt := fmt.Sprintf(
+				"SLIP-39 share %v of %v of group %v of %v: %v shares recover the group, %v groups recover the wallet. Written down?",
+				mi+1, len(group), gi+1, len(shares), groups[gi].Threshold, groupThreshold,
+			)
+
+			share := crypto.JoinWords(words)
+			confirmed, err := d.Reveal.ConfirmSecret(prompt, share)
+			crypto.Wipe(share)
+
+			if err != nil {
+				return nil, apps.APDUExecutionError, err
+			}
+
+			if !confirmed {
+				return nil, apps.APDUCommandNotAllowed, fmt.Errorf("SLIP-39 backup aborted by the user")
+			}
+		}
+	}
+
+	d.l.Infow("SLIP-39 backup created", "groups", len(groups), "group_threshold", groupThreshold)
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleImportSLIP39 restores a seed from SLIP-39 shares, sent one word per command:
+//   - importBegin opens a session for shares of P2 words, 20, 27 or 33
+//   - importWord carries a single word, which is checked against the wordlist before being accepted,
+//     and shares are complete once they have P2 words
+//   - importFinish recovers the seed from the shares received, and replaces the device seed once the
+//     user approved it
+//
+// importWord responds with the amount of complete shares, followed by the amount of words received
+// for the current one.
+func (d *Device) handleImportSLIP39(data []byte) (response []byte, code apps.APDUCode, err error) {
+	step := importStep(data[2])
+	d.l.Debugw("import SLIP-39 shares", "step", step.String())
+
+	if d.currentSharesImportSession == nil && step != importBegin {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("wrong import step with no session initialized, %v", step.String())
+	}
+
+	switch step {
+	case importBegin:
+		shareWords := int(data[3])
+		if !crypto.ValidSLIP39Length(shareWords) {
+			return nil, apps.APDUDataInvalid, fmt.Errorf("unsupported SLIP-39 share length %v", shareWords)
+		}
+
+		d.endSharesImportSession()
+		d.currentSharesImportSession = &sharesImportSession{
+			shareWords: shareWords,
+			current:    make([]string, 0, shareWords),
+		}
+
+		return nil, apps.APDUSuccess, nil
+	case importWord:
+		session := d.currentSharesImportSession
+		if len(session.shares) == maxImportShares {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf("too many SLIP-39 shares")
+		}
+
+		word, found := crypto.SLIP39Word(data[minDataLen:])
+		crypto.Wipe(data[minDataLen:])
+
+		if !found {
+			return nil, apps.APDUDataInvalid, fmt.Errorf(
+				"word %v of share %v is not in the SLIP-39 wordlist", len(session.current)+1, len(session.shares)+1,
+			)
+		}
+
+		session.current = append(session.current, word)
+		if len(session.current) == session.shareWords {
+			session.shares = append(session.shares, session.current)
+			session.current = make([]string, 0, session.shareWords)
+		}
+
+		return []byte{byte(len(session.shares)), byte(len(session.current))}, apps.APDUSuccess, nil
+	case importFinish:
+		defer d.endSharesImportSession()
+
+		session := d.currentSharesImportSession
+		if len(session.shares) == 0 || len(session.current) != 0 {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf(
+				"received %v complete shares, and %v words of the current one", len(session.shares), len(session.current),
+			)
+		}
+
+		if code, err := d.confirmSeedReplacement("SLIP-39 shares"); err != nil {
+			return nil, code, err
+		}
+
+		if err := d.Token.ImportSLIP39Shares(session.shares); err != nil {
+			return nil, apps.APDUDataInvalid, err
+		}
+
+		d.l.Infow("device seed restored from SLIP-39 shares", "shares", len(session.shares))
+
+		return nil, apps.APDUSuccess, nil
+	default:
+		d.endSharesImportSession()
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown import step %X", data[2])
+	}
+}
+
+// endSharesImportSession wipes the shares received so far, and closes the SLIP-39 import session.
+func (d *Device) endSharesImportSession() {
+	if d.currentSharesImportSession != nil {
+		for _, words := range d.currentSharesImportSession.shares {
+			crypto.WipeWords(words)
+		}
+
+		crypto.WipeWords(d.currentSharesImportSession.current)
+	}
+
+	d.currentSharesImportSession = nil
+}
+
+// handleDeriveBIP85 derives a BIP-85 child secret of the current wallet: P1 selects the application,
+// P2 holds the amount of mnemonic words, hex bytes or password characters, and the payload holds the
+// child index as a big-endian uint32.
+// Like SLIP-39 shares, child secrets never leave the device through responses: they're revealed to
+// the user through Reveal.
+func (d *Device) handleDeriveBIP85(data []byte) (response []byte, code apps.APDUCode, err error) {
+	app, found := bip85Applications[data[2]]
+	if !found {
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown BIP-85 application %X", data[2])
+	}
+
+	length := uint32(data[3])
+
+	payload := data[minDataLen:]
+	if len(payload) != 4 {
+		return nil, apps.APDUWrongLength, fmt.Errorf("malformed BIP-85 payload")
+	}
+
+	index := binary.BigEndian.Uint32(payload)
+
+	if d.Reveal == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to reveal secrets to the user")
+	}
+
+	found, err = d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device has not been set up")
+	}
+
+	path, err := crypto.BIP85Path(app, length, index)
+	if err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	secret, err := d.Token.BIP85(app, length, index)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	defer crypto.Wipe(secret)
+
+	confirmed, err := d.Reveal.ConfirmSecret(fmt.Sprintf("BIP-85 child secret at %v. Written down?", path), secret)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !confirmed {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("BIP-85 derivation aborted by the user")
+	}
+
+	d.l.Infow("BIP-85 child secret derived", "application", app.String(), "path", path.String())
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleListProfiles responds with the index of the active profile, followed by the label of every
+// profile prefixed by its length.
+func (d *Device) handleListProfiles() (response []byte, code apps.APDUCode, err error) {
+	labels, active, err := d.Token.Profiles()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	response = []byte{0}
+	for i, label := range labels {
+		if label == active {
+			response[0] = byte(i)
+		}
+
+		response = append(response, byte(len(label)))
+		response = append(response, label...)
+	}
+
+	return response, apps.APDUSuccess, nil
+}
+
+// handleSelectProfile makes the profile whose label is held in the payload the active one, creating
+// it without a seed if needed, and resets the passphrase.
+// It responds with the fingerprint of the profile wallet, or nothing if the profile has no seed yet.
+func (d *Device) handleSelectProfile(data []byte) (response []byte, code apps.APDUCode, err error) {
+	label := string(data[minDataLen:])
+	if err := crypto.ValidProfileLabel(label); err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	// seeds being restored belong to the profile they have been started on
+	d.endImportSession()
+	d.endSharesImportSession()
+
+	if err := d.Token.SelectProfile(label); err != nil {
+		return nil, apps.APDUCommandNotAllowed, err
+	}
+
+	d.l.Infow("profile selected", "profile", label)
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return nil, apps.APDUSuccess, nil
+	}
+
+	fp, err := d.Token.Fingerprint()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return fp, apps.APDUSuccess, nil
+}
+
+// handleDeleteProfile wipes the seed of the profile whose label is held in the payload, and deletes
+// the profile, once the user approved it.
+func (d *Device) handleDeleteProfile(data []byte) (response []byte, code apps.APDUCode, err error) {
+	label := string(data[minDataLen:])
+	if err := crypto.ValidProfileLabel(label); err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	if d.Confirm == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to ask for user confirmation")
+	}
+
+	approved, err := d.Confirm.Confirm(fmt.Sprintf("Delete profile %q and wipe its seed?", label))
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !approved {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("profile deletion refused by the user")
+	}
+
+	if err := d.Token.DeleteProfile(label); err != nil {
+		return nil, apps.APDUCommandNotAllowed, err
+	}
+
+	d.l.Infow("profile deleted", "profile", label)
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleGetAttestation signs the host challenge held in the payload along with the firmware revision,
+// with the attestation key: Tokens which are crypto.MeasuredAttesters attest their measured revision.
+// It responds with the revision prefixed by its length, the attestation chain prefixed by its length
+// as a big-endian uint16, and the ASN.1 ECDSA signature.
+// Hosts check it with crypto.VerifyAttestation, against the root CA certificate.
+func (d *Device) handleGetAttestation(data []byte) (response []byte, code apps.APDUCode, err error) {
+	challenge := data[minDataLen:]
+	if len(challenge) < crypto.MinChallengeSize || len(challenge) > crypto.MaxChallengeSize {
+		return nil, apps.APDUWrongLength, fmt.Errorf("challenge must be between %v and %v bytes long",
+			crypto.MinChallengeSize, crypto.MaxChallengeSize)
+	}
+
+	revision := d.Revision
+	if ma, ok := d.Token.(crypto.MeasuredAttester); ok {
+		if revision, err = ma.MeasuredRevision(); err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+	}
+
+	if len(revision) > crypto.MaxRevisionLength {
+		return nil, apps.APDUExecutionError, fmt.Errorf("firmware revision is too long")
+	}
+
+	chain, err := d.Token.AttestationChain()
+	if err != nil {
+		return nil, apps.APDUCommandNotAllowed, err
+	}
+
+	signature, err := d.Token.Attest(challenge, revision)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	response = append([]byte{byte(len(revision))}, revision...)
+	response = append(response, 0, 0)
+	binary.BigEndian.PutUint16(response[len(response)-2:], uint16(len(chain)))
+	response = append(response, chain...)
+	response = append(response, signature...)
+
+	return response, apps.APDUSuccess, nil
+}
+
+// handleAttestationCSR responds with a DER certificate signing request for the attestation key,
+// which the device generates on the first request.
+// The provisioning CA issues the device certificate out of it, see cmd/gen-cert.
+func (d *Device) handleAttestationCSR() (response []byte, code apps.APDUCode, err error) {
+	csr, err := d.Token.AttestationCSR()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return csr, apps.APDUSuccess, nil
+}
+
+// handleSetAttestationChain stores the attestation chain issued by the provisioning CA, sent in chunks:
+// P1 holds the index of the chunk, starting over from 0, and P2 is chainLastChunk on the last one,
+// once the device stores the whole chain.
+func (d *Device) handleSetAttestationChain(data []byte) (response []byte, code apps.APDUCode, err error) {
+	index, flag, chunk := data[2], data[3], data[minDataLen:]
+
+	if index == 0 {
+		d.attestationChain = []byte{}
+	}
+
+	if d.attestationChain == nil || int(index)*maxChainChunkSize != len(d.attestationChain) {
+		d.attestationChain = nil
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("unexpected attestation chain chunk %v", index)
+	}
+
+	if len(d.attestationChain)+len(chunk) > crypto.MaxAttestationChainSize {
+		d.attestationChain = nil
+		return nil, apps.APDUWrongLength, fmt.Errorf("attestation chain is too large")
+	}
+
+	d.attestationChain = append(d.attestationChain, chunk...)
+
+	switch flag {
+	case chainMoreChunks:
+		if len(chunk) != maxChainChunkSize {
+			d.attestationChain = nil
+			return nil, apps.APDUWrongLength, fmt.Errorf("attestation chain chunks must be %v bytes long", maxChainChunkSize)
+		}
+
+		return nil, apps.APDUSuccess, nil
+	case chainLastChunk:
+		chain := d.attestationChain
+		d.attestationChain = nil
+
+		if err := d.Token.SetAttestationChain(chain); err != nil {
+			return nil, apps.APDUDataInvalid, err
+		}
+
+		d.l.Infow("attestation chain provisioned", "size", len(chain))
+
+		return nil, apps.APDUSuccess, nil
+	default:
+		d.attestationChain = nil
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown attestation chain flag %X", flag)
+	}
+}
+
+// handleSetPassphrase sets the BIP-39 passphrase held in the payload for the rest of the session,
+// and responds with the fingerprint of the wallet it selects.
+// An empty payload selects the standard wallet.
+func (d *Device) handleSetPassphrase(data []byte) (response []byte, code apps.APDUCode, err error) {
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device has not been set up")
+	}
+
+	// the Token keeps its own copy of the passphrase
+	defer crypto.Wipe(data[minDataLen:])
+
+	if err := d.Token.SetPassphrase(data[minDataLen:]); err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	fp, err := d.Token.Fingerprint()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	d.l.Infow("passphrase set", "fingerprint", hex.EncodeToString(fp))
+
+	return fp, apps.APDUSuccess, nil
+}
+
+// handleSetPIN sets the PIN held in the payload, on a device which has neither a PIN nor a seed.
+func (d *Device) handleSetPIN(data []byte) (response []byte, code apps.APDUCode, err error) {
+	defer crypto.Wipe(data[minDataLen:])
+
+	found, err := d.PIN.IsSet()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("pin has already been set")
+	}
+
+	// the PIN must be set before any seed: otherwise, removing the PIN verifier from the storage would
+	// let anyone set a PIN of their own, and unlock the seeds
+	seeded, err := d.Token.HasAnySeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if seeded {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device holds a seed without a pin")
+	}
+
+	if err := d.PIN.Set(data[minDataLen:]); err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	d.Token.UseDecoy(false)
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleSetDuressPIN sets the duress PIN held in the payload, and sets up the decoy wallet it unlocks
+// with a fresh seed if there's none.
+func (d *Device) handleSetDuressPIN(data []byte) (response []byte, code apps.APDUCode, err error) {
+	defer crypto.Wipe(data[minDataLen:])
+
+	if d.PIN.State() == pin.Duress {
+		// pretend everything went fine, like it would have on the real wallet
+		d.l.Debugw("ignoring duress pin change under duress")
+		return nil, apps.APDUSuccess, nil
+	}
+
+	if d.PIN.State() != pin.Unlocked {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device is locked")
+	}
+
+	if err := d.PIN.SetDuress(data[minDataLen:]); err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	d.Token.UseDecoy(true)
+	defer d.Token.UseDecoy(false)
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		if err := d.Token.GenerateSeed(256); err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+	}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleVerifyPIN unlocks the device with the PIN held in the payload.
+// The duress PIN yields the very same response, while switching the Token to the decoy wallet.
+func (d *Device) handleVerifyPIN(data []byte) (response []byte, code apps.APDUCode, err error) {
+	defer crypto.Wipe(data[minDataLen:])
+
+	state, err := d.PIN.Verify(data[minDataLen:])
+	if err != nil {
+		return d.pinError(err)
+	}
+
+	d.Token.UseDecoy(state == pin.Duress)
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleChangePIN changes the PIN: the payload holds the current PIN length, followed by the current
+// PIN and the new one.
+// Under duress, the duress PIN is changed instead.
+func (d *Device) handleChangePIN(data []byte) (response []byte, code apps.APDUCode, err error) {
+	payload := data[minDataLen:]
+	defer crypto.Wipe(payload)
+
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return nil, apps.APDUWrongLength, fmt.Errorf("malformed change pin payload")
+	}
+
+	oldPIN := payload[1 : 1+payload[0]]
+	newPIN := payload[1+payload[0]:]
+
+	if err := d.PIN.Change(oldPIN, newPIN); err != nil {
+		return d.pinError(err)
+	}
+
+	d.Token.UseDecoy(d.PIN.State() == pin.Duress)
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleExportAccount exports the extended public key of the account whose wire encoded path is
+// held in the payload, once the user approved it.
+// With exportXPub, the response holds the 4 bytes master key fingerprint, followed by the wire encoded path
+// and the serialized extended public key.
+// With exportDescriptors, the response holds the receive and change output descriptors, separated by a newline.
+func (d *Device) handleExportAccount(data []byte) (response []byte, code apps.APDUCode, err error) {
+	format := data[2]
+	if format != exportXPub && format != exportDescriptors {
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown export format %X", format)
+	}
+
+	path, rest, err := crypto.DecodeDerivationPath(data[minDataLen:])
+	if err != nil {
+		return nil, apps.APDUDataInvalid, err
+	}
+
+	if len(rest) != 0 {
+		return nil, apps.APDUWrongLength, fmt.Errorf("trailing data after derivation path")
+	}
+
+	found, err := d.Token.HasSeed()
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !found {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("device has not been set up")
+	}
+
+	key, err := crypto.NewScopedToken(d.Token, crypto.ScopeAccountExport).AccountKey(path)
+	if err != nil {
+		return nil, apps.APDUCommandNotAllowed, err
+	}
+
+	if format == exportXPub {
+		rawPath, err := path.MarshalBinary()
+		if err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		response = append([]byte{}, key.Fingerprint...)
+		response = append(response, rawPath...)
+		response = append(response, key.XPub...)
+	} else {
+		descriptors, err := key.Descriptors()
+		if err != nil {
+			return nil, apps.APDUDataInvalid, err
+		}
+
+		response = []byte(strings.Join(descriptors, "\n"))
+	}
+
+	if d.Confirm == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to ask for user confirmation")
+	}
+
+	approved, err := d.Confirm.Confirm(fmt.Sprintf("Export public key of account %v of wallet %x?", path, key.Fingerprint))
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !approved {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("account export refused by the user")
+	}
+
+	d.l.Infow("account exported", "path", path.String())
+
+	return response, apps.APDUSuccess, nil
+}
+
+// pinError returns the response to a failed PIN operation.
+// Wrong PINs are signalled with APDUWrongPIN, whose low nibble holds the retries left.
+func (d *Device) pinError(pinErr error) (response []byte, code apps.APDUCode, err error) {
+	switch {
+	case errors.Is(pinErr, pin.ErrWrongPIN):
+		retries, err := d.PIN.RetriesLeft()
+		if err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		if retries > 0x0F {
+			retries = 0x0F
+		}
+
+		return nil, apps.APDUWrongPIN | apps.APDUCode(retries), pinErr
+	case errors.Is(pinErr, pin.ErrWiped):
+		d.endImportSession()
+		d.endSharesImportSession()
+		d.l.Warnw("too many wrong pins, device wiped")
+		return nil, apps.APDUWrongPIN, pinErr
+	case errors.Is(pinErr, pin.ErrNotSet):
+		return nil, apps.APDUCommandNotAllowed, pinErr
+	default:
+		return nil, apps.APDUDataInvalid, pinErr
+	}
+}
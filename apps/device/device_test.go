@@ -0,0 +1,343 @@
+package device
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/appstest"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/pin"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// newTestDevice returns a Device whose PIN has been set, and which is unlocked.
+func newTestDevice(t *testing.T, reveal apps.SecretConfirmer) *Device {
+	t.Helper()
+
+	token := crypto.NewDumbToken(storage.NewMemory())
+	pm := pin.NewManager(storage.NewMemory(), token.Wipe)
+	require.NoError(t, pm.Set([]byte("1234")))
+
+	return &Device{
+		Token:  token,
+		PIN:    pm,
+		Reveal: reveal,
+	}
+}
+
+// testFingerprint is the fingerprint of the wallet of appstest.Mnemonic.
+const testFingerprint = "73c5da0a"
+
+func run(d *Device, cmd command, p1, p2 byte, payload []byte) ([]byte, apps.APDUCode) {
+	response, code, _ := appstest.Handle(d, byte(cmd), p1, p2, payload)
+	return response, code
+}
+
+func importMnemonic(t *testing.T, d *Device, mnemonic string) apps.APDUCode {
+	t.Helper()
+
+	words := strings.Fields(mnemonic)
+
+	_, code := run(d, claImportMnemonic, byte(importBegin), byte(len(words)), nil)
+	require.Equal(t, apps.APDUSuccess, code)
+
+	for i, w := range words {
+		response, code := run(d, claImportMnemonic, byte(importWord), 0x00, []byte(w))
+		require.Equal(t, apps.APDUSuccess, code)
+		require.Equal(t, []byte{byte(i + 1)}, response)
+	}
+
+	_, code = run(d, claImportMnemonic, byte(importFinish), 0x00, nil)
+	return code
+}
+
+func importShares(t *testing.T, d *Device, shares [][]string) apps.APDUCode {
+	t.Helper()
+
+	_, code := run(d, claImportSLIP39, byte(importBegin), byte(len(shares[0])), nil)
+	require.Equal(t, apps.APDUSuccess, code)
+
+	for _, share := range shares {
+		for _, w := range share {
+			_, code := run(d, claImportSLIP39, byte(importWord), 0x00, []byte(w))
+			require.Equal(t, apps.APDUSuccess, code)
+		}
+	}
+
+	_, code = run(d, claImportSLIP39, byte(importFinish), 0x00, nil)
+	return code
+}
+
+func generateSeed(d *Device, entropy byte) apps.APDUCode {
+	_, code := run(d, claGenerateSeed, entropy, 0x00, nil)
+	return code
+}
+
+func TestGenerateSeedRevealsTheMnemonic(t *testing.T) {
+	var revealed string
+	d := newTestDevice(t, apps.SecretConfirmFunc(func(prompt string, secret []byte) (bool, error) {
+		revealed = string(secret)
+		return true, nil
+	}))
+
+	require.Equal(t, apps.APDUSuccess, generateSeed(d, entropy256))
+	require.Len(t, strings.Fields(revealed), 24)
+
+	mnemonic, err := d.Token.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, strings.Fields(revealed), mnemonic)
+
+	// the seed can't be replaced
+	require.Equal(t, apps.APDUCommandNotAllowed, generateSeed(d, entropy128))
+}
+
+func TestGenerateSeedDeclinedReveal(t *testing.T) {
+	revealed := false
+	d := newTestDevice(t, apps.SecretConfirmFunc(func(prompt string, secret []byte) (bool, error) {
+		revealed = true
+		return false, nil
+	}))
+
+	require.Equal(t, apps.APDUCommandNotAllowed, generateSeed(d, entropy128))
+	require.True(t, revealed)
+
+	found, err := d.Token.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestGenerateSeedWithoutReveal(t *testing.T) {
+	d := newTestDevice(t, nil)
+
+	require.Equal(t, apps.APDUCommandNotAllowed, generateSeed(d, entropy128))
+
+	found, err := d.Token.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestImportMnemonic(t *testing.T) {
+	d := newTestDevice(t, nil)
+
+	response, code := run(d, claGetStatus, 0x00, 0x00, nil)
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, []byte{statusPINSet | statusUnlocked}, response)
+
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, appstest.Mnemonic))
+
+	// seeded, followed by the BIP-32 master key fingerprint
+	response, code = run(d, claGetStatus, 0x00, 0x00, nil)
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, statusSeeded|statusPINSet|statusUnlocked, response[0])
+	require.Equal(t, testFingerprint, hex.EncodeToString(response[1:]))
+}
+
+func TestImportMnemonicRejects(t *testing.T) {
+	d := newTestDevice(t, nil)
+
+	// unsupported lengths
+	_, code := run(d, claImportMnemonic, byte(importBegin), 13, nil)
+	require.Equal(t, apps.APDUDataInvalid, code)
+
+	// words out of a session
+	_, code = run(d, claImportMnemonic, byte(importWord), 0x00, []byte("abandon"))
+	require.Equal(t, apps.APDUCommandNotAllowed, code)
+
+	// words out of the wordlist
+	_, code = run(d, claImportMnemonic, byte(importBegin), 12, nil)
+	require.Equal(t, apps.APDUSuccess, code)
+
+	_, code = run(d, claImportMnemonic, byte(importWord), 0x00, []byte("wallera"))
+	require.Equal(t, apps.APDUDataInvalid, code)
+
+	// missing words
+	_, code = run(d, claImportMnemonic, byte(importFinish), 0x00, nil)
+	require.Equal(t, apps.APDUCommandNotAllowed, code)
+
+	// wrong checksum
+	require.Equal(t, apps.APDUDataInvalid, importMnemonic(t, d, strings.Repeat("abandon ", 12)))
+
+	found, err := d.Token.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestImportMnemonicReplacingSeed(t *testing.T) {
+	d := newTestDevice(t, nil)
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, appstest.Mnemonic))
+
+	other := "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong"
+
+	// without a way to ask the user, seeds are never replaced
+	require.Equal(t, apps.APDUCommandNotAllowed, importMnemonic(t, d, other))
+
+	var prompts []string
+	approve := false
+	d.Confirm = apps.ConfirmFunc(func(prompt string) (bool, error) {
+		prompts = append(prompts, prompt)
+		return approve, nil
+	})
+
+	require.Equal(t, apps.APDUCommandNotAllowed, importMnemonic(t, d, other))
+
+	mnemonic, err := d.Token.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, strings.Fields(appstest.Mnemonic), mnemonic)
+
+	approve = true
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, other))
+	require.Len(t, prompts, 2)
+
+	mnemonic, err = d.Token.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, strings.Fields(other), mnemonic)
+}
+
+func TestImportSLIP39ReplacingSeed(t *testing.T) {
+	d := newTestDevice(t, nil)
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, appstest.Mnemonic))
+
+	secret := bytes.Repeat([]byte{0xFF}, 16)
+	groups, err := crypto.SLIP39Split(rand.Reader, secret, nil, 1, []crypto.SLIP39Group{{Threshold: 1, Count: 1}})
+	require.NoError(t, err)
+
+	// without a way to ask the user, seeds are never replaced
+	require.Equal(t, apps.APDUCommandNotAllowed, importShares(t, d, groups[0]))
+
+	d.Confirm = apps.ConfirmFunc(func(prompt string) (bool, error) {
+		return false, nil
+	})
+	require.Equal(t, apps.APDUCommandNotAllowed, importShares(t, d, groups[0]))
+
+	mnemonic, err := d.Token.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, strings.Fields(appstest.Mnemonic), mnemonic)
+
+	d.Confirm = apps.ConfirmFunc(func(prompt string) (bool, error) {
+		return true, nil
+	})
+	require.Equal(t, apps.APDUSuccess, importShares(t, d, groups[0]))
+
+	// the master secret is the BIP-32 seed of the wallet
+	expected, err := crypto.Fingerprint(secret)
+	require.NoError(t, err)
+
+	fingerprint, err := d.Token.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fingerprint)
+}
+
+func TestPINLock(t *testing.T) {
+	tokenStorage, pinStorage := storage.NewMemory(), storage.NewMemory()
+
+	// boot returns the device as it starts up, locked if it has a PIN
+	boot := func() *Device {
+		token := crypto.NewDumbToken(tokenStorage)
+		return &Device{
+			Token: token,
+			PIN:   pin.NewManager(pinStorage, token.Wipe),
+		}
+	}
+
+	fingerprint := func(d *Device) string {
+		response, code := run(d, claGetStatus, 0x00, 0x00, nil)
+		require.Equal(t, apps.APDUSuccess, code)
+		require.Equal(t, statusSeeded|statusPINSet|statusUnlocked, response[0])
+
+		return hex.EncodeToString(response[1:])
+	}
+
+	d := boot()
+
+	_, code := run(d, claSetPIN, 0x00, 0x00, []byte("1234"))
+	require.Equal(t, apps.APDUSuccess, code)
+
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, appstest.Mnemonic))
+	require.Equal(t, testFingerprint, fingerprint(d))
+
+	_, code = run(d, claSetDuressPIN, 0x00, 0x00, []byte("9999"))
+	require.Equal(t, apps.APDUSuccess, code)
+
+	// locked devices tell nothing about their wallet, and refuse to change it
+	d = boot()
+
+	response, code := run(d, claGetStatus, 0x00, 0x00, nil)
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, []byte{statusSeeded | statusPINSet}, response)
+
+	_, code = run(d, claImportMnemonic, byte(importBegin), 12, nil)
+	require.Equal(t, apps.APDUDeviceLocked, code)
+
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("0000"))
+	require.Equal(t, apps.APDUWrongPIN|apps.APDUCode(pin.MaxRetries-1), code)
+
+	// the duress PIN unlocks the decoy wallet
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("9999"))
+	require.Equal(t, apps.APDUSuccess, code)
+
+	decoy := fingerprint(d)
+	require.NotEqual(t, testFingerprint, decoy)
+
+	// a right PIN resets the retry counter
+	d = boot()
+
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("0000"))
+	require.Equal(t, apps.APDUWrongPIN|apps.APDUCode(pin.MaxRetries-1), code)
+
+	_, code = run(d, claChangePIN, 0x00, 0x00, append([]byte{4}, "12345678"...))
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, testFingerprint, fingerprint(d))
+
+	d = boot()
+
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("1234"))
+	require.Equal(t, apps.APDUWrongPIN|apps.APDUCode(pin.MaxRetries-1), code)
+
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("5678"))
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, testFingerprint, fingerprint(d))
+
+	// the duress PIN survived the change
+	d = boot()
+
+	_, code = run(d, claVerifyPIN, 0x00, 0x00, []byte("9999"))
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, decoy, fingerprint(d))
+}
+
+func TestPINRemovedFromStorage(t *testing.T) {
+	tokenStorage, pinStorage := storage.NewMemory(), storage.NewMemory()
+
+	token := crypto.NewDumbToken(tokenStorage)
+	d := &Device{
+		Token: token,
+		PIN:   pin.NewManager(pinStorage, token.Wipe),
+	}
+
+	_, code := run(d, claSetPIN, 0x00, 0x00, []byte("1234"))
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, apps.APDUSuccess, importMnemonic(t, d, appstest.Mnemonic))
+
+	// someone with access to the storage deletes the PIN verifier
+	require.NoError(t, pinStorage.Delete("pin/verifier"))
+
+	d = &Device{
+		Token: token,
+		PIN:   pin.NewManager(pinStorage, token.Wipe),
+	}
+
+	response, code := run(d, claGetStatus, 0x00, 0x00, nil)
+	require.Equal(t, apps.APDUSuccess, code)
+	require.Equal(t, []byte{statusSeeded}, response)
+
+	_, code = run(d, claSetPIN, 0x00, 0x00, []byte("0000"))
+	require.Equal(t, apps.APDUCommandNotAllowed, code)
+
+	_, code = run(d, claExportAccount, 0x00, 0x00, nil)
+	require.Equal(t, apps.APDUDeviceLocked, code)
+}
@@ -0,0 +1,340 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cosmos/btcutil/bech32"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/log"
+	"go.uber.org/zap"
+)
+
+//go:generate stringer -type command
+type command byte
+
+const (
+	appName      = "NOSTR"
+	appID   byte = 0x4E
+
+	minDataLen = 5
+
+	// NIP-06: m/44'/1237'/<account>'/0/0
+	coinType = 1237
+
+	npubHRP = "npub"
+
+	claGetPublicKey command = 0x02
+	claSignEvent    command = 0x04
+)
+
+//go:generate stringer -type signPayloadDescr
+type signPayloadDescr byte
+
+const (
+	signInit signPayloadDescr = 0
+	signAdd  signPayloadDescr = 1
+	signLast signPayloadDescr = 2
+)
+
+// Nostr handles Nostr keys and event signatures.
+type Nostr struct {
+	// Token is set by apps.Handler.Register, restricted to KeyScope.
+	Token                   crypto.Token
+	currentSignatureSession *signatureSession
+
+	// Confirm asks the user to approve every event before it's signed.
+	// When nil, events are never signed.
+	Confirm apps.Confirmer
+
+	// TODO: figure out how to better handle logger instance
+	l *zap.SugaredLogger
+}
+
+func (n *Nostr) initLog() {
+	if n.l != nil {
+		return
+	}
+
+	n.l = log.Development(
+		zap.Fields(zap.String("app_name", n.Name())),
+	).Sugar()
+}
+
+// Name implements the apps.App interface
+func (n *Nostr) Name() string {
+	return appName
+}
+
+// ID implements the apps.App interface
+func (n *Nostr) ID() byte {
+	return appID
+}
+
+// KeyScope implements the apps.KeyUser interface
+func (n *Nostr) KeyScope() crypto.ScopeID {
+	return crypto.ScopeNostr
+}
+
+// SetToken implements the apps.KeyUser interface
+func (n *Nostr) SetToken(t crypto.Token) {
+	n.Token = t
+}
+
+// Commands implements the apps.App interface
+func (n *Nostr) Commands() (commandIDs []byte) {
+	ret := []byte{
+		byte(claGetPublicKey),
+		byte(claSignEvent),
+	}
+
+	return ret
+}
+
+// Handle implements the apps.App interface
+func (n *Nostr) Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error) {
+	n.initLog()
+
+	if len(data) < minDataLen {
+		return nil, apps.APDUWrongLength, fmt.Errorf("data is too small to be processed")
+	}
+
+	n.l.Debugw("handling command", "name", command(cmd).String())
+	switch cmd {
+	case byte(claGetPublicKey):
+		return n.handleGetPublicKey(data)
+	case byte(claSignEvent):
+		return n.handleSignEvent(data)
+	default:
+		return nil, apps.APDUINSNotSupported, fmt.Errorf("command not found")
+	}
+}
+
+func derivationPath(account uint32) crypto.DerivationPath {
+	return crypto.BIP44Path(coinType, account, 0, 0)
+}
+
+// accountFromData reads the little-endian account index found right after the APDU header.
+func accountFromData(data []byte) (uint32, error) {
+	if len(data) < minDataLen+4 {
+		return 0, fmt.Errorf("missing account index")
+	}
+
+	return binary.LittleEndian.Uint32(data[minDataLen : minDataLen+4]), nil
+}
+
+// publicKey returns the x-only public key at dp.
+func (n *Nostr) publicKey(dp crypto.DerivationPath) ([]byte, error) {
+	pubkey, err := n.Token.PublicKey(dp, crypto.AlgoSecp256K1Schnorr)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.XOnlyPublicKey(pubkey)
+}
+
+func npub(xonly []byte) (string, error) {
+	converted, err := bech32.ConvertBits(xonly, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.Encode(npubHRP, converted)
+}
+
+func (n *Nostr) handleGetPublicKey(data []byte) (response []byte, code apps.APDUCode, err error) {
+	account, err := accountFromData(data)
+	if err != nil {
+		return nil, apps.APDUWrongLength, err
+	}
+
+	dp := derivationPath(account)
+	n.l.Debugw("derivation path", "value", dp.String())
+
+	xonly, err := n.publicKey(dp)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	encoded, err := npub(xonly)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	n.l.Debugw("should display on device", "value", data[2] == 0x01)
+	n.l.Debugw("public key generation complete", "npub", encoded)
+
+	r := &bytes.Buffer{}
+	r.Write(xonly)
+	r.WriteString(encoded)
+
+	return r.Bytes(), apps.APDUSuccess, nil
+}
+
+type signatureSession struct {
+	account uint32
+	data    *bytes.Buffer
+}
+
+// wipe zeroes the data received so far.
+// The buffers data outgrew while receiving it have been left to the garbage collector.
+func (s *signatureSession) wipe() {
+	b := s.data.Bytes()
+	crypto.Wipe(b[:cap(b)])
+	s.data.Reset()
+}
+
+// endSignatureSession wipes and closes the signature session.
+func (n *Nostr) endSignatureSession() {
+	if n.currentSignatureSession != nil {
+		n.currentSignatureSession.wipe()
+	}
+
+	n.currentSignatureSession = nil
+}
+
+// event is an unsigned NIP-01 event, as sent by the host.
+type event struct {
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+}
+
+func (n *Nostr) handleSignEvent(data []byte) (response []byte, code apps.APDUCode, err error) {
+	payloadDescription := signPayloadDescr(data[2])
+	n.l.Debugw("sign payload", "description", payloadDescription.String())
+
+	if n.currentSignatureSession == nil && payloadDescription != signInit {
+		return nil, apps.APDUExecutionError, fmt.Errorf("wrong signature description with no session initialized, %v", payloadDescription.String())
+	}
+
+	switch payloadDescription {
+	case signInit:
+		account, err := accountFromData(data)
+		if err != nil {
+			return nil, apps.APDUWrongLength, err
+		}
+
+		n.endSignatureSession()
+		n.currentSignatureSession = &signatureSession{
+			account: account,
+			data:    &bytes.Buffer{},
+		}
+	case signAdd, signLast:
+		n.l.Debugw("writing data to session", "length", len(data[minDataLen:]))
+		n.currentSignatureSession.data.Write(data[minDataLen:])
+	default:
+		n.endSignatureSession()
+		return nil, apps.APDUDataInvalid, fmt.Errorf("unknown payload description %v", payloadDescription)
+	}
+
+	if payloadDescription != signLast {
+		return nil, apps.APDUSuccess, nil
+	}
+
+	defer n.endSignatureSession()
+
+	ev := event{}
+	if err := json.Unmarshal(n.currentSignatureSession.data.Bytes(), &ev); err != nil {
+		return nil, apps.APDUDataInvalid, fmt.Errorf("provided event isn't valid JSON, %w", err)
+	}
+
+	if ev.Tags == nil {
+		ev.Tags = [][]string{}
+	}
+
+	dp := derivationPath(n.currentSignatureSession.account)
+
+	xonly, err := n.publicKey(dp)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	// the event id is computed on the device, so that what gets displayed is what gets signed
+	id := eventID(xonly, ev)
+
+	if n.Confirm == nil {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to ask for user confirmation")
+	}
+
+	approved, err := n.Confirm.Confirm(fmt.Sprintf(
+		"Sign Nostr event of kind %v with %v tags from account %v? Content: %q",
+		ev.Kind, len(ev.Tags), n.currentSignatureSession.account, ev.Content,
+	))
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if !approved {
+		return nil, apps.APDUCommandNotAllowed, fmt.Errorf("event signature refused by the user")
+	}
+
+	n.l.Infow("signing event", "kind", ev.Kind, "id", hex.EncodeToString(id))
+
+	sig, err := n.Token.SignDigest(dp, crypto.AlgoSecp256K1Schnorr, id)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return append(id, sig...), apps.APDUSuccess, nil
+}
+
+// eventID returns the NIP-01 id of ev, as created by pubkey.
+func eventID(pubkey []byte, ev event) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, `[0,"%s",%d,%d,[`, hex.EncodeToString(pubkey), ev.CreatedAt, ev.Kind)
+	for i, tag := range ev.Tags {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.WriteByte('[')
+		for j, v := range tag {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+
+			writeString(buf, v)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteString("],")
+	writeString(buf, ev.Content)
+	buf.WriteByte(']')
+
+	id := sha256.Sum256(buf.Bytes())
+	return id[:]
+}
+
+// writeString writes s as a JSON string, escaped as mandated by NIP-01.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\n':
+			buf.WriteString(`\n`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
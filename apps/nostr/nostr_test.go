@@ -0,0 +1,128 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/appstest"
+	"github.com/wallera-computer/wallera/crypto"
+)
+
+// NIP-06 test vector.
+const (
+	testMnemonic = "leader monkey parrot ring guide accident before fence cannon height naive bean"
+	testPubkey   = "17162c921dc4d2518f9a101db33695df1afb56ab82f5ff3e5da6eec3ca5cd917"
+	testNpub     = "npub1zutzeysacnf9rru6zqwmxd54mud0k44tst6l70ja5mhv8jjumytsd2x7nu"
+)
+
+func newTestNostr(t *testing.T) *Nostr {
+	t.Helper()
+
+	n := &Nostr{
+		Confirm: apps.ConfirmFunc(func(prompt string) (bool, error) {
+			return true, nil
+		}),
+	}
+	appstest.SetToken(n, appstest.TokenFromMnemonic(t, testMnemonic))
+
+	return n
+}
+
+func account(index uint32) []byte {
+	ret := make([]byte, 4)
+	binary.LittleEndian.PutUint32(ret, index)
+
+	return ret
+}
+
+func TestGetPublicKey(t *testing.T) {
+	n := newTestNostr(t)
+
+	response := appstest.Run(t, n, byte(claGetPublicKey), 0x00, 0x00, account(0))
+	require.Equal(t, testPubkey, hex.EncodeToString(response[:32]))
+	require.Equal(t, testNpub, string(response[32:]))
+}
+
+// referenceID returns the NIP-01 id of ev, serialized by encoding/json: it matches NIP-01 for
+// contents without control characters other than the ones NIP-01 escapes, and without U+2028 or U+2029.
+func referenceID(t *testing.T, pubkey string, ev event) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	require.NoError(t, enc.Encode([]interface{}{0, pubkey, ev.CreatedAt, ev.Kind, ev.Tags, ev.Content}))
+
+	id := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return id[:]
+}
+
+func TestSignEvent(t *testing.T) {
+	n := newTestNostr(t)
+
+	ev := event{
+		CreatedAt: 1673347337,
+		Kind:      1,
+		Tags: [][]string{
+			{"e", "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36", "wss://nostr.example.com"},
+			{"p", "f7234bd4c1394dda46d09f35bd384dd30cc552ad5541990f98844fb06676e9ca"},
+		},
+		Content: "Walled <gardens> & \"quotes\",\nback\\slashes\ttabs and ünïcödé ⚡",
+	}
+
+	raw, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	appstest.Run(t, n, byte(claSignEvent), byte(signInit), 0x00, account(0))
+	appstest.Run(t, n, byte(claSignEvent), byte(signAdd), 0x00, raw[:len(raw)/2])
+	response := appstest.Run(t, n, byte(claSignEvent), byte(signLast), 0x00, raw[len(raw)/2:])
+	require.Len(t, response, 32+64)
+
+	id, sig := response[:32], response[32:]
+	require.Equal(t, referenceID(t, testPubkey, ev), id)
+
+	pubkey, err := hex.DecodeString(testPubkey)
+	require.NoError(t, err)
+	require.True(t, crypto.VerifySchnorr(pubkey, id, sig))
+
+	// events without tags are serialized with an empty tag list
+	raw = []byte(`{"created_at":1673347337,"kind":1,"content":"hello"}`)
+
+	appstest.Run(t, n, byte(claSignEvent), byte(signInit), 0x00, account(0))
+	response = appstest.Run(t, n, byte(claSignEvent), byte(signLast), 0x00, raw)
+	require.Equal(t, referenceID(t, testPubkey, event{CreatedAt: 1673347337, Kind: 1, Tags: [][]string{}, Content: "hello"}), response[:32])
+	require.True(t, crypto.VerifySchnorr(pubkey, response[:32], response[32:]))
+}
+
+func TestSignEventConfirmation(t *testing.T) {
+	n := newTestNostr(t)
+	raw := []byte(`{"created_at":1673347337,"kind":1,"tags":[["p","f7234bd4"]],"content":"hello"}`)
+
+	sign := func() apps.APDUCode {
+		appstest.Run(t, n, byte(claSignEvent), byte(signInit), 0x00, account(0))
+
+		_, code, _ := appstest.Handle(n, byte(claSignEvent), byte(signLast), 0x00, raw)
+		return code
+	}
+
+	var prompt string
+	n.Confirm = apps.ConfirmFunc(func(p string) (bool, error) {
+		prompt = p
+		return false, nil
+	})
+
+	require.Equal(t, apps.APDUCommandNotAllowed, sign())
+	require.Contains(t, prompt, "kind 1")
+	require.Contains(t, prompt, "with 1 tags")
+	require.Contains(t, prompt, `"hello"`)
+
+	// without a way to ask the user, events are never signed
+	n.Confirm = nil
+	require.Equal(t, apps.APDUCommandNotAllowed, sign())
+}
@@ -0,0 +1,557 @@
+package oath
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/hsanjuan/go-nfctype4/apdu"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/tlv"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/storage"
+	"go.uber.org/zap"
+)
+
+//go:generate stringer -type command
+type command byte
+
+const (
+	appName      = "OATH"
+	appID   byte = apps.ISOAppID
+
+	insPut          command = 0x01
+	insDelete       command = 0x02
+	insSetCode      command = 0x03
+	insList         command = 0xA1
+	insCalculate    command = 0xA2
+	insValidate     command = 0xA3
+	insCalculateAll command = 0xA4 // shares INS with SELECT, told apart by P1
+)
+
+// Status words used by the YubiOATH protocol.
+const (
+	swAuthRequired apps.APDUCode = 0x6982
+	swNoSuchObject apps.APDUCode = 0x6984
+	swWrongSyntax  apps.APDUCode = 0x6A80
+	swNoSpace      apps.APDUCode = 0x6A84
+)
+
+// Data object tags.
+const (
+	tagName         uint16 = 0x71
+	tagNameList     uint16 = 0x72
+	tagKey          uint16 = 0x73
+	tagChallenge    uint16 = 0x74
+	tagResponse     uint16 = 0x75
+	tagTruncated    uint16 = 0x76
+	tagHOTP         uint16 = 0x77
+	tagProperty     uint16 = 0x78
+	tagVersion      uint16 = 0x79
+	tagIMF          uint16 = 0x7A
+	tagAlgorithm    uint16 = 0x7B
+	tagTouchRequire uint16 = 0x7C
+)
+
+// Credential types and hash algorithms, packed together in the first byte of the key tag.
+const (
+	typeMask byte = 0xF0
+	typeHOTP byte = 0x10
+	typeTOTP byte = 0x20
+
+	algoMask   byte = 0x0F
+	algoSHA1   byte = 0x01
+	algoSHA256 byte = 0x02
+	algoSHA512 byte = 0x03
+)
+
+const (
+	propertyRequireTouch byte = 0x02
+
+	selectByName byte = 0x04
+
+	// P2 value which requests a truncated response in CALCULATE and CALCULATE_ALL.
+	truncateResponse byte = 0x01
+
+	maxNameLength  = 64
+	maxCredentials = 32
+
+	saltSize      = 8
+	challengeSize = 8
+)
+
+var (
+	aid = []byte{0xA0, 0x00, 0x00, 0x05, 0x27, 0x21, 0x01}
+
+	version = []byte{0x05, 0x04, 0x03}
+)
+
+// OATH implements the YubiOATH HOTP and TOTP authenticator application.
+// Credentials are kept in Storage, encrypted with a key derived by Token.
+// TOTP challenges are computed by the host, the device has no notion of time.
+type OATH struct {
+	// Token is set by apps.Handler.Register, restricted to KeyScope.
+	Token   crypto.Token
+	Storage storage.Storage
+
+	// Confirm asks the user to approve the calculation of credentials which require touch, standing
+	// for the user presence check.
+	// When nil, those credentials are never calculated.
+	Confirm apps.Confirmer
+
+	validated bool
+
+	// challenge is sent to the host on SELECT, and must be answered through VALIDATE.
+	challenge []byte
+
+	// TODO: figure out how to better handle logger instance
+	l *zap.SugaredLogger
+}
+
+func (o *OATH) initLog() {
+	if o.l != nil {
+		return
+	}
+
+	o.l = log.Development(
+		zap.Fields(zap.String("app_name", o.Name())),
+	).Sugar()
+}
+
+// Name implements the apps.App interface
+func (o *OATH) Name() string {
+	return appName
+}
+
+// ID implements the apps.App interface
+func (o *OATH) ID() byte {
+	return appID
+}
+
+// KeyScope implements the apps.KeyUser interface
+func (o *OATH) KeyScope() crypto.ScopeID {
+	return crypto.ScopeOATH
+}
+
+// SetToken implements the apps.KeyUser interface
+func (o *OATH) SetToken(t crypto.Token) {
+	o.Token = t
+}
+
+// AID implements the apps.Applet interface
+func (o *OATH) AID() []byte {
+	return aid
+}
+
+// Commands implements the apps.App interface
+func (o *OATH) Commands() (commandIDs []byte) {
+	ret := []byte{
+		byte(insPut),
+		byte(insDelete),
+		byte(insSetCode),
+		byte(insList),
+		byte(insCalculate),
+		byte(insValidate),
+		byte(insCalculateAll),
+	}
+
+	return ret
+}
+
+// Handle implements the apps.App interface
+func (o *OATH) Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error) {
+	o.initLog()
+
+	c := apdu.CAPDU{}
+	if _, err := c.Unmarshal(data); err != nil {
+		return nil, apps.APDUWrongLength, fmt.Errorf("cannot unmarshal command apdu, %w", err)
+	}
+
+	st, err := loadState(o.Storage, o.Token)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if err := o.ensureSalt(&st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if command(cmd) == insCalculateAll && c.P1 == selectByName {
+		o.l.Debugw("handling command", "name", "SELECT")
+		return o.handleSelect(st)
+	}
+
+	o.l.Debugw("handling command", "name", command(cmd).String())
+
+	if st.AccessKey != nil && !o.validated && command(cmd) != insValidate {
+		return nil, swAuthRequired, fmt.Errorf("access code validation required")
+	}
+
+	switch command(cmd) {
+	case insPut:
+		return o.handlePut(c, &st)
+	case insDelete:
+		return o.handleDelete(c, &st)
+	case insSetCode:
+		return o.handleSetCode(c, &st)
+	case insList:
+		return o.handleList(st)
+	case insCalculate:
+		return o.handleCalculate(c, &st)
+	case insValidate:
+		return o.handleValidate(c, st)
+	case insCalculateAll:
+		return o.handleCalculateAll(c, st)
+	default:
+		return nil, apps.APDUINSNotSupported, fmt.Errorf("command not found")
+	}
+}
+
+// ensureSalt assigns a random device salt the first time the application is used.
+func (o *OATH) ensureSalt(st *state) error {
+	if st.Salt != nil {
+		return nil
+	}
+
+	salt, err := o.Token.RandomBytes(saltSize)
+	if err != nil {
+		return err
+	}
+
+	st.Salt = salt
+
+	return saveState(o.Storage, o.Token, *st)
+}
+
+func (o *OATH) handleSelect(st state) (response []byte, code apps.APDUCode, err error) {
+	// selecting the application resets the access status
+	o.validated = false
+	o.challenge = nil
+
+	buf := &bytes.Buffer{}
+	buf.Write(tlv.Encode(tagVersion, version))
+	buf.Write(tlv.Encode(tagName, st.Salt))
+
+	if st.AccessKey != nil {
+		challenge, err := o.Token.RandomBytes(challengeSize)
+		if err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		o.challenge = challenge
+
+		buf.Write(tlv.Encode(tagChallenge, challenge))
+		buf.Write(tlv.Encode(tagAlgorithm, []byte{st.AccessAlgorithm}))
+	}
+
+	return buf.Bytes(), apps.APDUSuccess, nil
+}
+
+func (o *OATH) handlePut(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	name, err := tlv.Find(c.Data, tagName)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	if len(name) == 0 || len(name) > maxNameLength {
+		return nil, swWrongSyntax, fmt.Errorf("credential name length %v not valid", len(name))
+	}
+
+	key, err := tlv.Find(c.Data, tagKey)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	// type|algorithm || digits || secret
+	if len(key) < 2 {
+		return nil, swWrongSyntax, fmt.Errorf("credential key too short")
+	}
+
+	cred := credential{
+		Name:      string(name),
+		Type:      key[0] & typeMask,
+		Algorithm: key[0] & algoMask,
+		Digits:    key[1],
+		Secret:    append([]byte{}, key[2:]...),
+	}
+
+	if cred.Type != typeHOTP && cred.Type != typeTOTP {
+		return nil, swWrongSyntax, fmt.Errorf("credential type %X not supported", cred.Type)
+	}
+
+	if hashFunc(cred.Algorithm) == nil {
+		return nil, swWrongSyntax, fmt.Errorf("credential algorithm %X not supported", cred.Algorithm)
+	}
+
+	if cred.Digits < 6 || cred.Digits > 10 {
+		return nil, swWrongSyntax, fmt.Errorf("credential digits %v not supported", cred.Digits)
+	}
+
+	// property and IMF are optional, and follow the key as plain tag-value pairs
+	rest := c.Data
+	for len(rest) > 0 {
+		if rest[0] == byte(tagProperty) && len(rest) >= 2 {
+			cred.Touch = rest[1]&propertyRequireTouch != 0
+			rest = rest[2:]
+			continue
+		}
+
+		t, r, err := tlv.Decode(rest)
+		if err != nil {
+			return nil, swWrongSyntax, err
+		}
+
+		if t.Tag == tagIMF {
+			if len(t.Value) != 4 {
+				return nil, swWrongSyntax, fmt.Errorf("imf must be 4 bytes long")
+			}
+
+			cred.Counter = uint64(binary.BigEndian.Uint32(t.Value))
+		}
+
+		rest = r
+	}
+
+	if idx, found := st.find(cred.Name); found {
+		st.Credentials[idx] = cred
+	} else {
+		if len(st.Credentials) >= maxCredentials {
+			return nil, swNoSpace, fmt.Errorf("no space left for credential %v", cred.Name)
+		}
+
+		st.Credentials = append(st.Credentials, cred)
+	}
+
+	o.l.Debugw("stored credential", "name", cred.Name, "touch", cred.Touch)
+
+	if err := saveState(o.Storage, o.Token, *st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleDelete(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	name, err := tlv.Find(c.Data, tagName)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	idx, found := st.find(string(name))
+	if !found {
+		return nil, swNoSuchObject, fmt.Errorf("credential %v not found", string(name))
+	}
+
+	st.Credentials = append(st.Credentials[:idx], st.Credentials[idx+1:]...)
+
+	if err := saveState(o.Storage, o.Token, *st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleSetCode(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	key, err := tlv.Find(c.Data, tagKey)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	// an empty key removes the access code
+	if len(key) == 0 {
+		st.AccessKey = nil
+		st.AccessAlgorithm = 0
+		o.validated = false
+
+		if err := saveState(o.Storage, o.Token, *st); err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		return nil, apps.APDUSuccess, nil
+	}
+
+	algorithm := key[0] & algoMask
+	if hashFunc(algorithm) == nil {
+		return nil, swWrongSyntax, fmt.Errorf("access code algorithm %X not supported", algorithm)
+	}
+
+	challenge, err := tlv.Find(c.Data, tagChallenge)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	resp, err := tlv.Find(c.Data, tagResponse)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	// the host proves it knows the new key by answering its own challenge
+	if !hmac.Equal(resp, mac(algorithm, key[1:], challenge)) {
+		return nil, swWrongSyntax, fmt.Errorf("access code response doesn't match")
+	}
+
+	st.AccessKey = append([]byte{}, key[1:]...)
+	st.AccessAlgorithm = algorithm
+	o.validated = false
+
+	if err := saveState(o.Storage, o.Token, *st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleList(st state) (response []byte, code apps.APDUCode, err error) {
+	buf := &bytes.Buffer{}
+	for _, c := range st.Credentials {
+		buf.Write(tlv.Encode(tagNameList, append([]byte{c.Type | c.Algorithm}, c.Name...)))
+	}
+
+	return buf.Bytes(), apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleValidate(c apdu.CAPDU, st state) (response []byte, code apps.APDUCode, err error) {
+	if st.AccessKey == nil || o.challenge == nil {
+		return nil, swAuthRequired, fmt.Errorf("no access code challenge pending")
+	}
+
+	resp, err := tlv.Find(c.Data, tagResponse)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	challenge, err := tlv.Find(c.Data, tagChallenge)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	expected := mac(st.AccessAlgorithm, st.AccessKey, o.challenge)
+
+	// a challenge can be answered only once
+	o.challenge = nil
+
+	if !hmac.Equal(resp, expected) {
+		o.validated = false
+		return nil, swWrongSyntax, fmt.Errorf("wrong access code response")
+	}
+
+	o.validated = true
+
+	return tlv.Encode(tagResponse, mac(st.AccessAlgorithm, st.AccessKey, challenge)), apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleCalculate(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	name, err := tlv.Find(c.Data, tagName)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	challenge, err := tlv.Find(c.Data, tagChallenge)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	idx, found := st.find(string(name))
+	if !found {
+		return nil, swNoSuchObject, fmt.Errorf("credential %v not found", string(name))
+	}
+
+	cred := &st.Credentials[idx]
+
+	if cred.Touch {
+		if o.Confirm == nil {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf("no way to ask for user presence")
+		}
+
+		approved, err := o.Confirm.Confirm(fmt.Sprintf("Calculate the OTP of %v?", cred.Name))
+		if err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		if !approved {
+			return nil, apps.APDUCommandNotAllowed, fmt.Errorf("calculation refused by the user")
+		}
+	}
+
+	// HOTP credentials use their own counter as challenge, which moves at every calculation
+	if cred.Type == typeHOTP {
+		challenge = make([]byte, 8)
+		binary.BigEndian.PutUint64(challenge, cred.Counter)
+
+		cred.Counter++
+		if err := saveState(o.Storage, o.Token, *st); err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+	}
+
+	return calculate(*cred, challenge, c.P2 == truncateResponse), apps.APDUSuccess, nil
+}
+
+func (o *OATH) handleCalculateAll(c apdu.CAPDU, st state) (response []byte, code apps.APDUCode, err error) {
+	challenge, err := tlv.Find(c.Data, tagChallenge)
+	if err != nil {
+		return nil, swWrongSyntax, err
+	}
+
+	buf := &bytes.Buffer{}
+	for _, cred := range st.Credentials {
+		buf.Write(tlv.Encode(tagName, []byte(cred.Name)))
+
+		// HOTP and touch credentials must be calculated one by one through CALCULATE
+		switch {
+		case cred.Type == typeHOTP:
+			buf.Write(tlv.Encode(tagHOTP, []byte{cred.Digits}))
+		case cred.Touch:
+			buf.Write(tlv.Encode(tagTouchRequire, []byte{cred.Digits}))
+		default:
+			buf.Write(calculate(cred, challenge, c.P2 == truncateResponse))
+		}
+	}
+
+	return buf.Bytes(), apps.APDUSuccess, nil
+}
+
+// calculate returns the encoded response of cred to challenge, either as full HMAC or as
+// RFC 4226 dynamically truncated value.
+func calculate(cred credential, challenge []byte, truncate bool) []byte {
+	sum := mac(cred.Algorithm, cred.Secret, challenge)
+
+	if !truncate {
+		return tlv.Encode(tagResponse, append([]byte{cred.Digits}, sum...))
+	}
+
+	offset := sum[len(sum)-1] & 0x0F
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+
+	ret := make([]byte, 5)
+	ret[0] = cred.Digits
+	binary.BigEndian.PutUint32(ret[1:], value)
+
+	return tlv.Encode(tagTruncated, ret)
+}
+
+// hashFunc returns the hash function identified by algorithm, or nil if not supported.
+func hashFunc(algorithm byte) func() hash.Hash {
+	switch algorithm {
+	case algoSHA1:
+		return sha1.New
+	case algoSHA256:
+		return sha256.New
+	case algoSHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+func mac(algorithm byte, key, data []byte) []byte {
+	h := hmac.New(hashFunc(algorithm), key)
+	h.Write(data)
+	return h.Sum(nil)
+}
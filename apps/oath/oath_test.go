@@ -0,0 +1,153 @@
+package oath
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/appstest"
+	"github.com/wallera-computer/wallera/apps/tlv"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// RFC 4226 and RFC 6238 test secrets.
+var (
+	secretSHA1   = []byte("12345678901234567890")
+	secretSHA256 = []byte("12345678901234567890123456789012")
+	secretSHA512 = []byte(strings.Repeat("1234567890", 6) + "1234")
+)
+
+func newTestOATH(t *testing.T) *OATH {
+	t.Helper()
+
+	o := &OATH{
+		Storage: storage.NewMemory(),
+	}
+	appstest.SetToken(o, appstest.Token(t))
+
+	return o
+}
+
+func put(t *testing.T, o *OATH, name string, kind, algorithm, digits byte, secret []byte) {
+	t.Helper()
+
+	key := append([]byte{kind | algorithm, digits}, secret...)
+	appstest.Run(t, o, byte(insPut), 0x00, 0x00, append(tlv.Encode(tagName, []byte(name)), tlv.Encode(tagKey, key)...))
+}
+
+// code returns the OTP of a truncated response.
+func code(t *testing.T, response []byte) uint32 {
+	t.Helper()
+
+	value, err := tlv.Find(response, tagTruncated)
+	require.NoError(t, err)
+	require.Len(t, value, 5)
+
+	modulo := uint32(1)
+	for i := byte(0); i < value[0]; i++ {
+		modulo *= 10
+	}
+
+	return binary.BigEndian.Uint32(value[1:]) % modulo
+}
+
+func TestCalculateHOTP(t *testing.T) {
+	o := newTestOATH(t)
+	put(t, o, "hotp", typeHOTP, algoSHA1, 6, secretSHA1)
+
+	// RFC 4226 appendix D, the counter moves at every calculation
+	expected := []uint32{755224, 287082, 359152, 969429, 338314, 254676, 287922, 162583, 399871, 520489}
+	for _, otp := range expected {
+		// HOTP challenges are left empty by hosts
+		data := append(tlv.Encode(tagName, []byte("hotp")), tlv.Encode(tagChallenge, nil)...)
+
+		response := appstest.Run(t, o, byte(insCalculate), 0x00, truncateResponse, data)
+		require.Equal(t, otp, code(t, response))
+	}
+
+	// CALCULATE_ALL leaves HOTP credentials out
+	response := appstest.Run(t, o, byte(insCalculateAll), 0x00, truncateResponse, tlv.Encode(tagChallenge, make([]byte, 8)))
+	require.True(t, bytes.HasSuffix(response, tlv.Encode(tagHOTP, []byte{6})))
+}
+
+func TestCalculateTOTP(t *testing.T) {
+	o := newTestOATH(t)
+	put(t, o, "sha1", typeTOTP, algoSHA1, 8, secretSHA1)
+	put(t, o, "sha256", typeTOTP, algoSHA256, 8, secretSHA256)
+	put(t, o, "sha512", typeTOTP, algoSHA512, 8, secretSHA512)
+
+	// RFC 6238 appendix B, with a 30 seconds step
+	tests := []struct {
+		time   uint64
+		sha1   uint32
+		sha256 uint32
+		sha512 uint32
+	}{
+		{59, 94287082, 46119246, 90693936},
+		{1111111109, 7081804, 68084774, 25091201},
+		{1111111111, 14050471, 67062674, 99943326},
+		{1234567890, 89005924, 91819424, 93441116},
+		{2000000000, 69279037, 90698825, 38618901},
+		{20000000000, 65353130, 77737706, 47863826},
+	}
+	for _, tt := range tests {
+		challenge := make([]byte, 8)
+		binary.BigEndian.PutUint64(challenge, tt.time/30)
+
+		for name, otp := range map[string]uint32{"sha1": tt.sha1, "sha256": tt.sha256, "sha512": tt.sha512} {
+			data := append(tlv.Encode(tagName, []byte(name)), tlv.Encode(tagChallenge, challenge)...)
+
+			response := appstest.Run(t, o, byte(insCalculate), 0x00, truncateResponse, data)
+			require.Equal(t, otp, code(t, response), "%v at %v", name, tt.time)
+		}
+
+		// CALCULATE_ALL answers every TOTP credential, in order
+		response := appstest.Run(t, o, byte(insCalculateAll), 0x00, truncateResponse, tlv.Encode(tagChallenge, challenge))
+		for _, otp := range []uint32{tt.sha1, tt.sha256, tt.sha512} {
+			_, rest, err := tlv.Decode(response)
+			require.NoError(t, err)
+
+			require.Equal(t, otp, code(t, rest))
+
+			_, response, err = tlv.Decode(rest)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestCalculateTouch(t *testing.T) {
+	o := newTestOATH(t)
+
+	key := append([]byte{typeTOTP | algoSHA1, 8}, secretSHA1...)
+	data := append(tlv.Encode(tagName, []byte("touch")), tlv.Encode(tagKey, key)...)
+	appstest.Run(t, o, byte(insPut), 0x00, 0x00, append(data, byte(tagProperty), propertyRequireTouch))
+
+	calculate := append(tlv.Encode(tagName, []byte("touch")), tlv.Encode(tagChallenge, []byte{0, 0, 0, 0, 0, 0, 0, 1})...)
+
+	// without a way to ask the user, touch credentials are never calculated
+	_, status, _ := appstest.Handle(o, byte(insCalculate), 0x00, truncateResponse, calculate)
+	require.Equal(t, apps.APDUCommandNotAllowed, status)
+
+	var prompts []string
+	approve := false
+	o.Confirm = apps.ConfirmFunc(func(prompt string) (bool, error) {
+		prompts = append(prompts, prompt)
+		return approve, nil
+	})
+
+	_, status, _ = appstest.Handle(o, byte(insCalculate), 0x00, truncateResponse, calculate)
+	require.Equal(t, apps.APDUCommandNotAllowed, status)
+
+	approve = true
+	response := appstest.Run(t, o, byte(insCalculate), 0x00, truncateResponse, calculate)
+	require.Equal(t, uint32(94287082), code(t, response))
+	require.Len(t, prompts, 2)
+
+	// CALCULATE_ALL leaves them out, without asking the user
+	response = appstest.Run(t, o, byte(insCalculateAll), 0x00, truncateResponse, tlv.Encode(tagChallenge, make([]byte, 8)))
+	require.True(t, bytes.HasSuffix(response, tlv.Encode(tagTouchRequire, []byte{8})))
+	require.Len(t, prompts, 2)
+}
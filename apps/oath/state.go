@@ -0,0 +1,142 @@
+package oath
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	stateKeyPrefix = "oath/state/"
+
+	// keyCoinType is used to derive the state encryption key at m/44'/keyCoinType'/0'/0/0.
+	keyCoinType = 0x4F415448 // "OATH"
+
+	nonceSize = 12
+)
+
+// numsPoint is the secp256k1 point H from BIP-341, whose discrete logarithm is unknown:
+// the shared secret between it and a Token key can only be computed by the Token itself.
+var numsPoint, _ = hex.DecodeString("0250929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0")
+
+// credential is an OATH credential, as written by the host through PUT.
+type credential struct {
+	Name      string
+	Type      byte
+	Algorithm byte
+	Digits    byte
+	Secret    []byte
+	Touch     bool
+
+	// Counter is the moving factor of HOTP credentials.
+	Counter uint64
+}
+
+// state holds everything the OATH application must remember across reboots.
+type state struct {
+	// Salt identifies the device towards the host, and salts the access code derivation.
+	Salt []byte
+
+	// AccessKey is the HMAC key protecting the application, if any.
+	AccessKey       []byte
+	AccessAlgorithm byte
+
+	Credentials []credential
+}
+
+func (st *state) find(name string) (int, bool) {
+	for i, c := range st.Credentials {
+		if c.Name == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// encryptionKey returns the AES-256 key state is encrypted with, derived from the Token master key.
+func encryptionKey(t crypto.Token) ([]byte, error) {
+	return t.ECDH(crypto.BIP44Path(keyCoinType, 0, 0, 0), crypto.AlgoSecp256K1, crypto.KDF{Algorithm: crypto.KDFSHA256}, numsPoint)
+}
+
+// newAEAD returns the cipher state is encrypted with, along with the storage key it's kept under.
+// Each wallet, as selected by the BIP-39 passphrase, has its own state, whose storage key is
+// derived from the encryption key so that it doesn't tell which wallets exist on the device.
+func newAEAD(t crypto.Token) (cipher.AEAD, string, error) {
+	key, err := encryptionKey(t)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot derive oath state key, %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := sha256.Sum256(append([]byte(stateKeyPrefix), key...))
+
+	return aead, stateKeyPrefix + hex.EncodeToString(id[:8]), nil
+}
+
+func loadState(s storage.Storage, t crypto.Token) (state, error) {
+	aead, stateKey, err := newAEAD(t)
+	if err != nil {
+		return state{}, err
+	}
+
+	raw, err := s.Get(stateKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return state{}, nil
+	}
+
+	if err != nil {
+		return state{}, fmt.Errorf("cannot read oath state, %w", err)
+	}
+
+	if len(raw) < nonceSize {
+		return state{}, fmt.Errorf("oath state is too short")
+	}
+
+	plain, err := aead.Open(nil, raw[:nonceSize], raw[nonceSize:], []byte(stateKey))
+	if err != nil {
+		return state{}, fmt.Errorf("cannot decrypt oath state, %w", err)
+	}
+
+	st := state{}
+	if err := json.Unmarshal(plain, &st); err != nil {
+		return state{}, fmt.Errorf("cannot unmarshal oath state, %w", err)
+	}
+
+	return st, nil
+}
+
+func saveState(s storage.Storage, t crypto.Token, st state) error {
+	plain, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("cannot marshal oath state, %w", err)
+	}
+
+	aead, stateKey, err := newAEAD(t)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := t.RandomBytes(nonceSize)
+	if err != nil {
+		return err
+	}
+
+	return s.Set(stateKey, aead.Seal(nonce, nonce, plain, []byte(stateKey)))
+}
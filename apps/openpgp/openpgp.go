@@ -0,0 +1,691 @@
+package openpgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hsanjuan/go-nfctype4/apdu"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/tlv"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/storage"
+	"go.uber.org/zap"
+)
+
+//go:generate stringer -type command
+type command byte
+
+const (
+	appName      = "OPENPGP"
+	appID   byte = apps.ISOAppID
+
+	insVerify              command = 0x20
+	insChangeReferenceData command = 0x24
+	insPSO                 command = 0x2A
+	insResetRetryCounter   command = 0x2C
+	insGenerateKeyPair     command = 0x47
+	insSelect              command = 0xA4
+	insGetData             command = 0xCA
+	insPutData             command = 0xDA
+)
+
+// ISO 7816-4 status words used by the OpenPGP card specification.
+const (
+	swVerifyFailed               apps.APDUCode = 0x63C0 // low nibble holds the remaining tries
+	swSecurityStatusNotSatisfied apps.APDUCode = 0x6982
+	swAuthenticationBlocked      apps.APDUCode = 0x6983
+	swConditionsNotSatisfied     apps.APDUCode = 0x6985
+	swWrongData                  apps.APDUCode = 0x6A80
+	swFileNotFound               apps.APDUCode = 0x6A82
+	swReferencedDataNotFound     apps.APDUCode = 0x6A88
+	swWrongParameters            apps.APDUCode = 0x6B00
+)
+
+// Password references, as found in VERIFY P2.
+const (
+	pw1Signature byte = 0x81
+	pw1Other     byte = 0x82
+	pw3          byte = 0x83
+)
+
+const (
+	// coinType is used to derive OpenPGP keys at m/44'/coinType'/slot'/0/generation.
+	coinType = 0x504750 // "PGP"
+
+	// maxAPDULength is advertised as both maximum command and response length.
+	maxAPDULength = 0x0800
+)
+
+var (
+	// rid || application || version 3.4 || manufacturer 0xFFFE (test card)
+	aidPrefix = []byte{0xD2, 0x76, 0x00, 0x01, 0x24, 0x01, 0x03, 0x04, 0xFF, 0xFE}
+
+	// category indicator || card capabilities (extended Lc/Le) || status indicator
+	historicalBytes = []byte{0x00, 0x73, 0x00, 0x00, 0x40, 0x05, 0x90, 0x00}
+
+	// no secure messaging, no key import, no changeable algorithm attributes,
+	// 255 bytes special data objects
+	extendedCapabilities = []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0x00, 0x00}
+
+	// ECDSA and ECDH over secp256k1, OID 1.3.132.0.10
+	ecdsaSecp256K1Attributes = []byte{0x13, 0x2B, 0x81, 0x04, 0x00, 0x0A}
+	ecdhSecp256K1Attributes  = []byte{0x12, 0x2B, 0x81, 0x04, 0x00, 0x0A}
+)
+
+// putDataMaxLength maps data objects writable through PUT DATA to their maximum length.
+var putDataMaxLength = map[uint16]int{
+	0x5B:   39,  // name
+	0x5E:   254, // login data
+	0x5F2D: 8,   // language preferences
+	0x5F35: 1,   // sex
+	0x5F50: 254, // url
+	0xC4:   1,   // PW1 status
+	0xC7:   20,  // signature key fingerprint
+	0xC8:   20,  // decryption key fingerprint
+	0xC9:   20,  // authentication key fingerprint
+	0xCA:   20,  // CA fingerprints
+	0xCB:   20,
+	0xCC:   20,
+	0xCE:   4, // signature key generation timestamp
+	0xCF:   4, // decryption key generation timestamp
+	0xD0:   4, // authentication key generation timestamp
+}
+
+// OpenPGP implements the OpenPGP card application, version 3.4.
+// Keys are derived and held by Token, OpenPGP-specific state is kept in Storage.
+type OpenPGP struct {
+	// Token is set by apps.Handler.Register, restricted to KeyScope.
+	Token   crypto.Token
+	Storage storage.Storage
+
+	verified map[byte]bool
+
+	// TODO: figure out how to better handle logger instance
+	l *zap.SugaredLogger
+}
+
+func (o *OpenPGP) initLog() {
+	if o.l != nil {
+		return
+	}
+
+	o.l = log.Development(
+		zap.Fields(zap.String("app_name", o.Name())),
+	).Sugar()
+}
+
+// Name implements the apps.App interface
+func (o *OpenPGP) Name() string {
+	return appName
+}
+
+// ID implements the apps.App interface
+func (o *OpenPGP) ID() byte {
+	return appID
+}
+
+// KeyScope implements the apps.KeyUser interface
+func (o *OpenPGP) KeyScope() crypto.ScopeID {
+	return crypto.ScopeOpenPGP
+}
+
+// SetToken implements the apps.KeyUser interface
+func (o *OpenPGP) SetToken(t crypto.Token) {
+	o.Token = t
+}
+
+// AID implements the apps.Applet interface
+func (o *OpenPGP) AID() []byte {
+	return aidPrefix[:6]
+}
+
+// Commands implements the apps.App interface
+func (o *OpenPGP) Commands() (commandIDs []byte) {
+	ret := []byte{
+		byte(insVerify),
+		byte(insChangeReferenceData),
+		byte(insPSO),
+		byte(insResetRetryCounter),
+		byte(insGenerateKeyPair),
+		byte(insSelect),
+		byte(insGetData),
+		byte(insPutData),
+	}
+
+	return ret
+}
+
+// Handle implements the apps.App interface
+func (o *OpenPGP) Handle(cmd byte, data []byte) (response []byte, code apps.APDUCode, err error) {
+	o.initLog()
+
+	if o.verified == nil {
+		o.verified = map[byte]bool{}
+	}
+
+	c := apdu.CAPDU{}
+	if _, err := c.Unmarshal(data); err != nil {
+		return nil, apps.APDUWrongLength, fmt.Errorf("cannot unmarshal command apdu, %w", err)
+	}
+
+	st, err := loadState(o.Storage)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	if err := o.ensureSerial(&st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	o.l.Debugw("handling command", "name", command(cmd).String())
+	switch command(cmd) {
+	case insSelect:
+		return o.handleSelect(c)
+	case insGetData:
+		return o.handleGetData(c, st)
+	case insVerify:
+		return o.handleVerify(c, &st)
+	case insChangeReferenceData:
+		return o.handleChangeReferenceData(c, &st)
+	case insResetRetryCounter:
+		return o.handleResetRetryCounter(c, &st)
+	case insPutData:
+		return o.handlePutData(c, &st)
+	case insGenerateKeyPair:
+		return o.handleGenerateKeyPair(c, &st)
+	case insPSO:
+		return o.handlePSO(c, &st)
+	default:
+		return nil, apps.APDUINSNotSupported, fmt.Errorf("command not found")
+	}
+}
+
+// ensureSerial assigns a random serial number to the card the first time it is used.
+func (o *OpenPGP) ensureSerial(st *state) error {
+	if st.Serial != 0 {
+		return nil
+	}
+
+	for st.Serial == 0 {
+		rb, err := o.Token.RandomBytes(4)
+		if err != nil {
+			return err
+		}
+
+		st.Serial = binary.BigEndian.Uint32(rb)
+	}
+
+	return saveState(o.Storage, *st)
+}
+
+func (o *OpenPGP) handleSelect(c apdu.CAPDU) (response []byte, code apps.APDUCode, err error) {
+	if c.P1 != 0x04 {
+		return nil, swWrongParameters, fmt.Errorf("select by %X not supported", c.P1)
+	}
+
+	if len(c.Data) < 6 || !bytes.Equal(c.Data[:6], aidPrefix[:6]) {
+		return nil, swFileNotFound, fmt.Errorf("aid %X not found", c.Data)
+	}
+
+	// selecting the application resets the access status
+	o.verified = map[byte]bool{}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OpenPGP) handleGetData(c apdu.CAPDU, st state) (response []byte, code apps.APDUCode, err error) {
+	tag := uint16(c.P1)<<8 | uint16(c.P2)
+
+	o.l.Debugw("get data", "tag", fmt.Sprintf("%X", tag))
+
+	do, found := dataObject(tag, st)
+	if !found {
+		return nil, swReferencedDataNotFound, fmt.Errorf("data object %X not found", tag)
+	}
+
+	return do, apps.APDUSuccess, nil
+}
+
+// dataObject returns the value of the data object identified by tag.
+func dataObject(tag uint16, st state) ([]byte, bool) {
+	concat := func(tags ...uint16) []byte {
+		buf := &bytes.Buffer{}
+		for _, t := range tags {
+			v, _ := dataObject(t, st)
+			buf.Write(v)
+		}
+
+		return buf.Bytes()
+	}
+
+	tlvs := func(tags ...uint16) []byte {
+		buf := &bytes.Buffer{}
+		for _, t := range tags {
+			v, _ := dataObject(t, st)
+			buf.Write(tlv.Encode(t, v))
+		}
+
+		return buf.Bytes()
+	}
+
+	switch tag {
+	case 0x4F:
+		aid := make([]byte, 16)
+		copy(aid, aidPrefix)
+		binary.BigEndian.PutUint32(aid[10:], st.Serial)
+		return aid, true
+	case 0x5F52:
+		return historicalBytes, true
+	case 0x7F66:
+		ext := make([]byte, 8)
+		copy(ext, []byte{0x02, 0x02})
+		binary.BigEndian.PutUint16(ext[2:], maxAPDULength)
+		copy(ext[4:], []byte{0x02, 0x02})
+		binary.BigEndian.PutUint16(ext[6:], maxAPDULength)
+		return ext, true
+	case 0xC0:
+		return extendedCapabilities, true
+	case 0xC1, 0xC3:
+		return ecdsaSecp256K1Attributes, true
+	case 0xC2:
+		return ecdhSecp256K1Attributes, true
+	case 0xC4:
+		return pwStatus(st), true
+	case 0xC5:
+		return concat(0xC7, 0xC8, 0xC9), true
+	case 0xC6:
+		return concat(0xCA, 0xCB, 0xCC), true
+	case 0xCD:
+		return concat(0xCE, 0xCF, 0xD0), true
+	case 0xDE:
+		info := []byte{}
+		for i, k := range st.Keys {
+			status := byte(0x00)
+			if k.Generated {
+				status = 0x01
+			}
+
+			info = append(info, byte(i+1), status)
+		}
+
+		return info, true
+	case 0x65:
+		return tlvs(0x5B, 0x5F2D, 0x5F35), true
+	case 0x6E:
+		discretionary := tlvs(0xC0, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xCD, 0xDE)
+
+		buf := &bytes.Buffer{}
+		buf.Write(tlvs(0x4F, 0x5F52, 0x7F66))
+		buf.Write(tlv.Encode(0x73, discretionary))
+		return buf.Bytes(), true
+	case 0x7A:
+		counter := make([]byte, 4)
+		binary.BigEndian.PutUint32(counter, st.SignatureCounter)
+		return tlv.Encode(0x93, counter[1:]), true
+	}
+
+	maxLen, writable := putDataMaxLength[tag]
+	if !writable {
+		return nil, false
+	}
+
+	if v, found := st.DataObjects[tag]; found {
+		return v, true
+	}
+
+	switch tag {
+	case 0xC7, 0xC8, 0xC9, 0xCA, 0xCB, 0xCC, 0xCE, 0xCF, 0xD0:
+		return make([]byte, maxLen), true
+	}
+
+	return []byte{}, true
+}
+
+// pwStatus returns the PW status bytes.
+func pwStatus(st state) []byte {
+	validity := byte(0x00)
+	if st.PW1ValidForMultipleSignatures {
+		validity = 0x01
+	}
+
+	return []byte{
+		validity,
+		0x7F, // PW1 maximum length, UTF-8 format
+		0x7F, // resetting code maximum length
+		0x7F, // PW3 maximum length
+		st.PW1Retries,
+		0x00, // resetting code is not supported
+		st.PW3Retries,
+	}
+}
+
+func (o *OpenPGP) handleVerify(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	ref := c.P2
+
+	pw, err := passwordFor(st, ref)
+	if err != nil {
+		return nil, swWrongParameters, err
+	}
+
+	switch {
+	case c.P1 == 0xFF:
+		o.verified[ref] = false
+		return nil, apps.APDUSuccess, nil
+	case c.P1 != 0x00:
+		return nil, swWrongParameters, fmt.Errorf("wrong verify P1 %X", c.P1)
+	}
+
+	// an empty VERIFY returns the verification status
+	if len(c.Data) == 0 {
+		if o.verified[ref] {
+			return nil, apps.APDUSuccess, nil
+		}
+
+		return nil, swVerifyFailed | apps.APDUCode(*pw.retries), nil
+	}
+
+	if code, err := o.checkPassword(st, ref, pw, c.Data); err != nil {
+		return nil, code, err
+	}
+
+	o.verified[ref] = true
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// checkPassword checks candidate against pw, referenced by ref, using up a retry if it doesn't match
+// and resetting the retry counter otherwise.
+func (o *OpenPGP) checkPassword(st *state, ref byte, pw password, candidate []byte) (apps.APDUCode, error) {
+	if *pw.retries == 0 {
+		return swAuthenticationBlocked, fmt.Errorf("password %X blocked", ref)
+	}
+
+	if !pw.matches(candidate) {
+		*pw.retries--
+		o.verified[ref] = false
+
+		if err := saveState(o.Storage, *st); err != nil {
+			return apps.APDUExecutionError, err
+		}
+
+		return swVerifyFailed | apps.APDUCode(*pw.retries), fmt.Errorf("wrong password for reference %X", ref)
+	}
+
+	*pw.retries = maxRetries
+
+	if err := saveState(o.Storage, *st); err != nil {
+		return apps.APDUExecutionError, err
+	}
+
+	return apps.APDUSuccess, nil
+}
+
+// setPassword replaces pw with newPW, salted with random bytes from the Token, and saves st.
+func (o *OpenPGP) setPassword(st *state, pw password, newPW []byte) (apps.APDUCode, error) {
+	salt, err := o.Token.RandomBytes(pwSaltSize)
+	if err != nil {
+		return apps.APDUExecutionError, err
+	}
+
+	if err := pw.set(newPW, salt); err != nil {
+		return swWrongData, err
+	}
+
+	if err := saveState(o.Storage, *st); err != nil {
+		return apps.APDUExecutionError, err
+	}
+
+	return apps.APDUSuccess, nil
+}
+
+// handleChangeReferenceData changes PW1 (P2 0x81) or PW3 (P2 0x83): the payload holds the current
+// password followed by the new one.
+func (o *OpenPGP) handleChangeReferenceData(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	if c.P1 != 0x00 || (c.P2 != pw1Signature && c.P2 != pw3) {
+		return nil, swWrongParameters, fmt.Errorf("wrong change reference data P1 %X P2 %X", c.P1, c.P2)
+	}
+
+	pw, err := passwordFor(st, c.P2)
+	if err != nil {
+		return nil, swWrongParameters, err
+	}
+
+	oldLength := pw.length()
+	if len(c.Data) <= oldLength {
+		// counts as a wrong password, so that it doesn't leak the password length
+		oldLength = len(c.Data)
+	}
+
+	if code, err := o.checkPassword(st, c.P2, pw, c.Data[:oldLength]); err != nil {
+		return nil, code, err
+	}
+
+	if code, err := o.setPassword(st, pw, c.Data[oldLength:]); err != nil {
+		return nil, code, err
+	}
+
+	o.l.Debugw("changed password", "reference", fmt.Sprintf("%X", c.P2))
+
+	return nil, apps.APDUSuccess, nil
+}
+
+// handleResetRetryCounter sets a new PW1, held in the payload, and resets its retry counter once PW3 has
+// been verified (P1 0x02).
+// Resetting codes aren't supported.
+func (o *OpenPGP) handleResetRetryCounter(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	if c.P2 != pw1Signature {
+		return nil, swWrongParameters, fmt.Errorf("wrong reset retry counter P2 %X", c.P2)
+	}
+
+	switch c.P1 {
+	case 0x00:
+		return nil, swSecurityStatusNotSatisfied, fmt.Errorf("resetting code is not supported")
+	case 0x02:
+		if !o.verified[pw3] {
+			return nil, swSecurityStatusNotSatisfied, fmt.Errorf("reset retry counter requires PW3")
+		}
+	default:
+		return nil, swWrongParameters, fmt.Errorf("wrong reset retry counter P1 %X", c.P1)
+	}
+
+	pw, err := passwordFor(st, pw1Signature)
+	if err != nil {
+		return nil, swWrongParameters, err
+	}
+
+	if code, err := o.setPassword(st, pw, c.Data); err != nil {
+		return nil, code, err
+	}
+
+	o.l.Debugw("reset PW1")
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OpenPGP) handlePutData(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	tag := uint16(c.P1)<<8 | uint16(c.P2)
+
+	if !o.verified[pw3] {
+		return nil, swSecurityStatusNotSatisfied, fmt.Errorf("put data requires PW3")
+	}
+
+	maxLen, writable := putDataMaxLength[tag]
+	if !writable {
+		return nil, swReferencedDataNotFound, fmt.Errorf("data object %X not writable", tag)
+	}
+
+	if len(c.Data) > maxLen {
+		return nil, apps.APDUWrongLength, fmt.Errorf("data object %X exceeds %v bytes", tag, maxLen)
+	}
+
+	o.l.Debugw("put data", "tag", fmt.Sprintf("%X", tag), "length", len(c.Data))
+
+	if tag == 0xC4 {
+		if len(c.Data) != 1 {
+			return nil, apps.APDUWrongLength, fmt.Errorf("PW status must be 1 byte long")
+		}
+
+		st.PW1ValidForMultipleSignatures = c.Data[0] == 0x01
+	} else {
+		st.DataObjects[tag] = append([]byte{}, c.Data...)
+	}
+
+	if err := saveState(o.Storage, *st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return nil, apps.APDUSuccess, nil
+}
+
+func (o *OpenPGP) handleGenerateKeyPair(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	crt, _, err := tlv.Decode(c.Data)
+	if err != nil {
+		return nil, swWrongData, err
+	}
+
+	slot, err := keySlotFromCRT(crt.Tag)
+	if err != nil {
+		return nil, swWrongData, err
+	}
+
+	switch c.P1 {
+	case 0x80:
+		if !o.verified[pw3] {
+			return nil, swSecurityStatusNotSatisfied, fmt.Errorf("key generation requires PW3")
+		}
+
+		key := &st.Keys[slot]
+		if key.Generated {
+			key.Generation++
+		}
+		key.Generated = true
+
+		// fingerprint and timestamp refer to the old key, the host will write new ones
+		delete(st.DataObjects, 0xC7+uint16(slot))
+		delete(st.DataObjects, 0xCE+uint16(slot))
+
+		if slot == slotSignature {
+			st.SignatureCounter = 0
+		}
+
+		if err := saveState(o.Storage, *st); err != nil {
+			return nil, apps.APDUExecutionError, err
+		}
+
+		o.l.Debugw("generated key", "slot", slot, "generation", key.Generation)
+	case 0x81:
+		if !st.Keys[slot].Generated {
+			return nil, swReferencedDataNotFound, fmt.Errorf("no key in slot %v", slot)
+		}
+	default:
+		return nil, swWrongParameters, fmt.Errorf("wrong generate key pair P1 %X", c.P1)
+	}
+
+	pubkey, err := o.Token.PublicKey(slotPath(slot, *st), crypto.AlgoSecp256K1)
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	pk, err := btcec.ParsePubKey(pubkey, btcec.S256())
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	return tlv.Encode(0x7F49, tlv.Encode(0x86, pk.SerializeUncompressed())), apps.APDUSuccess, nil
+}
+
+// slotPath returns the derivation path of the current key for slot.
+func slotPath(slot keySlot, st state) crypto.DerivationPath {
+	return crypto.BIP44Path(coinType, uint32(slot), 0, st.Keys[slot].Generation)
+}
+
+// ecdsaDigest returns digest as the crypto.DigestSize bytes digest it stands for when signing with
+// ECDSA over a 256 bits curve: longer digests are truncated to their leftmost bytes, shorter ones are
+// left-padded with zeroes, which leaves their integer value unchanged.
+func ecdsaDigest(digest []byte) []byte {
+	if len(digest) >= crypto.DigestSize {
+		return digest[:crypto.DigestSize]
+	}
+
+	ret := make([]byte, crypto.DigestSize)
+	copy(ret[crypto.DigestSize-len(digest):], digest)
+
+	return ret
+}
+
+func (o *OpenPGP) handlePSO(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	switch {
+	case c.P1 == 0x9E && c.P2 == 0x9A:
+		return o.computeDigitalSignature(c, st)
+	case c.P1 == 0x80 && c.P2 == 0x86:
+		return o.decipher(c, st)
+	default:
+		return nil, swWrongParameters, fmt.Errorf("pso %X%X not supported", c.P1, c.P2)
+	}
+}
+
+func (o *OpenPGP) computeDigitalSignature(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	if !o.verified[pw1Signature] {
+		return nil, swSecurityStatusNotSatisfied, fmt.Errorf("signature requires PW1")
+	}
+
+	if !st.Keys[slotSignature].Generated {
+		return nil, swConditionsNotSatisfied, fmt.Errorf("no signature key")
+	}
+
+	if len(c.Data) < 20 || len(c.Data) > 64 {
+		return nil, apps.APDUWrongLength, fmt.Errorf("digest length %v not supported", len(c.Data))
+	}
+
+	if !st.PW1ValidForMultipleSignatures {
+		o.verified[pw1Signature] = false
+	}
+
+	der, err := o.Token.SignDigest(slotPath(slotSignature, *st), crypto.AlgoSecp256K1, ecdsaDigest(c.Data))
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	sig, err := btcec.ParseDERSignature(der, btcec.S256())
+	if err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	st.SignatureCounter++
+	if err := saveState(o.Storage, *st); err != nil {
+		return nil, apps.APDUExecutionError, err
+	}
+
+	// ECDSA signatures are returned as r || s
+	ret := make([]byte, 64)
+	sig.R.FillBytes(ret[:32])
+	sig.S.FillBytes(ret[32:])
+
+	return ret, apps.APDUSuccess, nil
+}
+
+func (o *OpenPGP) decipher(c apdu.CAPDU, st *state) (response []byte, code apps.APDUCode, err error) {
+	if !o.verified[pw1Other] {
+		return nil, swSecurityStatusNotSatisfied, fmt.Errorf("decipher requires PW1")
+	}
+
+	if !st.Keys[slotDecryption].Generated {
+		return nil, swConditionsNotSatisfied, fmt.Errorf("no decryption key")
+	}
+
+	// cipher DO || public key DO || external public key
+	peer, err := tlv.Find(c.Data, 0xA6, 0x7F49, 0x86)
+	if err != nil {
+		return nil, swWrongData, err
+	}
+
+	shared, err := o.Token.ECDH(slotPath(slotDecryption, *st), crypto.AlgoSecp256K1, crypto.KDF{}, peer)
+	if err != nil {
+		return nil, swWrongData, err
+	}
+
+	return shared, apps.APDUSuccess, nil
+}
@@ -0,0 +1,113 @@
+package openpgp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/appstest"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func newTestOpenPGP(t *testing.T) (*OpenPGP, storage.Storage) {
+	t.Helper()
+
+	s := storage.NewMemory()
+
+	o := &OpenPGP{
+		Storage: s,
+	}
+	appstest.SetToken(o, appstest.Token(t))
+
+	return o, s
+}
+
+func run(o *OpenPGP, ins command, p1, p2 byte, data string) apps.APDUCode {
+	_, code, _ := appstest.Handle(o, byte(ins), p1, p2, []byte(data))
+	return code
+}
+
+func TestVerifyRetries(t *testing.T) {
+	o, s := newTestOpenPGP(t)
+
+	require.Equal(t, swVerifyFailed|2, run(o, insVerify, 0x00, pw1Signature, "000000"))
+	require.Equal(t, swVerifyFailed|1, run(o, insVerify, 0x00, pw1Other, "000000"))
+
+	// a right password resets the retry counter
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw1Signature, defaultPW1))
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw1Signature, ""))
+	require.Equal(t, swVerifyFailed|3, run(o, insVerify, 0x00, pw1Other, ""))
+
+	// a wrong one resets the access status
+	require.Equal(t, swVerifyFailed|2, run(o, insVerify, 0x00, pw1Signature, "000000"))
+	require.Equal(t, swVerifyFailed|2, run(o, insVerify, 0x00, pw1Signature, ""))
+
+	require.Equal(t, swVerifyFailed|1, run(o, insVerify, 0x00, pw1Signature, "000000"))
+	require.Equal(t, swVerifyFailed|0, run(o, insVerify, 0x00, pw1Signature, "000000"))
+
+	// blocked, even with the right password and across reboots
+	require.Equal(t, swAuthenticationBlocked, run(o, insVerify, 0x00, pw1Signature, defaultPW1))
+
+	o = &OpenPGP{Storage: s, Token: o.Token}
+	require.Equal(t, swAuthenticationBlocked, run(o, insVerify, 0x00, pw1Other, defaultPW1))
+
+	// PW3 has its own counter
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw3, defaultPW3))
+
+	// and signing is refused
+	require.Equal(t, swSecurityStatusNotSatisfied, run(o, insPSO, 0x9E, 0x9A, strings.Repeat("a", 32)))
+}
+
+func TestResetRetryCounter(t *testing.T) {
+	o, _ := newTestOpenPGP(t)
+
+	for i := 0; i < maxRetries; i++ {
+		run(o, insVerify, 0x00, pw1Signature, "000000")
+	}
+
+	require.Equal(t, swAuthenticationBlocked, run(o, insVerify, 0x00, pw1Signature, defaultPW1))
+
+	// resetting codes aren't supported, and PW3 is required
+	require.Equal(t, swSecurityStatusNotSatisfied, run(o, insResetRetryCounter, 0x00, pw1Signature, "resetting654321"))
+	require.Equal(t, swSecurityStatusNotSatisfied, run(o, insResetRetryCounter, 0x02, pw1Signature, "654321"))
+
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw3, defaultPW3))
+	require.Equal(t, swWrongData, run(o, insResetRetryCounter, 0x02, pw1Signature, "65432"))
+	require.Equal(t, apps.APDUSuccess, run(o, insResetRetryCounter, 0x02, pw1Signature, "654321"))
+
+	require.Equal(t, swVerifyFailed|2, run(o, insVerify, 0x00, pw1Signature, defaultPW1))
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw1Signature, "654321"))
+}
+
+func TestChangeReferenceData(t *testing.T) {
+	o, s := newTestOpenPGP(t)
+
+	// the old password is checked like VERIFY does
+	require.Equal(t, swVerifyFailed|2, run(o, insChangeReferenceData, 0x00, pw3, "87654321"+"new admin"))
+	require.Equal(t, swVerifyFailed|1, run(o, insChangeReferenceData, 0x00, pw3, "1234"))
+
+	// new passwords must be long enough
+	require.Equal(t, swWrongData, run(o, insChangeReferenceData, 0x00, pw3, defaultPW3+"short"))
+	require.Equal(t, apps.APDUSuccess, run(o, insChangeReferenceData, 0x00, pw3, defaultPW3+"new admin"))
+
+	require.Equal(t, swVerifyFailed|2, run(o, insVerify, 0x00, pw3, defaultPW3))
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw3, "new admin"))
+
+	// changing again splits the old password by its own length
+	require.Equal(t, apps.APDUSuccess, run(o, insChangeReferenceData, 0x00, pw1Signature, defaultPW1+"new user pin"))
+	require.Equal(t, apps.APDUSuccess, run(o, insChangeReferenceData, 0x00, pw1Signature, "new user pin"+"other pin"))
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw1Other, "other pin"))
+
+	// passwords are stored hashed, and survive reboots
+	raw, err := s.Get(stateKey)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "new admin")
+	require.NotContains(t, string(raw), "other pin")
+
+	o = &OpenPGP{Storage: s, Token: o.Token}
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw3, "new admin"))
+	require.Equal(t, apps.APDUSuccess, run(o, insVerify, 0x00, pw1Signature, "other pin"))
+
+	require.Equal(t, swWrongParameters, run(o, insChangeReferenceData, 0x00, pw1Other, "other pin"+"another pin"))
+}
@@ -0,0 +1,103 @@
+package openpgp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// defaultPW1 and defaultPW3 are the factory default passwords of OpenPGP cards, which hold until
+	// they are changed with CHANGE REFERENCE DATA.
+	defaultPW1 = "123456"
+	defaultPW3 = "12345678"
+
+	minPW1Length = 6
+	minPW3Length = 8
+	maxPWLength  = 0x7F
+
+	pwSaltSize   = 16
+	pwHashSize   = 32
+	pwIterations = 20000
+)
+
+// pwVerifier is a salted and stretched password hash.
+// The password length is kept along with it, since CHANGE REFERENCE DATA sends the old and the new
+// password concatenated.
+type pwVerifier struct {
+	Salt   []byte
+	Hash   []byte
+	Length int
+}
+
+func stretchPW(pw, salt []byte) []byte {
+	return pbkdf2.Key(pw, salt, pwIterations, pwHashSize, sha256.New)
+}
+
+// password is PW1 or PW3, as held in state.
+type password struct {
+	retries   *uint8
+	verifier  **pwVerifier
+	def       string
+	minLength int
+}
+
+// passwordFor returns the password referenced by ref in st.
+func passwordFor(st *state, ref byte) (password, error) {
+	switch ref {
+	case pw1Signature, pw1Other:
+		return password{
+			retries:   &st.PW1Retries,
+			verifier:  &st.PW1,
+			def:       defaultPW1,
+			minLength: minPW1Length,
+		}, nil
+	case pw3:
+		return password{
+			retries:   &st.PW3Retries,
+			verifier:  &st.PW3,
+			def:       defaultPW3,
+			minLength: minPW3Length,
+		}, nil
+	default:
+		return password{}, fmt.Errorf("unknown password reference %X", ref)
+	}
+}
+
+// length returns the length of the current password.
+func (p password) length() int {
+	if *p.verifier == nil {
+		return len(p.def)
+	}
+
+	return (*p.verifier).Length
+}
+
+// matches returns true if pw is the current password.
+func (p password) matches(pw []byte) bool {
+	v := *p.verifier
+	if v == nil {
+		return subtle.ConstantTimeCompare(pw, []byte(p.def)) == 1
+	}
+
+	return hmac.Equal(stretchPW(pw, v.Salt), v.Hash)
+}
+
+// set replaces the current password with pw, salted with salt, and resets its retry counter.
+func (p password) set(pw, salt []byte) error {
+	if len(pw) < p.minLength || len(pw) > maxPWLength {
+		return fmt.Errorf("password must be between %v and %v bytes long", p.minLength, maxPWLength)
+	}
+
+	*p.verifier = &pwVerifier{
+		Salt:   salt,
+		Hash:   stretchPW(pw, salt),
+		Length: len(pw),
+	}
+	*p.retries = maxRetries
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package openpgp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	stateKey = "openpgp/state"
+
+	maxRetries = 3
+)
+
+// keySlot identifies one of the three OpenPGP card keys.
+type keySlot int
+
+const (
+	slotSignature keySlot = iota
+	slotDecryption
+	slotAuthentication
+)
+
+// keySlotFromCRT returns the key slot referenced by a control reference template tag.
+func keySlotFromCRT(crt uint16) (keySlot, error) {
+	switch crt {
+	case 0xB6:
+		return slotSignature, nil
+	case 0xB8:
+		return slotDecryption, nil
+	case 0xA4:
+		return slotAuthentication, nil
+	default:
+		return 0, fmt.Errorf("unknown control reference template %X", crt)
+	}
+}
+
+type keyState struct {
+	Generated bool
+
+	// Generation is incremented every time a new key is generated for a slot,
+	// and is used as the last component of its derivation path.
+	Generation uint32
+}
+
+// state holds everything the OpenPGP application must remember across reboots.
+type state struct {
+	Serial uint32
+
+	// DataObjects holds the data objects written by the host through PUT DATA.
+	DataObjects map[uint16][]byte
+
+	PW1Retries uint8
+	PW3Retries uint8
+
+	// PW1 and PW3 are nil until the factory default passwords are changed.
+	PW1 *pwVerifier
+	PW3 *pwVerifier
+
+	// PW1ValidForMultipleSignatures mirrors the first PW status byte.
+	PW1ValidForMultipleSignatures bool
+
+	SignatureCounter uint32
+
+	Keys [3]keyState
+}
+
+func defaultState() state {
+	return state{
+		DataObjects: map[uint16][]byte{},
+		PW1Retries:  maxRetries,
+		PW3Retries:  maxRetries,
+	}
+}
+
+func loadState(s storage.Storage) (state, error) {
+	raw, err := s.Get(stateKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return defaultState(), nil
+	}
+
+	if err != nil {
+		return state{}, fmt.Errorf("cannot read openpgp state, %w", err)
+	}
+
+	st := defaultState()
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return state{}, fmt.Errorf("cannot unmarshal openpgp state, %w", err)
+	}
+
+	return st, nil
+}
+
+func saveState(s storage.Storage, st state) error {
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("cannot marshal openpgp state, %w", err)
+	}
+
+	return s.Set(stateKey, raw)
+}
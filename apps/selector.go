@@ -0,0 +1,113 @@
+package apps
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// ISOAppID is the class byte shared by all the ISO 7816-4 applets.
+	ISOAppID byte = 0x00
+
+	insSelect byte = 0xA4
+
+	selectByName byte = 0x04
+)
+
+// Applet is an App which follows ISO 7816-4 application selection: it shares the ISOAppID
+// class byte with other applets, and only receives commands after the host selected it by AID.
+type Applet interface {
+	App
+	AID() []byte
+}
+
+// Compile-time check which fails if Selector doesn't comply with
+// App interface.
+var _ App = (*Selector)(nil)
+
+// Selector is an App which routes ISO 7816-4 commands to the currently selected Applet.
+type Selector struct {
+	applets  []Applet
+	selected Applet
+}
+
+// NewSelector returns a Selector for applets.
+func NewSelector(applets ...Applet) *Selector {
+	return &Selector{
+		applets: applets,
+	}
+}
+
+// Name implements the App interface
+func (s *Selector) Name() string {
+	return "ISO7816"
+}
+
+// ID implements the App interface
+func (s *Selector) ID() byte {
+	return ISOAppID
+}
+
+// Commands implements the App interface
+func (s *Selector) Commands() (commandIDs []byte) {
+	seen := map[byte]struct{}{
+		insSelect: {},
+	}
+
+	ret := []byte{insSelect}
+
+	for _, a := range s.applets {
+		for _, cmd := range a.Commands() {
+			if _, found := seen[cmd]; found {
+				continue
+			}
+
+			seen[cmd] = struct{}{}
+			ret = append(ret, cmd)
+		}
+	}
+
+	return ret
+}
+
+// Handle implements the App interface
+func (s *Selector) Handle(command byte, data []byte) (response []byte, code APDUCode, err error) {
+	capdu, err := UnmarshalCAPDU(data)
+	if err != nil {
+		return nil, APDUWrongLength, err
+	}
+
+	if command == insSelect && capdu.P1 == selectByName {
+		applet := s.find(capdu.Data)
+		if applet == nil {
+			return nil, APDUFileNotFound, fmt.Errorf("no applet found for AID %X", capdu.Data)
+		}
+
+		s.selected = applet
+	}
+
+	if s.selected == nil {
+		return nil, APDUCommandNotAllowed, fmt.Errorf("no applet selected")
+	}
+
+	if !bytes.Contains(s.selected.Commands(), []byte{command}) {
+		return nil, APDUINSNotSupported, fmt.Errorf("command ID %v not supported in applet %v", command, s.selected.Name())
+	}
+
+	return s.selected.Handle(command, data)
+}
+
+// find returns the applet whose AID starts with aid, which may be partial.
+func (s *Selector) find(aid []byte) Applet {
+	if len(aid) == 0 {
+		return nil
+	}
+
+	for _, a := range s.applets {
+		if bytes.HasPrefix(a.AID(), aid) || bytes.HasPrefix(aid, a.AID()) {
+			return a
+		}
+	}
+
+	return nil
+}
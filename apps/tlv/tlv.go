@@ -0,0 +1,122 @@
+package tlv
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// TLV is a BER-TLV data object, as used by ISO 7816-4 applets.
+type TLV struct {
+	Tag   uint16
+	Value []byte
+}
+
+// Encode returns the BER-TLV encoding of tag and value.
+func Encode(tag uint16, value []byte) []byte {
+	buf := &bytes.Buffer{}
+
+	if tag > 0xFF {
+		buf.WriteByte(byte(tag >> 8))
+	}
+	buf.WriteByte(byte(tag))
+
+	l := len(value)
+	switch {
+	case l < 0x80:
+		buf.WriteByte(byte(l))
+	case l <= 0xFF:
+		buf.WriteByte(0x81)
+		buf.WriteByte(byte(l))
+	default:
+		buf.WriteByte(0x82)
+		buf.WriteByte(byte(l >> 8))
+		buf.WriteByte(byte(l))
+	}
+
+	buf.Write(value)
+
+	return buf.Bytes()
+}
+
+// Decode reads the first BER-TLV data object in data, and returns it along with
+// the remaining bytes.
+func Decode(data []byte) (TLV, []byte, error) {
+	if len(data) < 2 {
+		return TLV{}, nil, fmt.Errorf("tlv too short")
+	}
+
+	tag := uint16(data[0])
+	data = data[1:]
+
+	// tag number encoded in the subsequent byte
+	if tag&0x1F == 0x1F {
+		tag = tag<<8 | uint16(data[0])
+		data = data[1:]
+	}
+
+	if len(data) == 0 {
+		return TLV{}, nil, fmt.Errorf("tlv for tag %X has no length", tag)
+	}
+
+	l := int(data[0])
+	data = data[1:]
+
+	switch l {
+	case 0x81:
+		if len(data) < 1 {
+			return TLV{}, nil, fmt.Errorf("tlv for tag %X has truncated length", tag)
+		}
+
+		l = int(data[0])
+		data = data[1:]
+	case 0x82:
+		if len(data) < 2 {
+			return TLV{}, nil, fmt.Errorf("tlv for tag %X has truncated length", tag)
+		}
+
+		l = int(data[0])<<8 | int(data[1])
+		data = data[2:]
+	default:
+		if l >= 0x80 {
+			return TLV{}, nil, fmt.Errorf("tlv for tag %X has unsupported length encoding", tag)
+		}
+	}
+
+	if l > len(data) {
+		return TLV{}, nil, fmt.Errorf("tlv for tag %X is %v bytes long, but only %v are available", tag, l, len(data))
+	}
+
+	return TLV{
+		Tag:   tag,
+		Value: data[:l],
+	}, data[l:], nil
+}
+
+// Find looks for the data object identified by the given chain of tags, descending
+// into constructed data objects.
+func Find(data []byte, tags ...uint16) ([]byte, error) {
+	for _, tag := range tags {
+		found := false
+
+		for len(data) > 0 {
+			t, rest, err := Decode(data)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.Tag == tag {
+				data = t.Value
+				found = true
+				break
+			}
+
+			data = rest
+		}
+
+		if !found {
+			return nil, fmt.Errorf("tag %X not found", tag)
+		}
+	}
+
+	return data, nil
+}
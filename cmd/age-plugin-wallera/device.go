@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/usb"
+	"go.uber.org/zap"
+)
+
+const (
+	hidReportSize = 64
+
+	// channelID is the HID channel LedgerJS uses as well.
+	channelID = 0x0101
+)
+
+// statusError is returned when the device answers with a status word other than success.
+type statusError apps.APDUCode
+
+func (s statusError) Error() string {
+	return fmt.Sprintf("device returned %v", apps.APDUCode(s))
+}
+
+// device exchanges APDUs with a WallERA over a Linux hidraw file.
+type device struct {
+	f *os.File
+}
+
+func openDevice(path string) (*device, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open device %v, %w", path, err)
+	}
+
+	return &device{f: f}, nil
+}
+
+func (d *device) Close() error {
+	return d.f.Close()
+}
+
+// exchange sends the command APDU made of cla, ins, p1, p2 and data, and returns the response
+// data once the device answered with a success status word.
+func (d *device) exchange(cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
+	if len(data) > 0xFF {
+		return nil, fmt.Errorf("command data too long")
+	}
+
+	capdu := append([]byte{cla, ins, p1, p2, byte(len(data))}, data...)
+
+	for _, frame := range usb.Frames(channelID, capdu) {
+		// hidraw expects the report ID first, which is always zero for WallERA
+		report := make([]byte, hidReportSize+1)
+		copy(report[1:], frame)
+
+		if _, err := d.f.Write(report); err != nil {
+			return nil, fmt.Errorf("cannot write to device, %w", err)
+		}
+	}
+
+	var session *usb.Session
+	for session == nil || session.ShouldReadMore {
+		report := make([]byte, hidReportSize)
+		n, err := d.f.Read(report)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read from device, %w", err)
+		}
+
+		if session == nil {
+			s, err := usb.NewSession(report[:n], zap.NewNop().Sugar())
+			if err != nil {
+				return nil, err
+			}
+
+			session = &s
+			continue
+		}
+
+		if err := session.ReadData(report[:n]); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := session.Data()
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("response too short")
+	}
+
+	code := apps.APDUCode(binary.BigEndian.Uint16(resp[len(resp)-2:]))
+	if code != apps.APDUSuccess {
+		return nil, statusError(code)
+	}
+
+	return resp[:len(resp)-2], nil
+}
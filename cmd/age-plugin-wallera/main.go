@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cosmos/btcutil/bech32"
+	"github.com/wallera-computer/wallera/apps"
+)
+
+const (
+	// identityHRP is the Bech32 human readable part of identities handled by this plugin,
+	// which age maps to the age-plugin-wallera binary.
+	identityHRP = "age-plugin-wallera-"
+
+	maxIdentityLength = 90
+
+	// Application and commands of apps/age.
+	ageAppID        byte = 0x41
+	claGetRecipient byte = 0x02
+	claUnwrap       byte = 0x04
+
+	defaultDevice = "/dev/hidraw0"
+)
+
+type args struct {
+	agePlugin string
+	device    string
+	generate  bool
+	account   uint
+}
+
+func cliArgs() args {
+	a := args{}
+
+	device := os.Getenv("WALLERA_DEVICE")
+	if device == "" {
+		device = defaultDevice
+	}
+
+	flag.StringVar(&a.agePlugin, "age-plugin", "", "age plugin state machine, set by age")
+	flag.StringVar(&a.device, "device", device, "WallERA hidraw device path, defaults to $WALLERA_DEVICE")
+	flag.BoolVar(&a.generate, "generate", false, "print the identity and recipient of an account and exit")
+	flag.UintVar(&a.account, "account", 0, "account index of the identity to generate")
+	flag.Parse()
+
+	return a
+}
+
+func main() {
+	a := cliArgs()
+
+	switch {
+	case a.generate:
+		if err := generate(a.device, uint32(a.account)); err != nil {
+			log.Fatal("cannot generate identity, ", err)
+		}
+	case a.agePlugin == "identity-v1":
+		d, err := openDevice(a.device)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+
+		if err := runIdentity(os.Stdin, os.Stdout, unwrapper(d)); err != nil {
+			log.Fatal(err)
+		}
+	case a.agePlugin != "":
+		// wallera recipients are native X25519 ones, age encrypts to them by itself
+		log.Fatalf("unsupported state machine %v", a.agePlugin)
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// encodeIdentity returns the age identity string of account.
+func encodeIdentity(account uint32) (string, error) {
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, account)
+
+	converted, err := bech32.ConvertBits(raw, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := bech32.Encode(identityHRP, converted)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(encoded), nil
+}
+
+// parseIdentity returns the account index held by an identity string.
+func parseIdentity(identity string) (uint32, error) {
+	hrp, data, err := bech32.Decode(strings.ToLower(identity), maxIdentityLength)
+	if err != nil {
+		return 0, fmt.Errorf("malformed identity, %w", err)
+	}
+
+	if hrp != identityHRP {
+		return 0, fmt.Errorf("identity has unknown prefix %v", hrp)
+	}
+
+	raw, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return 0, fmt.Errorf("malformed identity, %w", err)
+	}
+
+	if len(raw) != 4 {
+		return 0, fmt.Errorf("identity is %v bytes long, expected 4", len(raw))
+	}
+
+	return binary.BigEndian.Uint32(raw), nil
+}
+
+func accountBytes(account uint32) []byte {
+	ret := make([]byte, 4)
+	binary.LittleEndian.PutUint32(ret, account)
+	return ret
+}
+
+func generate(device string, account uint32) error {
+	d, err := openDevice(device)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	resp, err := d.exchange(ageAppID, claGetRecipient, 0x01, 0x00, accountBytes(account))
+	if err != nil {
+		return err
+	}
+
+	if len(resp) < 32 {
+		return fmt.Errorf("recipient response too short")
+	}
+
+	identity, err := encodeIdentity(account)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# account: %v\n", account)
+	fmt.Printf("# recipient: %s\n", resp[32:])
+	fmt.Println(identity)
+
+	return nil
+}
+
+// unwrapper returns an unwrapFunc which unwraps X25519 stanzas on d.
+func unwrapper(d *device) unwrapFunc {
+	return func(identities []uint32, s stanza) ([]byte, error) {
+		if s.Type != "X25519" {
+			return nil, nil
+		}
+
+		if len(s.Args) != 1 {
+			return nil, fmt.Errorf("invalid X25519 stanza")
+		}
+
+		share, err := base64.RawStdEncoding.Strict().DecodeString(s.Args[0])
+		if err != nil || len(share) != 32 {
+			return nil, fmt.Errorf("invalid X25519 stanza share")
+		}
+
+		if len(s.Body) != 32 {
+			return nil, fmt.Errorf("invalid X25519 stanza body")
+		}
+
+		for _, account := range identities {
+			payload := append(accountBytes(account), share...)
+			payload = append(payload, s.Body...)
+
+			fileKey, err := d.exchange(ageAppID, claUnwrap, 0x00, 0x00, payload)
+
+			var se statusError
+			if errors.As(err, &se) && apps.APDUCode(se) == apps.APDUDataInvalid {
+				// stanza addressed to some other recipient
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			return fileKey, nil
+		}
+
+		return nil, nil
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	stanzaPrefix = "-> "
+
+	// bodyColumns is the width of the base64 lines a stanza body is wrapped at.
+	bodyColumns = 64
+)
+
+// stanza is an age stanza, as exchanged over the plugin stdio protocol.
+type stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+func readStanza(r *bufio.Reader) (stanza, error) {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return stanza{}, err
+	}
+
+	header = strings.TrimSuffix(header, "\n")
+	if !strings.HasPrefix(header, stanzaPrefix) {
+		return stanza{}, fmt.Errorf("malformed stanza header %q", header)
+	}
+
+	fields := strings.Split(strings.TrimPrefix(header, stanzaPrefix), " ")
+	if fields[0] == "" {
+		return stanza{}, fmt.Errorf("stanza with no type")
+	}
+
+	s := stanza{
+		Type: fields[0],
+		Args: fields[1:],
+	}
+
+	// the body ends with the first line shorter than a full one, which might be empty
+	body := &strings.Builder{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return stanza{}, fmt.Errorf("truncated stanza body, %w", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		body.WriteString(line)
+
+		if len(line) < bodyColumns {
+			break
+		}
+	}
+
+	s.Body, err = base64.RawStdEncoding.Strict().DecodeString(body.String())
+	if err != nil {
+		return stanza{}, fmt.Errorf("malformed stanza body, %w", err)
+	}
+
+	return s, nil
+}
+
+func writeStanza(w io.Writer, s stanza) error {
+	header := append([]string{s.Type}, s.Args...)
+	if _, err := fmt.Fprintf(w, "%s%s\n", stanzaPrefix, strings.Join(header, " ")); err != nil {
+		return err
+	}
+
+	body := base64.RawStdEncoding.EncodeToString(s.Body)
+	for {
+		line := body
+		if len(line) > bodyColumns {
+			line = line[:bodyColumns]
+		}
+		body = body[len(line):]
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+
+		// a full last line must be followed by an empty one
+		if len(line) < bodyColumns {
+			return nil
+		}
+	}
+}
+
+// recipientStanza is a recipient stanza of one of the files being decrypted.
+type recipientStanza struct {
+	fileIndex   string
+	stanzaIndex int
+	stanza      stanza
+}
+
+// unwrapFunc returns the file key wrapped in s, or nil if s isn't addressed to any of identities.
+type unwrapFunc func(identities []uint32, s stanza) ([]byte, error)
+
+// runIdentity implements the identity-v1 state machine of the age plugin protocol.
+func runIdentity(in io.Reader, out io.Writer, unwrap unwrapFunc) error {
+	r := bufio.NewReader(in)
+
+	var identities []uint32
+	var stanzas []recipientStanza
+	stanzasPerFile := map[string]int{}
+
+	// phase 1: the client sends identities and recipient stanzas
+	for {
+		s, err := readStanza(r)
+		if err != nil {
+			return err
+		}
+
+		switch s.Type {
+		case "add-identity":
+			if len(s.Args) != 1 {
+				return fmt.Errorf("add-identity needs exactly one argument")
+			}
+
+			account, err := parseIdentity(s.Args[0])
+			if err != nil {
+				return err
+			}
+
+			identities = append(identities, account)
+		case "recipient-stanza":
+			if len(s.Args) < 2 {
+				return fmt.Errorf("recipient-stanza needs at least two arguments")
+			}
+
+			stanzas = append(stanzas, recipientStanza{
+				fileIndex:   s.Args[0],
+				stanzaIndex: stanzasPerFile[s.Args[0]],
+				stanza: stanza{
+					Type: s.Args[1],
+					Args: s.Args[2:],
+					Body: s.Body,
+				},
+			})
+
+			stanzasPerFile[s.Args[0]]++
+		}
+
+		if s.Type == "done" {
+			break
+		}
+	}
+
+	// phase 2: the plugin sends back file keys, and waits for an acknowledgement of each one
+	unwrapped := map[string]bool{}
+	for _, rs := range stanzas {
+		if unwrapped[rs.fileIndex] {
+			continue
+		}
+
+		fileKey, err := unwrap(identities, rs.stanza)
+		if err != nil {
+			if err := sendCommand(r, out, stanza{
+				Type: "error",
+				Args: []string{"stanza", rs.fileIndex, strconv.Itoa(rs.stanzaIndex)},
+				Body: []byte(err.Error()),
+			}); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fileKey == nil {
+			continue
+		}
+
+		if err := sendCommand(r, out, stanza{
+			Type: "file-key",
+			Args: []string{rs.fileIndex},
+			Body: fileKey,
+		}); err != nil {
+			return err
+		}
+
+		unwrapped[rs.fileIndex] = true
+	}
+
+	return writeStanza(out, stanza{Type: "done"})
+}
+
+// sendCommand sends s to the client, and reads its response.
+func sendCommand(r *bufio.Reader, out io.Writer, s stanza) error {
+	if err := writeStanza(out, s); err != nil {
+		return err
+	}
+
+	resp, err := readStanza(r)
+	if err != nil {
+		return err
+	}
+
+	if resp.Type != "ok" && resp.Type != "fail" {
+		return fmt.Errorf("unexpected response %v to %v", resp.Type, s.Type)
+	}
+
+	return nil
+}
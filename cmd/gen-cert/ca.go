@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultRootSubject         = "O=WallERA,CN=WallERA Attestation Root CA"
+	defaultIntermediateSubject = "O=WallERA,CN=WallERA Attestation CA"
+)
+
+// authority is a CA out of a gen-cert directory, made of three files:
+//   - <name>_privkey.pem, its PKCS#8 private key
+//   - <name>_certificate.pem, its certificate
+//   - <name>_chain.pem, its certificate followed by the ones of its issuers, root excluded; empty for roots
+type authority struct {
+	name  string
+	key   crypto.Signer
+	cert  *x509.Certificate
+	chain [][]byte
+}
+
+func keyPath(dir, name string) string {
+	return filepath.Join(dir, name+"_privkey.pem")
+}
+
+func certificatePath(dir, name string) string {
+	return filepath.Join(dir, name+"_certificate.pem")
+}
+
+func chainPath(dir, name string) string {
+	return filepath.Join(dir, name+"_chain.pem")
+}
+
+// loadAuthority loads the CA name out of dir.
+func loadAuthority(dir, name string) *authority {
+	certs := readCertificates(certificatePath(dir, name))
+	if len(certs) != 1 {
+		log.Fatalf("%v must hold a single certificate", certificatePath(dir, name))
+	}
+
+	cert, err := x509.ParseCertificate(certs[0])
+	if err != nil {
+		log.Fatal("cannot parse CA certificate, ", err)
+	}
+
+	if !cert.IsCA {
+		log.Fatalf("%v isn't a CA certificate", certificatePath(dir, name))
+	}
+
+	a := &authority{
+		name: name,
+		key:  readPrivateKey(keyPath(dir, name)),
+		cert: cert,
+	}
+
+	if _, err := os.Stat(chainPath(dir, name)); err == nil {
+		a.chain = readCertificates(chainPath(dir, name))
+	}
+
+	return a
+}
+
+// save writes a into dir, refusing to overwrite an existing CA.
+func (a *authority) save(dir string) {
+	writeNew(keyPath(dir, a.name), encodePrivateKey(a.key), 0600)
+	writeNew(certificatePath(dir, a.name), encodeCertificates(a.cert.Raw), 0644)
+	writeNew(chainPath(dir, a.name), encodeCertificates(a.chain...), 0644)
+}
+
+// caFlags are the flags shared by root and intermediate.
+type caFlags struct {
+	dir      string
+	name     string
+	keyType  string
+	subject  string
+	validity string
+}
+
+func (c *caFlags) register(fs *flag.FlagSet, name, subject, validity string) {
+	fs.StringVar(&c.dir, "dir", ".", "directory holding the CAs and the serial number ledger")
+	fs.StringVar(&c.name, "name", name, "name of the CA files")
+	fs.StringVar(&c.keyType, "key-type", keyTypeP256, fmt.Sprintf("CA key type, one of %v", keyTypes))
+	fs.StringVar(&c.subject, "subject", subject, "CA subject, as comma-separated C, ST, L, O, OU, CN and SERIALNUMBER attributes")
+	fs.StringVar(&c.validity, "validity", validity, "CA validity, in years (20y), days (365d) or as a Go duration")
+}
+
+// template returns the certificate template of the CA described by c, with a serial number out of l.
+func (c *caFlags) template(l *ledger) *x509.Certificate {
+	subject, err := parseSubject(c.subject)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notBefore, notAfter, err := validityPeriod(c.validity)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return &x509.Certificate{
+		SerialNumber:          l.serialNumber(),
+		Subject:               subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+// createRoot creates a self-signed root CA.
+// Roots can sign a single level of intermediate CAs.
+func createRoot(args []string) {
+	fs := flag.NewFlagSet("root", flag.ExitOnError)
+	c := caFlags{}
+	c.register(fs, "root", defaultRootSubject, "20y")
+	_ = fs.Parse(args)
+
+	l, err := openLedger(c.dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	key, err := generateKey(c.keyType)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	template := c.template(l)
+	template.MaxPathLen = 1
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		log.Fatal("cannot generate certificate, ", err)
+	}
+
+	a := newAuthority(c.name, key, der, nil)
+	if err := l.record(a.cert); err != nil {
+		log.Fatal(err)
+	}
+
+	a.save(c.dir)
+
+	fmt.Printf("created root CA %q, serial %x\n", a.cert.Subject.CommonName, a.cert.SerialNumber)
+}
+
+// createIntermediate creates an intermediate CA signed by another CA, which can only sign device certificates.
+func createIntermediate(args []string) {
+	fs := flag.NewFlagSet("intermediate", flag.ExitOnError)
+	c := caFlags{}
+	c.register(fs, "intermediate", defaultIntermediateSubject, "10y")
+	issuerName := fs.String("ca", "root", "name of the signing CA")
+	_ = fs.Parse(args)
+
+	l, err := openLedger(c.dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	issuer := loadAuthority(c.dir, *issuerName)
+
+	key, err := generateKey(c.keyType)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	template := c.template(l)
+	template.MaxPathLenZero = true
+	capValidity(template, issuer.cert)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer.cert, key.Public(), issuer.key)
+	if err != nil {
+		log.Fatal("cannot generate certificate, ", err)
+	}
+
+	a := newAuthority(c.name, key, der, append([][]byte{der}, issuer.chain...))
+	if err := l.record(a.cert); err != nil {
+		log.Fatal(err)
+	}
+
+	a.save(c.dir)
+
+	fmt.Printf("created intermediate CA %q, serial %x, signed by %q\n",
+		a.cert.Subject.CommonName, a.cert.SerialNumber, issuer.cert.Subject.CommonName)
+}
+
+func newAuthority(name string, key crypto.Signer, der []byte, chain [][]byte) *authority {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		log.Fatal("cannot parse certificate, ", err)
+	}
+
+	return &authority{
+		name:  name,
+		key:   key,
+		cert:  cert,
+		chain: chain,
+	}
+}
+
+// capValidity ends the validity of template along with the one of its issuer, if it'd outlive it.
+func capValidity(template, issuer *x509.Certificate) {
+	if template.NotAfter.After(issuer.NotAfter) {
+		log.Printf("capping validity to the one of %q, %v", issuer.Subject.CommonName, issuer.NotAfter)
+		template.NotAfter = issuer.NotAfter
+	}
+}
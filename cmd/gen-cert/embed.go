@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// embed writes the certificates given as arguments as a Go source file declaring their concatenated
+// DER encoding, ready to be passed to x509.ParseCertificates.
+// Firmware and host tool builds embed the root CA this way.
+func embed(args []string) {
+	fs := flag.NewFlagSet("embed", flag.ExitOnError)
+	pkg := fs.String("package", "attestation", "Go package name")
+	name := fs.String("var", "RootCertificate", "Go variable name")
+	out := fs.String("o", "", "output file, standard output if empty")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatal("usage: gen-cert embed [flags] CERTIFICATE...")
+	}
+
+	if !token.IsIdentifier(*pkg) || !token.IsIdentifier(*name) {
+		log.Fatal("package and variable names must be Go identifiers")
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintln(src, "// Code generated by gen-cert; DO NOT EDIT.")
+	fmt.Fprintln(src)
+	fmt.Fprintf(src, "package %v\n\n", *pkg)
+
+	for _, path := range fs.Args() {
+		fmt.Fprintf(src, "// %v\n", path)
+	}
+
+	fmt.Fprintf(src, "var %v = []byte{", *name)
+
+	i := 0
+	for _, path := range fs.Args() {
+		for _, der := range readCertificates(path) {
+			for _, b := range der {
+				if i%16 == 0 {
+					fmt.Fprint(src, "\n")
+				}
+
+				fmt.Fprintf(src, "0x%02x, ", b)
+				i++
+			}
+		}
+	}
+
+	fmt.Fprintln(src, "\n}")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		log.Fatal("cannot format Go source, ", err)
+	}
+
+	if *out == "" {
+		_, _ = os.Stdout.Write(formatted)
+		return
+	}
+
+	if err := ioutil.WriteFile(*out, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// issue issues device certificates for the attestation keys of the CSRs given as arguments.
+// For each device, it writes the certificate in <device>_certificate.pem, and the chain to provision
+// with SET_ATTESTATION_CHAIN in <device>_chain.der.
+func issue(args []string) {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory holding the CAs and the serial number ledger")
+	out := fs.String("out", ".", "directory the device certificates and chains are written to")
+	issuerName := fs.String("ca", "root", "name of the signing CA")
+	subject := fs.String("subject", "", "device subject, replacing the one of the CSR, as comma-separated C, ST, L, O, OU, CN and SERIALNUMBER attributes")
+	validity := fs.String("validity", "10y", "device certificate validity, in years (10y), days (365d) or as a Go duration")
+	reissue := fs.Bool("reissue", false, "issue certificates for attestation keys the ledger already holds one for")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatal("usage: gen-cert issue [flags] CSR...")
+	}
+
+	l, err := openLedger(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	issuer := loadAuthority(*dir, *issuerName)
+
+	for _, csrPath := range fs.Args() {
+		if err := issueDevice(l, issuer, csrPath, *out, *subject, *validity, *reissue); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// issueDevice issues the device certificate of the CSR held in csrPath, recording it in l before
+// writing it and its chain into out: a certificate can't get out without a ledger entry.
+func issueDevice(l *ledger, issuer *authority, csrPath, out, subject, validity string, reissue bool) error {
+	csr, err := x509.ParseCertificateRequest(readDER(csrPath, "CERTIFICATE REQUEST"))
+	if err != nil {
+		return fmt.Errorf("cannot parse CSR %v, %w", csrPath, err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("CSR %v isn't signed by the key it holds, %w", csrPath, err)
+	}
+
+	if pk, ok := csr.PublicKey.(*ecdsa.PublicKey); !ok || pk.Curve != elliptic.P256() {
+		return fmt.Errorf("CSR %v doesn't hold a P-256 attestation key", csrPath)
+	}
+
+	if serial, found := l.certified(csr.RawSubjectPublicKeyInfo); found && !reissue {
+		return fmt.Errorf("the attestation key of %v has already been certified with serial %v, use -reissue to issue another certificate", csrPath, serial)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          l.serialNumber(),
+		Subject:               csr.Subject,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	if subject != "" {
+		if template.Subject, err = parseSubject(subject); err != nil {
+			return err
+		}
+	}
+
+	if template.NotBefore, template.NotAfter, err = validityPeriod(validity); err != nil {
+		return err
+	}
+
+	capValidity(template, issuer.cert)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer.cert, csr.PublicKey, issuer.key)
+	if err != nil {
+		return fmt.Errorf("cannot generate certificate, %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("cannot parse certificate, %w", err)
+	}
+
+	device := filepath.Join(out, deviceName(cert.Subject.CommonName))
+
+	if err := l.record(cert); err != nil {
+		return err
+	}
+
+	writeNew(device+"_certificate.pem", encodeCertificates(der), 0644)
+
+	// the root CA is left out of the chain devices hold, hosts check it against their own copy
+	chain := bytes.Join(append([][]byte{der}, issuer.chain...), nil)
+	if err := ioutil.WriteFile(device+"_chain.der", chain, 0644); err != nil {
+		return fmt.Errorf("cannot write attestation chain, %w", err)
+	}
+
+	fmt.Printf("issued certificate %x for %q\n", cert.SerialNumber, cert.Subject.CommonName)
+
+	return nil
+}
+
+// deviceName returns the file name prefix of the device whose certificate has commonName.
+func deviceName(commonName string) string {
+	name := strings.ToLower(strings.ReplaceAll(commonName, " ", "_"))
+	if name == "" || strings.ContainsAny(name, "/\\.") {
+		log.Fatalf("unexpected device common name %q", commonName)
+	}
+
+	return name
+}
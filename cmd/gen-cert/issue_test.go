@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// newCA creates a root CA and an intermediate one signed by it in a temporary directory.
+func newCA(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	createRoot([]string{"-dir", dir})
+	createIntermediate([]string{"-dir", dir})
+
+	return dir
+}
+
+// deviceCSR returns the path of the CSR of the attestation key of a new device.
+func deviceCSR(t *testing.T) (crypto.DeviceToken, string) {
+	t.Helper()
+
+	token := crypto.NewDumbToken(storage.NewMemory())
+
+	csr, err := token.AttestationCSR()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "device.csr")
+	require.NoError(t, ioutil.WriteFile(path, csr, 0644))
+
+	return token, path
+}
+
+// issued returns the chain issueDevice wrote into out.
+func issued(t *testing.T, out string) []byte {
+	t.Helper()
+
+	chains, err := filepath.Glob(filepath.Join(out, "*_chain.der"))
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+
+	chain, err := ioutil.ReadFile(chains[0])
+	require.NoError(t, err)
+
+	return chain
+}
+
+func TestIssueDeviceChain(t *testing.T) {
+	dir := newCA(t)
+	token, csrPath := deviceCSR(t)
+
+	l, err := openLedger(dir)
+	require.NoError(t, err)
+
+	out := t.TempDir()
+	require.NoError(t, issueDevice(l, loadAuthority(dir, "intermediate"), csrPath, out, "", "10y", false))
+
+	chain := issued(t, out)
+	require.NoError(t, token.SetAttestationChain(chain))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(loadAuthority(dir, "root").cert)
+
+	challenge := []byte("0123456789abcdef")
+	signature, err := token.Attest(challenge, "v1.0.0")
+	require.NoError(t, err)
+	require.NoError(t, crypto.VerifyAttestation(chain, roots, challenge, "v1.0.0", signature))
+
+	// chains issued by another root aren't trusted
+	other := x509.NewCertPool()
+	other.AddCert(loadAuthority(newCA(t), "root").cert)
+	require.Error(t, crypto.VerifyAttestation(chain, other, challenge, "v1.0.0", signature))
+}
+
+func TestIssueDeviceReissue(t *testing.T) {
+	dir := newCA(t)
+	_, csrPath := deviceCSR(t)
+
+	l, err := openLedger(dir)
+	require.NoError(t, err)
+
+	issuer := loadAuthority(dir, "intermediate")
+
+	first := t.TempDir()
+	require.NoError(t, issueDevice(l, issuer, csrPath, first, "", "10y", false))
+
+	// the ledger is reopened like separate gen-cert runs do
+	l, err = openLedger(dir)
+	require.NoError(t, err)
+
+	second := t.TempDir()
+	require.Error(t, issueDevice(l, issuer, csrPath, second, "", "10y", false))
+
+	files, err := ioutil.ReadDir(second)
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.NoError(t, issueDevice(l, issuer, csrPath, second, "", "10y", true))
+
+	firstChain, err := x509.ParseCertificates(issued(t, first))
+	require.NoError(t, err)
+
+	secondChain, err := x509.ParseCertificates(issued(t, second))
+	require.NoError(t, err)
+
+	require.NotEqual(t, firstChain[0].SerialNumber, secondChain[0].SerialNumber)
+	require.Equal(t, firstChain[0].RawSubjectPublicKeyInfo, secondChain[0].RawSubjectPublicKeyInfo)
+
+	// root, intermediate and both device certificates
+	content, err := ioutil.ReadFile(filepath.Join(dir, ledgerFile))
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 5)
+}
+
+func TestIssueDeviceRecordsFirst(t *testing.T) {
+	dir := newCA(t)
+	_, csrPath := deviceCSR(t)
+
+	l, err := openLedger(dir)
+	require.NoError(t, err)
+
+	// a ledger which can't be written leaves no certificate behind
+	require.NoError(t, os.Remove(filepath.Join(dir, ledgerFile)))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ledgerFile), 0755))
+
+	out := t.TempDir()
+	require.Error(t, issueDevice(l, loadAuthority(dir, "intermediate"), csrPath, out, "", "10y", false))
+
+	files, err := ioutil.ReadDir(out)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ledgerFile   = "serials.ledger"
+	ledgerHeader = "# serial\tissued\tnot after\tsubject\tissuer\tkey sha256"
+)
+
+// serialBits is the size of serial numbers: positive, and well within the 20 bytes RFC 5280 allows.
+const serialBits = 127
+
+// ledger is the serial number ledger of a gen-cert directory: an append-only file with a line for every
+// certificate issued out of it, holding tab-separated
+//   - the serial number, in hex
+//   - the issuance date
+//   - the end of validity
+//   - the subject common name
+//   - the issuer common name
+//   - the SHA-256 digest of the subject public key info, in hex
+//
+// Serial numbers are never reused, and attestation keys are only certified once unless asked to.
+type ledger struct {
+	path    string
+	serials map[string]struct{}
+	keys    map[string]string
+}
+
+// openLedger reads the ledger of dir, which is created on the first record.
+func openLedger(dir string) (*ledger, error) {
+	l := &ledger{
+		path:    filepath.Join(dir, ledgerFile),
+		serials: map[string]struct{}{},
+		keys:    map[string]string{},
+	}
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot open serial number ledger, %w", err)
+	}
+
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if s.Text() == "" || strings.HasPrefix(s.Text(), "#") {
+			continue
+		}
+
+		fields := strings.Split(s.Text(), "\t")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("%v:%v: malformed ledger entry", l.path, line)
+		}
+
+		if _, found := l.serials[fields[0]]; found {
+			return nil, fmt.Errorf("%v:%v: duplicate serial number %v", l.path, line, fields[0])
+		}
+
+		l.serials[fields[0]] = struct{}{}
+		l.keys[fields[5]] = fields[0]
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read serial number ledger, %w", err)
+	}
+
+	return l, nil
+}
+
+// serialNumber returns a random serial number, which the ledger doesn't hold yet.
+func (l *ledger) serialNumber() *big.Int {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialBits)
+
+	for {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			log.Fatal("cannot generate serial, ", err)
+		}
+
+		if _, found := l.serials[serial.Text(16)]; serial.Sign() > 0 && !found {
+			return serial
+		}
+	}
+}
+
+// certified returns the serial number of the certificate the ledger holds for the subject public
+// key info spki, if any.
+func (l *ledger) certified(spki []byte) (string, bool) {
+	serial, found := l.keys[keyID(spki)]
+	return serial, found
+}
+
+// record appends cert to the ledger.
+func (l *ledger) record(cert *x509.Certificate) error {
+	serial := cert.SerialNumber.Text(16)
+	if _, found := l.serials[serial]; found {
+		return fmt.Errorf("serial number %v has already been issued", serial)
+	}
+
+	_, err := os.Stat(l.path)
+	header := errors.Is(err, os.ErrNotExist)
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open serial number ledger, %w", err)
+	}
+
+	if header {
+		if _, err := fmt.Fprintln(f, ledgerHeader); err != nil {
+			f.Close()
+			return fmt.Errorf("cannot write serial number ledger, %w", err)
+		}
+	}
+
+	entry := strings.Join([]string{
+		serial,
+		time.Now().UTC().Format(time.RFC3339),
+		cert.NotAfter.UTC().Format(time.RFC3339),
+		cert.Subject.CommonName,
+		cert.Issuer.CommonName,
+		keyID(cert.RawSubjectPublicKeyInfo),
+	}, "\t")
+
+	if _, err := fmt.Fprintln(f, entry); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write serial number ledger, %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot write serial number ledger, %w", err)
+	}
+
+	l.serials[serial] = struct{}{}
+	l.keys[keyID(cert.RawSubjectPublicKeyInfo)] = serial
+
+	return nil
+}
+
+func keyID(spki []byte) string {
+	digest := sha256.Sum256(spki)
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeLedger(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	content := strings.Join(lines, "\n") + "\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ledgerFile), []byte(content), 0644))
+
+	return dir
+}
+
+func TestOpenLedger(t *testing.T) {
+	dir := writeLedger(t,
+		ledgerHeader,
+		"1f\t2022-01-01T00:00:00Z\t2032-01-01T00:00:00Z\tWallERA 0011\tWallERA Attestation CA\taa",
+		"",
+		"2e\t2022-01-02T00:00:00Z\t2032-01-02T00:00:00Z\tWallERA 0022\tWallERA Attestation CA\tbb",
+	)
+
+	l, err := openLedger(dir)
+	require.NoError(t, err)
+	require.Len(t, l.serials, 2)
+	require.Equal(t, map[string]string{"aa": "1f", "bb": "2e"}, l.keys)
+
+	// a missing ledger is created on the first record
+	l, err = openLedger(t.TempDir())
+	require.NoError(t, err)
+	require.Empty(t, l.serials)
+}
+
+func TestOpenLedgerRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{
+			"malformed entry",
+			[]string{"1f\t2022-01-01T00:00:00Z\tWallERA 0011\taa"},
+		},
+		{
+			"duplicate serial",
+			[]string{
+				"1f\t2022-01-01T00:00:00Z\t2032-01-01T00:00:00Z\tWallERA 0011\tWallERA Attestation CA\taa",
+				"1f\t2022-01-02T00:00:00Z\t2032-01-02T00:00:00Z\tWallERA 0022\tWallERA Attestation CA\tbb",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openLedger(writeLedger(t, tt.lines...))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLedgerRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := openLedger(dir)
+	require.NoError(t, err)
+
+	cert := &x509.Certificate{
+		SerialNumber:            l.serialNumber(),
+		Subject:                 pkix.Name{CommonName: "WallERA 0011"},
+		Issuer:                  pkix.Name{CommonName: "WallERA Attestation CA"},
+		NotAfter:                time.Date(2032, 1, 1, 0, 0, 0, 0, time.UTC),
+		RawSubjectPublicKeyInfo: []byte("spki"),
+	}
+
+	require.NoError(t, l.record(cert))
+
+	// serial numbers are never reused
+	require.Error(t, l.record(cert))
+
+	serial, found := l.certified([]byte("spki"))
+	require.True(t, found)
+	require.Equal(t, cert.SerialNumber.Text(16), serial)
+
+	// the record survives reopening, after the header
+	content, err := ioutil.ReadFile(filepath.Join(dir, ledgerFile))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), ledgerHeader+"\n"))
+
+	l, err = openLedger(dir)
+	require.NoError(t, err)
+
+	serial, found = l.certified([]byte("spki"))
+	require.True(t, found)
+	require.Equal(t, cert.SerialNumber.Text(16), serial)
+
+	require.Error(t, l.record(&x509.Certificate{
+		SerialNumber:            new(big.Int).Set(cert.SerialNumber),
+		RawSubjectPublicKeyInfo: []byte("other spki"),
+	}))
+}
@@ -1,72 +1,44 @@
 package main
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
-	"io/ioutil"
+	"fmt"
 	"log"
-	"math"
-	"math/big"
-	"time"
+	"os"
 )
 
-func main() {
-	serial, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
-	if err != nil {
-		log.Fatal("cannot generate serial, ", err)
-	}
-	cert := &x509.Certificate{
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(10, 0, 0), // this certificate is valid for 10 years
-		SerialNumber: serial,
-		Issuer: pkix.Name{
-			Country:      []string{"IT"},
-			SerialNumber: "",
-			CommonName:   "Fidati U2F Token",
-		},
-		PublicKeyAlgorithm: x509.ECDSA,
-		SignatureAlgorithm: x509.ECDSAWithSHA256,
-	}
+const usage = `usage: gen-cert COMMAND [flags] [args]
 
-	cert.Subject = cert.Issuer
-
-	privkey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		log.Fatal("cannot generate ecdsa key ", err)
-	}
+gen-cert is the provisioning CA of WallERA devices.
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, cert, cert, &privkey.PublicKey, privkey)
-	if err != nil {
-		log.Fatal("cannot generate certificate, ", err)
-	}
+commands:
+  root          creates a self-signed root CA
+  intermediate  creates an intermediate CA, signed by another CA
+  issue         issues device certificates out of the CSRs devices return to ATTESTATION_CSR
+  embed         writes certificates as a Go source file, to embed them in firmware and host tool builds
 
-	privkeyDer, err := x509.MarshalECPrivateKey(privkey)
-	if err != nil {
-		log.Fatal("cannot marshal ecdsa privkey, ", err)
-	}
+run gen-cert COMMAND -h for the flags of a command.`
 
-	privkeyPem := pem.EncodeToMemory(&pem.Block{
-		Type:  "EC PRIVATE KEY",
-		Bytes: privkeyDer,
-	})
+func main() {
+	log.SetFlags(0)
 
-	err = ioutil.WriteFile("ecdsa_privkey.pem", privkeyPem, 0644)
-	if err != nil {
-		log.Fatal("cannot write private key: ", err)
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	certPem := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	})
-
-	err = ioutil.WriteFile("attestation_certificate.pem", certPem, 0644)
-	if err != nil {
-		log.Fatal("cannot write certificate: ", err)
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "root":
+		createRoot(args)
+	case "intermediate":
+		createIntermediate(args)
+	case "issue":
+		issue(args)
+	case "embed":
+		embed(args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
 	}
-
 }
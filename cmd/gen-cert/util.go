@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	keyTypeP256    = "ecdsa-p256"
+	keyTypeP384    = "ecdsa-p384"
+	keyTypeEd25519 = "ed25519"
+)
+
+var keyTypes = []string{keyTypeP256, keyTypeP384, keyTypeEd25519}
+
+// generateKey generates a CA key of type keyType.
+func generateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case keyTypeP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case keyTypeP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case keyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q, must be one of %v", keyType, keyTypes)
+	}
+}
+
+// encodePrivateKey returns key as a PKCS#8 PEM block.
+func encodePrivateKey(key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		log.Fatal("cannot marshal private key, ", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// readPrivateKey reads a PKCS#8 or SEC 1 PEM private key out of path.
+func readPrivateKey(path string) crypto.Signer {
+	block := readPEM(path, "PRIVATE KEY", "EC PRIVATE KEY")
+
+	if block.Type == "EC PRIVATE KEY" {
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			log.Fatalf("cannot parse private key %v, %v", path, err)
+		}
+
+		return key
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		log.Fatalf("cannot parse private key %v, %v", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		log.Fatalf("unsupported private key type in %v", path)
+	}
+
+	return signer
+}
+
+// encodeCertificates returns ders as consecutive PEM blocks.
+func encodeCertificates(ders ...[]byte) []byte {
+	var out []byte
+	for _, der := range ders {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+
+	return out
+}
+
+// readCertificates reads the certificates held in path, either as PEM blocks or concatenated DER.
+func readCertificates(path string) [][]byte {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal("cannot read certificates, ", err)
+	}
+
+	var ders [][]byte
+
+	if block, rest := pem.Decode(content); block != nil {
+		for ; block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				log.Fatalf("unexpected PEM block %q in %v", block.Type, path)
+			}
+
+			ders = append(ders, block.Bytes)
+		}
+
+		return ders
+	}
+
+	certs, err := x509.ParseCertificates(content)
+	if err != nil {
+		log.Fatalf("cannot parse certificates %v, %v", path, err)
+	}
+
+	for _, c := range certs {
+		ders = append(ders, c.Raw)
+	}
+
+	return ders
+}
+
+// readDER reads the DER content of path, either raw or as a PEM block of type blockType.
+func readDER(path, blockType string) []byte {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != blockType {
+			log.Fatalf("unexpected PEM block %q in %v, expected %q", block.Type, path, blockType)
+		}
+
+		return block.Bytes
+	}
+
+	return content
+}
+
+func readPEM(path string, blockTypes ...string) *pem.Block {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		log.Fatalf("%v isn't a PEM file", path)
+	}
+
+	for _, t := range blockTypes {
+		if block.Type == t {
+			return block
+		}
+	}
+
+	log.Fatalf("unexpected PEM block %q in %v", block.Type, path)
+
+	return nil
+}
+
+// writeNew writes content to path, which must not exist: gen-cert never overwrites keys nor certificates.
+func writeNew(path string, content []byte, perm os.FileMode) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if errors.Is(err, os.ErrExist) {
+		log.Fatalf("%v already exists, refusing to overwrite it", path)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := f.Write(content); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseSubject parses a comma-separated list of ATTRIBUTE=value subject attributes, such as
+// "C=IT,O=WallERA,CN=WallERA Attestation CA".
+func parseSubject(s string) (pkix.Name, error) {
+	name := pkix.Name{}
+
+	for _, attribute := range strings.Split(s, ",") {
+		kv := strings.SplitN(attribute, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return pkix.Name{}, fmt.Errorf("malformed subject attribute %q", attribute)
+		}
+
+		value := strings.TrimSpace(kv[1])
+
+		switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+		case "C":
+			name.Country = append(name.Country, value)
+		case "ST":
+			name.Province = append(name.Province, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "CN":
+			name.CommonName = value
+		case "SERIALNUMBER":
+			name.SerialNumber = value
+		default:
+			return pkix.Name{}, fmt.Errorf("unsupported subject attribute %q", kv[0])
+		}
+	}
+
+	if name.CommonName == "" {
+		return pkix.Name{}, fmt.Errorf("subject %q has no CN", s)
+	}
+
+	return name, nil
+}
+
+// validityPeriod returns the validity period of a certificate valid from now on for v, expressed in
+// years (10y), days (365d) or as a Go duration (8760h).
+func validityPeriod(v string) (time.Time, time.Time, error) {
+	notBefore := time.Now().UTC().Truncate(time.Second)
+
+	var notAfter time.Time
+
+	switch {
+	case strings.HasSuffix(v, "y"):
+		years, err := strconv.Atoi(strings.TrimSuffix(v, "y"))
+		if err != nil || years <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("malformed validity %q", v)
+		}
+
+		notAfter = notBefore.AddDate(years, 0, 0)
+	case strings.HasSuffix(v, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err != nil || days <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("malformed validity %q", v)
+		}
+
+		notAfter = notBefore.AddDate(0, 0, days)
+	default:
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("malformed validity %q", v)
+		}
+
+		notAfter = notBefore.Add(d)
+	}
+
+	return notBefore, notAfter, nil
+}
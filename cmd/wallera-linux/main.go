@@ -1,24 +1,39 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/age"
 	"github.com/wallera-computer/wallera/apps/cosmos"
+	"github.com/wallera-computer/wallera/apps/device"
+	"github.com/wallera-computer/wallera/apps/nostr"
+	"github.com/wallera-computer/wallera/apps/oath"
+	"github.com/wallera-computer/wallera/apps/openpgp"
 	"github.com/wallera-computer/wallera/crypto"
 	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/pin"
+	"github.com/wallera-computer/wallera/storage"
 	"github.com/wallera-computer/wallera/usb"
 	"go.uber.org/zap"
 )
 
+// Revision contains the git revision (last hash and/or tag).
+var Revision string
+
 type args struct {
 	hidg          string
 	configfsPath  string
+	storagePath   string
 	mustClean     bool
 	mustSetupHidg bool
 }
@@ -28,6 +43,7 @@ func cliArgs() args {
 
 	flag.StringVar(&a.hidg, "hidg", "/dev/hidg0", "/dev/hidgX file descriptor path")
 	flag.StringVar(&a.configfsPath, "configfs-path", "/sys/kernel/config", "configfs path")
+	flag.StringVar(&a.storagePath, "storage", "wallera-storage.bin", "device state storage file path")
 	flag.BoolVar(&a.mustClean, "clean", false, "clean existing hidg descriptors and exit")
 	flag.BoolVar(&a.mustSetupHidg, "setup", false, "sets up dummy_hcd device and exits")
 	flag.Parse()
@@ -79,12 +95,31 @@ func main() {
 	// add 50ms delay in both rx and tx
 	// we don't wanna burn laptop cpus :^)
 
-	t := crypto.NewDumbToken()
+	s, err := storage.NewFile(a.storagePath)
+	notErr(err, l)
+
+	t := crypto.NewDumbToken(s)
+	notErr(crypto.SelfTest(t), l)
+
+	pm := pin.NewManager(s, t.Wipe)
+	dev := &device.Device{
+		Token:    t,
+		PIN:      pm,
+		Confirm:  apps.ConfirmFunc(terminalConfirm),
+		Reveal:   apps.SecretConfirmFunc(terminalConfirmSecret),
+		Revision: Revision,
+	}
 
-	ah := apps.NewHandler()
-	ah.Register(&cosmos.Cosmos{
-		Token: t,
-	})
+	ah := apps.NewHandler(t)
+	ah.Protect(pm, dev)
+	ah.Register(dev, &cosmos.Cosmos{}, apps.NewSelector(&openpgp.OpenPGP{
+		Storage: s,
+	}, &oath.OATH{
+		Storage: s,
+		Confirm: apps.ConfirmFunc(terminalConfirm),
+	}), &nostr.Nostr{
+		Confirm: apps.ConfirmFunc(terminalConfirm),
+	}, &age.Age{})
 
 	ha := hidHandler{
 		ah:           ah,
@@ -135,6 +170,34 @@ func main() {
 	l.Info("exiting, call this binary with the '-clean' flag to clean hidg entries")
 }
 
+// terminalConfirm asks the user sitting at the terminal to approve an operation.
+func terminalConfirm(prompt string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(strings.TrimSpace(answer), "y"), nil
+}
+
+// terminalConfirmSecret shows a secret on the terminal, numbering the words of mnemonics, and asks
+// the user to confirm it has been backed up.
+func terminalConfirmSecret(prompt string, secret []byte) (bool, error) {
+	words := bytes.Fields(secret)
+	for i, w := range words {
+		if len(words) > 1 {
+			fmt.Fprintf(os.Stderr, "%2d. ", i+1)
+		}
+
+		os.Stderr.Write(w)
+		os.Stderr.Write([]byte("\n"))
+	}
+
+	return terminalConfirm(prompt)
+}
+
 type hidHandler struct {
 	ah *apps.Handler
 
@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// AccountKey is an account-level extended public key, as exported to watch-only wallets.
+type AccountKey struct {
+	// XPub is the BIP-32 serialization of the extended public key.
+	XPub string
+
+	// Fingerprint is the fingerprint of the master key XPub has been derived from.
+	Fingerprint []byte
+
+	// Path is the derivation path of XPub.
+	Path DerivationPath
+}
+
+// AccountPathPolicy returns an error if extended public keys can't be exported at path.
+// Only account-level paths can: three or four components, all of them hardened, like m/44'/118'/0'
+// or m/48'/0'/0'/2'.
+// Extended public keys of shallower paths would expose every account at once, and together with
+// any private key below them, non-hardened paths would expose their parent private key.
+func AccountPathPolicy(path DerivationPath) error {
+	if len(path) < 3 || len(path) > 4 {
+		return fmt.Errorf("extended public key export path %v is not account-level", path)
+	}
+
+	for _, c := range path {
+		if c < HardenedKeyStart {
+			return fmt.Errorf("extended public key export path %v must only have hardened components", path)
+		}
+	}
+
+	return nil
+}
+
+// NewAccountKey derives the AccountKey at path from the BIP-39 seed, if AccountPathPolicy allows it.
+// Bitcoin testnet accounts, whose coin type is 1', are serialized as tpub.
+func NewAccountKey(seed []byte, path DerivationPath) (AccountKey, error) {
+	if err := AccountPathPolicy(path); err != nil {
+		return AccountKey{}, err
+	}
+
+	params := &chaincfg.MainNetParams
+	if path[1] == Hardened(1) {
+		params = &chaincfg.TestNet3Params
+	}
+
+	master, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	defer WipeExtendedKey(master)
+
+	key, err := KeyFromPath(master, path)
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	defer WipeExtendedKey(key)
+
+	pub, err := key.Neuter()
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	fp, err := Fingerprint(seed)
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	return AccountKey{
+		XPub:        pub.String(),
+		Fingerprint: fp,
+		Path:        path,
+	}, nil
+}
+
+// Descriptors returns the BIP-380 output descriptors of the receive and change addresses of a,
+// for the Bitcoin accounts of BIP-44 (pkh), BIP-49 (sh(wpkh)), BIP-84 (wpkh) and BIP-86 (tr).
+func (a AccountKey) Descriptors() ([]string, error) {
+	if len(a.Path) != 3 || (a.Path[1] != Hardened(0) && a.Path[1] != Hardened(1)) {
+		return nil, fmt.Errorf("no output descriptor for path %v", a.Path)
+	}
+
+	var format string
+	switch a.Path[0] {
+	case Hardened(44):
+		format = "pkh(%s)"
+	case Hardened(49):
+		format = "sh(wpkh(%s))"
+	case Hardened(84):
+		format = "wpkh(%s)"
+	case Hardened(86):
+		format = "tr(%s)"
+	default:
+		return nil, fmt.Errorf("no output descriptor for path %v", a.Path)
+	}
+
+	origin := "[" + hex.EncodeToString(a.Fingerprint) + strings.TrimPrefix(a.Path.String(), "m") + "]"
+
+	ret := []string{}
+	for _, change := range []int{0, 1} {
+		desc := fmt.Sprintf(format, fmt.Sprintf("%s%s/%d/*", origin, a.XPub, change))
+
+		checksum, err := DescriptorChecksum(desc)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, desc+"#"+checksum)
+	}
+
+	return ret, nil
+}
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+func descriptorPolymod(c uint64, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+
+	for i, g := range []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd} {
+		if c0>>i&1 == 1 {
+			c ^= g
+		}
+	}
+
+	return c
+}
+
+// DescriptorChecksum returns the 8 characters BIP-380 checksum of desc.
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls := uint64(0)
+	clsCount := 0
+
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in descriptor", ch)
+		}
+
+		c = descriptorPolymod(c, uint64(pos&31))
+		cls = cls*3 + uint64(pos>>5)
+
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolymod(c, cls)
+			cls = 0
+			clsCount = 0
+		}
+	}
+
+	if clsCount > 0 {
+		c = descriptorPolymod(c, cls)
+	}
+
+	for i := 0; i < 8; i++ {
+		c = descriptorPolymod(c, 0)
+	}
+
+	c ^= 1
+
+	ret := make([]byte, 8)
+	for i := range ret {
+		ret[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+
+	return string(ret), nil
+}
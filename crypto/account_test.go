@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	// BIP-380 test vector
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	require.NoError(t, err)
+	require.Equal(t, "89f8spxm", checksum)
+
+	_, err = DescriptorChecksum("raw(deadbeef)\n")
+	require.Error(t, err)
+}
+
+func TestAccountPathPolicy(t *testing.T) {
+	for _, path := range []string{"m/44'/118'/0'", "m/84'/0'/0'", "m/48'/0'/0'/2'"} {
+		dp, err := ParseDerivationPath(path)
+		require.NoError(t, err)
+		require.NoError(t, AccountPathPolicy(dp), path)
+	}
+
+	for _, path := range []string{"m", "m/44'/0'", "m/44'/0'/0", "m/44'/0'/0'/0", "m/44'/0'/0'/0'/0'"} {
+		dp, err := ParseDerivationPath(path)
+		require.NoError(t, err)
+		require.Error(t, AccountPathPolicy(dp), path)
+	}
+}
+
+func Test_dumbToken_AccountKey(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory()).(*dumbToken)
+	require.NoError(t, dt.ImportSeed(strings.Fields(
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+	)))
+
+	// BIP-86 test vector
+	dp, err := ParseDerivationPath("m/86'/0'/0'")
+	require.NoError(t, err)
+
+	key, err := dt.AccountKey(dp)
+	require.NoError(t, err)
+	require.Equal(t,
+		"xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ",
+		key.XPub,
+	)
+	require.Equal(t, []byte{0x73, 0xc5, 0xda, 0x0a}, key.Fingerprint)
+	require.Equal(t, dp, key.Path)
+
+	descriptors, err := key.Descriptors()
+	require.NoError(t, err)
+	require.Len(t, descriptors, 2)
+	require.True(t, strings.HasPrefix(descriptors[0], "tr([73c5da0a/86'/0'/0']"+key.XPub+"/0/*)#"))
+	require.True(t, strings.HasPrefix(descriptors[1], "tr([73c5da0a/86'/0'/0']"+key.XPub+"/1/*)#"))
+
+	// testnet accounts are serialized as tpub
+	key, err = dt.AccountKey(DerivationPath{Hardened(84), Hardened(1), Hardened(0)})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key.XPub, "tpub"))
+
+	// only bitcoin accounts have output descriptors
+	key, err = dt.AccountKey(DerivationPath{Hardened(44), Hardened(118), Hardened(0)})
+	require.NoError(t, err)
+
+	_, err = key.Descriptors()
+	require.Error(t, err)
+
+	// and the path policy is enforced
+	_, err = dt.AccountKey(BIP44Path(118, 0, 0, 0))
+	require.Error(t, err)
+}
@@ -9,11 +9,15 @@ func _() {
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
 	_ = x[AlgoSecp256K1-0]
+	_ = x[AlgoSecp256K1Schnorr-1]
+	_ = x[AlgoX25519-2]
+	_ = x[AlgoEd25519-3]
+	_ = x[AlgoP256-4]
 }
 
-const _Algorithm_name = "AlgoSecp256K1"
+const _Algorithm_name = "AlgoSecp256K1AlgoSecp256K1SchnorrAlgoX25519AlgoEd25519AlgoP256"
 
-var _Algorithm_index = [...]uint8{0, 13}
+var _Algorithm_index = [...]uint8{0, 13, 33, 43, 54, 62}
 
 func (i Algorithm) String() string {
 	if i >= Algorithm(len(_Algorithm_index)-1) {
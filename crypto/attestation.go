@@ -0,0 +1,307 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/wallera-computer/wallera/entropy"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	attestationKeyKey   = "crypto/attestation_key"
+	attestationChainKey = "crypto/attestation_chain"
+
+	// MinChallengeSize and MaxChallengeSize bound the size of the host challenges attestations sign.
+	MinChallengeSize = 16
+	MaxChallengeSize = 64
+
+	// MaxRevisionLength is the maximum length of the firmware revisions attestations sign.
+	MaxRevisionLength = 255
+
+	// MaxAttestationChainSize is the maximum size of an attestation certificate chain.
+	MaxAttestationChainSize = 4096
+
+	attestationDomain = "WallERA attestation\x00"
+)
+
+var (
+	// ErrNoAttestationKey is returned by Attesters which haven't generated their attestation key yet.
+	ErrNoAttestationKey = errors.New("device has no attestation key, a CSR must be requested first")
+
+	// ErrNotProvisioned is returned by Attesters which haven't been given their certificate chain yet.
+	ErrNotProvisioned = errors.New("device has no attestation certificate chain, it must be provisioned first")
+)
+
+// Attester is implemented by Tokens holding a device attestation key: a P-256 key generated on the device,
+// which never leaves it, certified by the provisioning CA.
+// The attestation key is the identity of the device: unlike seeds, wiping the device keeps it.
+type Attester interface {
+	// AttestationCSR returns a DER certificate signing request for the attestation key, generating it
+	// if the device has none yet.
+	AttestationCSR() ([]byte, error)
+
+	// SetAttestationChain stores chain, the concatenated DER certificates of the attestation key, device
+	// certificate first, up to the root CA excluded.
+	SetAttestationChain(chain []byte) error
+
+	// AttestationChain returns the chain stored by SetAttestationChain.
+	AttestationChain() ([]byte, error)
+
+	// Attest signs AttestationDigest(challenge, revision) with the attestation key, returning an ASN.1
+	// ECDSA signature.
+	Attest(challenge []byte, revision string) ([]byte, error)
+}
+
+// MeasuredAttester is implemented by Attesters running apart from the firmware they attest, like the
+// TEE applet: they only attest the revision measured by the environment which loaded the firmware.
+type MeasuredAttester interface {
+	// MeasuredRevision returns the only revision Attest signs.
+	MeasuredRevision() (string, error)
+}
+
+// ImageRevision returns the revision of a measured firmware image: its hex encoded SHA-256 digest,
+// prefixed by "sha256:".
+func ImageRevision(image []byte) string {
+	digest := sha256.Sum256(image)
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+// attestationKey returns the attestation key held in s, generating it if generate is true and s has
+// none yet.
+// The caller must wipe it once done.
+func attestationKey(s storage.Storage, generate bool) (*ecdsa.PrivateKey, error) {
+	raw, err := s.Get(attestationKeyKey)
+	if err == nil {
+		defer Wipe(raw)
+
+		curve := elliptic.P256()
+		key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(raw)}
+		key.Curve = curve
+		key.X, key.Y = curve.ScalarBaseMult(raw)
+
+		return key, nil
+	}
+
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("cannot read attestation key, %w", err)
+	}
+
+	if !generate {
+		return nil, ErrNoAttestationKey
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), entropy.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate attestation key, %w", err)
+	}
+
+	scalar := key.D.FillBytes(make([]byte, 32))
+	defer Wipe(scalar)
+
+	if err := s.Set(attestationKeyKey, scalar); err != nil {
+		WipeECDSAPrivateKey(key)
+		return nil, fmt.Errorf("cannot store attestation key, %w", err)
+	}
+
+	return key, nil
+}
+
+// AttestationCSR returns a DER certificate signing request for the attestation key held in s,
+// generating it if s has none yet.
+// Its subject common name tells devices apart by the hash of their attestation public key.
+func AttestationCSR(s storage.Storage) ([]byte, error) {
+	key, err := attestationKey(s, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeECDSAPrivateKey(key)
+
+	id := sha256.Sum256(elliptic.MarshalCompressed(key.Curve, key.X, key.Y))
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName: "WallERA " + hex.EncodeToString(id[:8]),
+		},
+		SignatureAlgorithm: x509.ECDSAWithSHA256,
+	}
+
+	return x509.CreateCertificateRequest(entropy.Reader, template, key)
+}
+
+// SetAttestationChain stores chain in s, after checking that its device certificate certifies the
+// attestation key held in s, and that each certificate is signed by the next one.
+func SetAttestationChain(s storage.Storage, chain []byte) error {
+	if len(chain) > MaxAttestationChainSize {
+		return fmt.Errorf("attestation chain is larger than %v bytes", MaxAttestationChainSize)
+	}
+
+	certs, err := x509.ParseCertificates(chain)
+	if err != nil {
+		return fmt.Errorf("cannot parse attestation chain, %w", err)
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("attestation chain is empty")
+	}
+
+	key, err := attestationKey(s, false)
+	if err != nil {
+		return err
+	}
+
+	defer WipeECDSAPrivateKey(key)
+
+	if !key.PublicKey.Equal(certs[0].PublicKey) {
+		return fmt.Errorf("device certificate doesn't certify the attestation key")
+	}
+
+	for i := 0; i < len(certs)-1; i++ {
+		if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+			return fmt.Errorf("certificate %v of the attestation chain isn't signed by the next one, %w", i, err)
+		}
+	}
+
+	return s.Set(attestationChainKey, chain)
+}
+
+// AttestationChain returns the attestation chain held in s.
+func AttestationChain(s storage.Storage) ([]byte, error) {
+	chain, err := s.Get(attestationChainKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotProvisioned
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot read attestation chain, %w", err)
+	}
+
+	return chain, nil
+}
+
+// AttestationDigest returns the digest attestations sign: the SHA-256 digest of a domain separator,
+// the length-prefixed firmware revision, and the host challenge.
+func AttestationDigest(challenge []byte, revision string) ([]byte, error) {
+	if len(challenge) < MinChallengeSize || len(challenge) > MaxChallengeSize {
+		return nil, fmt.Errorf("challenge must be between %v and %v bytes long", MinChallengeSize, MaxChallengeSize)
+	}
+
+	if len(revision) > MaxRevisionLength {
+		return nil, fmt.Errorf("revision must be at most %v characters long", MaxRevisionLength)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(attestationDomain))
+	h.Write([]byte{byte(len(revision))})
+	h.Write([]byte(revision))
+	h.Write(challenge)
+
+	return h.Sum(nil), nil
+}
+
+// certifiedKey returns the compressed public key certified by the device certificate of the attestation
+// chain held in s.
+func certifiedKey(s storage.Storage) ([]byte, error) {
+	chain, err := AttestationChain(s)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := x509.ParseCertificates(chain)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse attestation chain, %w", err)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("attestation chain is empty")
+	}
+
+	publicKey, ok := certs[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("device certificate doesn't certify a P-256 key")
+	}
+
+	return elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y), nil
+}
+
+// Attest signs AttestationDigest(challenge, revision) with the attestation key held in s.
+// Devices must be provisioned before attesting.
+// Signatures are only released if they verify against the key of the device certificate, rather
+// than against a public key computed from the stored scalar: a corrupted attestation key makes
+// attestations fail, instead of signing them with a key hosts don't trust.
+func Attest(s storage.Storage, challenge []byte, revision string) ([]byte, error) {
+	digest, err := AttestationDigest(challenge, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := certifiedKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := attestationKey(s, false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeECDSAPrivateKey(key)
+
+	signature, err := ecdsa.SignASN1(entropy.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReleaseSignature(AlgoP256, publicKey, digest, signature)
+}
+
+// VerifyAttestation returns an error unless chain leads to one of roots, and signature is the
+// attestation of challenge and revision by its device certificate.
+// Hosts check that a device is genuine with it.
+func VerifyAttestation(chain []byte, roots *x509.CertPool, challenge []byte, revision string, signature []byte) error {
+	certs, err := x509.ParseCertificates(chain)
+	if err != nil {
+		return fmt.Errorf("cannot parse attestation chain, %w", err)
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("attestation chain is empty")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("device certificate isn't trusted, %w", err)
+	}
+
+	publicKey, ok := certs[0].PublicKey.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return fmt.Errorf("device certificate doesn't hold a P-256 key")
+	}
+
+	digest, err := AttestationDigest(challenge, revision)
+	if err != nil {
+		return err
+	}
+
+	if !ecdsa.VerifyASN1(publicKey, digest, signature) {
+		return fmt.Errorf("attestation signature doesn't verify")
+	}
+
+	return nil
+}
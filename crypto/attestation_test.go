@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// testCA is a provisioning CA, issuing device certificates out of CSRs.
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T, parent *testCA) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	signer, signerCert := key, template
+	if parent != nil {
+		signer, signerCert = parent.key, parent.cert
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{key: key, cert: cert}
+}
+
+func (ca *testCA) issue(t *testing.T, csrDER []byte) []byte {
+	t.Helper()
+
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	require.NoError(t, err)
+	require.NoError(t, csr.CheckSignature())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return der
+}
+
+func TestAttestation(t *testing.T) {
+	root := newTestCA(t, nil)
+	intermediate := newTestCA(t, root)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	dt := seededToken(t)
+	challenge := make([]byte, MinChallengeSize)
+
+	_, err := dt.Attest(challenge, "v1")
+	require.ErrorIs(t, err, ErrNotProvisioned)
+
+	// chains can't be set before the attestation key exists
+	require.ErrorIs(t, dt.SetAttestationChain(root.cert.Raw), ErrNoAttestationKey)
+
+	csr, err := dt.AttestationCSR()
+	require.NoError(t, err)
+
+	// the attestation key is generated once
+	again, err := dt.AttestationCSR()
+	require.NoError(t, err)
+
+	first, err := x509.ParseCertificateRequest(csr)
+	require.NoError(t, err)
+	second, err := x509.ParseCertificateRequest(again)
+	require.NoError(t, err)
+	require.True(t, first.PublicKey.(*ecdsa.PublicKey).Equal(second.PublicKey))
+
+	chain := append(intermediate.issue(t, csr), intermediate.cert.Raw...)
+
+	// the device certificate must certify the attestation key
+	otherDevice := NewDumbToken(storage.NewMemory())
+	otherCSR, err := otherDevice.AttestationCSR()
+	require.NoError(t, err)
+	require.Error(t, dt.SetAttestationChain(intermediate.issue(t, otherCSR)))
+
+	// and each certificate must be signed by the next one
+	require.Error(t, dt.SetAttestationChain(append(intermediate.issue(t, csr), root.cert.Raw...)))
+
+	require.NoError(t, dt.SetAttestationChain(chain))
+
+	stored, err := dt.AttestationChain()
+	require.NoError(t, err)
+	require.Equal(t, chain, stored)
+
+	signature, err := dt.Attest(challenge, "v1")
+	require.NoError(t, err)
+	require.NoError(t, VerifyAttestation(stored, roots, challenge, "v1", signature))
+
+	require.Error(t, VerifyAttestation(stored, roots, challenge, "v2", signature))
+	require.Error(t, VerifyAttestation(stored, roots, make([]byte, MaxChallengeSize), "v1", signature))
+	require.Error(t, VerifyAttestation(stored, x509.NewCertPool(), challenge, "v1", signature))
+
+	_, err = dt.Attest(make([]byte, MinChallengeSize-1), "v1")
+	require.Error(t, err)
+
+	_, err = dt.Attest(make([]byte, MaxChallengeSize+1), "v1")
+	require.Error(t, err)
+
+	// wiping the device keeps its identity
+	require.NoError(t, dt.Wipe())
+
+	signature, err = dt.Attest(challenge, "v1")
+	require.NoError(t, err)
+	require.NoError(t, VerifyAttestation(stored, roots, challenge, "v1", signature))
+
+	// a corrupted attestation key signs nothing, since signatures are checked against the certified key
+	corrupted := make([]byte, 32)
+	corrupted[31] = 1
+	require.NoError(t, dt.storage.Set(attestationKeyKey, corrupted))
+
+	_, err = dt.Attest(challenge, "v1")
+	require.ErrorIs(t, err, ErrFaultySignature)
+}
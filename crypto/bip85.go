@@ -0,0 +1,239 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+//go:generate stringer -type=BIP85Application
+type BIP85Application uint
+
+// BIP-85 applications Tokens derive child secrets for.
+const (
+	// BIP85Mnemonic derives BIP-39 english mnemonics of 12, 18 or 24 words.
+	BIP85Mnemonic BIP85Application = iota
+
+	// BIP85Hex derives between 16 and 64 bytes of entropy, hex encoded.
+	BIP85Hex
+
+	// BIP85WIF derives compressed secp256k1 private keys, in Wallet Import Format.
+	BIP85WIF
+
+	// BIP85Password derives base85 passwords between 10 and 80 characters long.
+	BIP85Password
+)
+
+// BIP-85 constants.
+const (
+	bip85Purpose    = 83696968
+	bip85EntropyKey = "bip-entropy-from-k"
+
+	bip85AppMnemonic = 39
+	bip85AppHex      = 128169
+	bip85AppWIF      = 2
+	bip85AppPassword = 707785
+
+	bip85LanguageEnglish = 0
+
+	bip85MinHexBytes      = 16
+	bip85MaxHexBytes      = 64
+	bip85MinPasswordChars = 10
+	bip85MaxPasswordChars = 80
+
+	wifMainNet        byte = 0x80
+	wifCompressedFlag byte = 0x01
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// base85Alphabet is the RFC 1924 one, which BIP-85 passwords are encoded with.
+	base85Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&()*+-;<=>?@^_`{|}~"
+)
+
+// BIP85Path returns the derivation path of the index-th child secret of app, length being the amount
+// of mnemonic words, hex bytes or password characters it's made of.
+// WIF keys have no length, and must be given 0.
+func BIP85Path(app BIP85Application, length, index uint32) (DerivationPath, error) {
+	if index >= HardenedKeyStart {
+		return nil, fmt.Errorf("BIP-85 index %v out of range", index)
+	}
+
+	switch app {
+	case BIP85Mnemonic:
+		if !ValidMnemonicLength(int(length)) {
+			return nil, fmt.Errorf("unsupported BIP-85 mnemonic length %v, must be either 12, 18 or 24 words", length)
+		}
+
+		return DerivationPath{
+			Hardened(bip85Purpose),
+			Hardened(bip85AppMnemonic),
+			Hardened(bip85LanguageEnglish),
+			Hardened(length),
+			Hardened(index),
+		}, nil
+	case BIP85Hex:
+		if length < bip85MinHexBytes || length > bip85MaxHexBytes {
+			return nil, fmt.Errorf(
+				"unsupported BIP-85 hex length %v, must be between %v and %v bytes", length, bip85MinHexBytes, bip85MaxHexBytes,
+			)
+		}
+
+		return DerivationPath{
+			Hardened(bip85Purpose),
+			Hardened(bip85AppHex),
+			Hardened(length),
+			Hardened(index),
+		}, nil
+	case BIP85WIF:
+		if length != 0 {
+			return nil, fmt.Errorf("BIP-85 WIF keys have no length")
+		}
+
+		return DerivationPath{
+			Hardened(bip85Purpose),
+			Hardened(bip85AppWIF),
+			Hardened(index),
+		}, nil
+	case BIP85Password:
+		if length < bip85MinPasswordChars || length > bip85MaxPasswordChars {
+			return nil, fmt.Errorf(
+				"unsupported BIP-85 password length %v, must be between %v and %v characters",
+				length, bip85MinPasswordChars, bip85MaxPasswordChars,
+			)
+		}
+
+		return DerivationPath{
+			Hardened(bip85Purpose),
+			Hardened(bip85AppPassword),
+			Hardened(length),
+			Hardened(index),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown BIP-85 application %v", app)
+	}
+}
+
+// BIP85Entropy returns the 64 bytes of BIP-85 entropy of key, that is the HMAC-SHA512 of its private key.
+// The caller owns the returned slice, and should wipe it once done.
+func BIP85Entropy(key *hdkeychain.ExtendedKey) ([]byte, error) {
+	pk, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeECPrivateKey(pk)
+
+	k := bytes32(pk.D)
+	defer Wipe(k)
+
+	return hmacSHA512([]byte(bip85EntropyKey), k), nil
+}
+
+// BIP85Secret formats the BIP-85 entropy derived at the BIP85Path of app and length as ASCII text:
+// space-separated mnemonic words, lowercase hex, a WIF key or a password.
+// Secrets are never turned into strings: the caller owns the returned slice, and should wipe it once done.
+func BIP85Secret(app BIP85Application, length uint32, entropy []byte) ([]byte, error) {
+	if len(entropy) != 64 {
+		return nil, fmt.Errorf("BIP-85 entropy must be 64 bytes long, found %v", len(entropy))
+	}
+
+	switch app {
+	case BIP85Mnemonic:
+		// 32 bits of entropy every 3 words
+		return mnemonicSentence(entropy[:length*4/3])
+	case BIP85Hex:
+		ret := make([]byte, hex.EncodedLen(int(length)))
+		hex.Encode(ret, entropy[:length])
+		return ret, nil
+	case BIP85WIF:
+		return encodeWIF(entropy[:32])
+	case BIP85Password:
+		encoded := base85Encode(entropy)
+		defer Wipe(encoded)
+
+		return append([]byte{}, encoded[:length]...), nil
+	default:
+		return nil, fmt.Errorf("unknown BIP-85 application %v", app)
+	}
+}
+
+// encodeWIF returns the Wallet Import Format encoding of the compressed mainnet secp256k1 private key key.
+func encodeWIF(key []byte) ([]byte, error) {
+	if !validScalar(key, btcec.S256().N) {
+		return nil, fmt.Errorf("invalid secp256k1 private key")
+	}
+
+	payload := make([]byte, 0, 1+len(key)+1+4)
+	payload = append(payload, wifMainNet)
+	payload = append(payload, key...)
+	payload = append(payload, wifCompressedFlag)
+	defer Wipe(payload)
+
+	checksum := sha256.Sum256(payload)
+	checksum = sha256.Sum256(checksum[:])
+	defer Wipe(checksum[:])
+
+	payload = append(payload, checksum[:4]...)
+
+	return base58Encode(payload), nil
+}
+
+// base58Encode returns the base58 encoding of data, without the intermediate big integers and
+// strings btcutil would leave behind.
+func base58Encode(data []byte) []byte {
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256) / log(58), rounded up
+	digits := make([]byte, len(data)*138/100+1)
+	defer Wipe(digits)
+
+	length := 0
+	for _, b := range data {
+		carry := int(b)
+
+		i := 0
+		for j := len(digits) - 1; (carry != 0 || i < length) && j >= 0; j-- {
+			carry += 256 * int(digits[j])
+			digits[j] = byte(carry % 58)
+			carry /= 58
+			i++
+		}
+
+		length = i
+	}
+
+	ret := make([]byte, 0, zeros+length)
+	for i := 0; i < zeros; i++ {
+		ret = append(ret, base58Alphabet[0])
+	}
+
+	for _, d := range digits[len(digits)-length:] {
+		ret = append(ret, base58Alphabet[d])
+	}
+
+	return ret
+}
+
+// base85Encode returns the RFC 1924 base85 encoding of data, whose length must be a multiple of 4.
+func base85Encode(data []byte) []byte {
+	ret := make([]byte, len(data)/4*5)
+
+	for i := 0; i+4 <= len(data); i += 4 {
+		chunk := uint32(data[i])<<24 | uint32(data[i+1])<<16 | uint32(data[i+2])<<8 | uint32(data[i+3])
+
+		for j := 4; j >= 0; j-- {
+			ret[i/4*5+j] = base85Alphabet[chunk%85]
+			chunk /= 85
+		}
+	}
+
+	return ret
+}
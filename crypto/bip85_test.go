@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// BIP-85 test vectors master key
+const bip85Master = "xprv9s21ZrQH143K2LBWUUQRFXhucrQqBpKdRRxNVq2zBqsx8HVqFk2uYo8kmbaLLHRdqtQpUm98uKfu3vca1LqdGhUtyoFnCNkfmXRyPXLjbKb"
+
+func bip85Derive(t *testing.T, path DerivationPath) []byte {
+	t.Helper()
+
+	master, err := hdkeychain.NewKeyFromString(bip85Master)
+	require.NoError(t, err)
+
+	key, err := KeyFromPath(master, path)
+	require.NoError(t, err)
+
+	entropy, err := BIP85Entropy(key)
+	require.NoError(t, err)
+
+	return entropy
+}
+
+func TestBIP85Entropy(t *testing.T) {
+	tests := []struct {
+		path    string
+		entropy string
+	}{
+		{
+			"m/83696968'/0'/0'",
+			"efecfbccffea313214232d29e71563d941229afb4338c21f9517c41aaa0d16f00b83d2a09ef747e7a64e8e2bd5a14869e693da66ce94ac2da570ab7ee48618f7",
+		},
+		{
+			"m/83696968'/0'/1'",
+			"70c6e3e8ebee8dc4c0dbba66076819bb8c09672527c4277ca8729532ad711872218f826919f6b67218adde99018a6df9095ab2b58d803b5b93ec9802085a690e",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := ParseDerivationPath(tt.path)
+			require.NoError(t, err)
+
+			require.Equal(t, tt.entropy, hex.EncodeToString(bip85Derive(t, path)))
+		})
+	}
+}
+
+func TestBIP85Secret(t *testing.T) {
+	tests := []struct {
+		name   string
+		app    BIP85Application
+		length uint32
+		index  uint32
+		secret string
+	}{
+		{
+			"12 words mnemonic",
+			BIP85Mnemonic, 12, 0,
+			"girl mad pet galaxy egg matter matrix prison refuse sense ordinary nose",
+		},
+		{
+			"18 words mnemonic",
+			BIP85Mnemonic, 18, 0,
+			"near account window bike charge season chef number sketch tomorrow excuse sniff circle vital hockey outdoor supply token",
+		},
+		{
+			"24 words mnemonic",
+			BIP85Mnemonic, 24, 0,
+			"puppy ocean match cereal symbol another shed magic wrap hammer bulb intact gadget divorce twin tonight reason outdoor destroy simple truth cigar social volcano",
+		},
+		{
+			"hex",
+			BIP85Hex, 64, 0,
+			"492db4698cf3b73a5a24998aa3e9d7fa96275d85724a91e71aa2d645442f878555d078fd1f1f67e368976f04137b1f7a0d19232136ca50c44614af72b5582a5c",
+		},
+		{
+			"WIF",
+			BIP85WIF, 0, 0,
+			"Kzyv4uF39d4Jrw2W7UryTHwZr1zQVNk4dAFyqE6BuMrMh1Za7uhp",
+		},
+		{
+			"base85 password",
+			BIP85Password, 12, 0,
+			"_s`{TW89)i4`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := BIP85Path(tt.app, tt.length, tt.index)
+			require.NoError(t, err)
+
+			secret, err := BIP85Secret(tt.app, tt.length, bip85Derive(t, path))
+			require.NoError(t, err)
+			require.Equal(t, tt.secret, string(secret))
+		})
+	}
+}
+
+func TestBIP85PathRejectsInvalidParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		app    BIP85Application
+		length uint32
+		index  uint32
+	}{
+		{"mnemonic length", BIP85Mnemonic, 15, 0},
+		{"short hex", BIP85Hex, 15, 0},
+		{"long hex", BIP85Hex, 65, 0},
+		{"WIF length", BIP85WIF, 32, 0},
+		{"short password", BIP85Password, 9, 0},
+		{"long password", BIP85Password, 81, 0},
+		{"hardened index", BIP85Hex, 32, HardenedKeyStart},
+		{"unknown application", BIP85Password + 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BIP85Path(tt.app, tt.length, tt.index)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestBase58Encode(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0},
+		{0, 0, 1},
+		{0xff, 0xff, 0xff, 0xff},
+		[]byte("wallera base58 round"),
+	} {
+		require.Equal(t, base58.Encode(data), string(base58Encode(data)), data)
+	}
+}
+
+func Test_dumbToken_BIP85(t *testing.T) {
+	dt := seededToken(t)
+
+	secret, err := dt.BIP85(BIP85Mnemonic, 12, 0)
+	require.NoError(t, err)
+
+	// child mnemonics are valid BIP-39 ones, different from the device one
+	words := strings.Fields(string(secret))
+	require.NotEqual(t, standardMnemonic, words)
+
+	restored := NewDumbToken(storage.NewMemory())
+	require.NoError(t, restored.ImportSeed(words))
+
+	again, err := dt.BIP85(BIP85Mnemonic, 12, 0)
+	require.NoError(t, err)
+	require.Equal(t, secret, again)
+
+	other, err := dt.BIP85(BIP85Mnemonic, 12, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, secret, other)
+
+	// passphrases select other wallets, with other child secrets
+	require.NoError(t, dt.SetPassphrase([]byte("hidden wallet")))
+	hidden, err := dt.BIP85(BIP85Mnemonic, 12, 0)
+	require.NoError(t, err)
+	require.NotEqual(t, secret, hidden)
+
+	_, err = dt.BIP85(BIP85Password, 5, 0)
+	require.Error(t, err)
+}
@@ -1,10 +1,11 @@
 package crypto
 
 import (
-	"encoding/binary"
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcutil/hdkeychain"
+	"golang.org/x/crypto/curve25519"
 )
 
 //go:generate stringer -type=Algorithm
@@ -12,6 +13,10 @@ type Algorithm uint
 
 const (
 	AlgoSecp256K1 Algorithm = iota
+	AlgoSecp256K1Schnorr
+	AlgoX25519
+	AlgoEd25519
+	AlgoP256
 )
 
 var (
@@ -50,81 +55,124 @@ func Diversifier() []byte {
 
 // Token is a component which is in charge of executing cryptographic operation involving secrets, key derivation
 // and signature execution.
+// Every operation is given the derivation path and the algorithm of the key it uses: a Token holds no
+// per-operation state, and can be shared by every app.
 type Token interface {
 	RandomBytes(amount uint64) ([]byte, error)
-	DeriveSecret() ([32]byte, error)
-	Initialize(path DerivationPath) error
-	Sign(data []byte, algorithm Algorithm) ([]byte, error)
-	PublicKey() ([]byte, error)
-	Mnemonic() ([]string, error)
-	Clone() Token
+
+	// PublicKey returns the public key of algorithm at path: compressed points for the ECDSA and Schnorr
+	// algorithms, and 32 bytes keys for ed25519 and X25519.
+	PublicKey(path DerivationPath, algorithm Algorithm) ([]byte, error)
+
+	// AccountKey returns the extended public key at path, if AccountPathPolicy allows exporting it.
+	AccountKey(path DerivationPath) (AccountKey, error)
+
+	// SignDigest signs the DigestSize bytes digest the caller computed, with the key of algorithm at path.
+	// BIP-340 Schnorr signs digest as its 32 bytes message.
+	// Signatures are verified against the public key at path before being returned, and
+	// ErrFaultySignature is returned in place of the ones which don't verify.
+	SignDigest(path DerivationPath, algorithm Algorithm, digest []byte) ([]byte, error)
+
+	// SignMessage signs message, digested with hash, with the key of algorithm at path.
+	// ed25519 signs message as-is, and must be given HashNone.
+	// Signatures are verified like SignDigest ones.
+	SignMessage(path DerivationPath, algorithm Algorithm, hash Hash, message []byte) ([]byte, error)
+
+	// Fingerprint returns the BIP-32 fingerprint of the master key of the current wallet, that is the
+	// seed of the active profile with the current passphrase.
+	// Hosts can tell wallets apart with it, for instance when the active profile is switched.
+	Fingerprint() ([]byte, error)
+
+	// ECDH returns the key kdf derives from the shared secret between the key of algorithm at path and
+	// peerPublicKey, a secp256k1 or X25519 public key.
+	// The zero KDF returns the shared secret itself, other KDFs keep it on the Token.
+	ECDH(path DerivationPath, algorithm Algorithm, kdf KDF, peerPublicKey []byte) ([]byte, error)
+
 	SupportedSignAlgorithms() []Algorithm
 }
 
-type DerivationPath struct {
-	Purpose      uint32
-	CoinType     uint32
-	Account      uint32
-	Change       uint32
-	AddressIndex uint32
-}
+// Secrets reveals the secrets a Token derives its keys from.
+type Secrets interface {
+	// Mnemonic returns the BIP-39 mnemonic encoding the seed entropy, for SeedBIP39 seeds.
+	Mnemonic() ([]string, error)
 
-func NewDerivationPathFromBytes(purpose, coinType, account, change, addressIndex []byte) DerivationPath {
-	return DerivationPath{
-		Purpose:      0x80000000 ^ (binary.LittleEndian.Uint32(purpose)),
-		CoinType:     0x80000000 ^ (binary.LittleEndian.Uint32(coinType)),
-		Account:      0x80000000 ^ (binary.LittleEndian.Uint32(account)),
-		Change:       (binary.LittleEndian.Uint32(change)),
-		AddressIndex: (binary.LittleEndian.Uint32(addressIndex)),
-	}
-}
+	// SLIP39Shares splits the encrypted master secret of SeedSLIP39 seeds into SLIP-39 mnemonic shares,
+	// returned per group: groupThreshold of the groups recover it, each of them recovered by Threshold
+	// of its Count shares.
+	SLIP39Shares(groupThreshold int, groups []SLIP39Group) ([][][]string, error)
 
-// m / purpose' / coin_type' / account' / change / address_index
-func (d DerivationPath) String() string {
-	return fmt.Sprintf("m/%v'/%v'/%v'/%v/%v",
-		d.Purpose,
-		d.CoinType,
-		d.Account,
-		d.Change,
-		d.AddressIndex,
-	)
+	// BIP85 returns the index-th BIP-85 child secret of app, derived from the master key of the current
+	// wallet and formatted as ASCII text by BIP85Secret.
+	// length is the amount of mnemonic words, hex bytes or password characters, and 0 for WIF keys.
+	BIP85(app BIP85Application, length, index uint32) ([]byte, error)
 }
 
-// InOrder returns derivation path components in order left to right, as described by the definition string
-// specification.
-func (d DerivationPath) InOrder() []uint32 {
-	return []uint32{
-		d.Purpose,
-		d.CoinType,
-		d.Account,
-		d.Change,
-		d.AddressIndex,
+// KeyFromPath derives as new hdkeychain.ExtendedKey at a given path.
+// The intermediate keys are wiped, privateKey is left untouched.
+func KeyFromPath(privateKey *hdkeychain.ExtendedKey, path DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
 	}
-}
 
-// KeyFromPath derives as new hdkeychain.ExtendedKey at a given path, hardening purpose, coin type and account level by default.
-func KeyFromPath(privateKey *hdkeychain.ExtendedKey, path DerivationPath) (*hdkeychain.ExtendedKey, error) {
-	var child *hdkeychain.ExtendedKey
-	var err error
-
-	components := path.InOrder()
-	for idx, component := range components {
-		k := child
-		if k == nil {
-			k = privateKey
-		}
+	child := privateKey
+	for idx, component := range path {
+		parent := child
 
-		mustHarden := idx <= 2
+		var err error
+		child, err = parent.Child(component)
 
-		if mustHarden {
-			component = component + hdkeychain.HardenedKeyStart
+		if parent != privateKey {
+			WipeExtendedKey(parent)
 		}
-		child, err = k.Child(component)
 
 		if err != nil {
-			return nil, fmt.Errorf("cannot generate child key for path %v", components[:idx])
+			return nil, fmt.Errorf("cannot generate child key for path %v, %w", path[:idx+1], err)
 		}
 	}
 
 	return child, nil
 }
+
+// SharedPoint computes the ECDH shared point between key and the secp256k1 public key peer, and returns it
+// in uncompressed form.
+func SharedPoint(key *hdkeychain.ExtendedKey, peer []byte) ([]byte, error) {
+	pk, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeECPrivateKey(pk)
+
+	peerKey, err := btcec.ParsePubKey(peer, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse peer public key, %w", err)
+	}
+
+	d := pk.D.Bytes()
+	defer Wipe(d)
+
+	x, y := btcec.S256().ScalarMult(peerKey.X, peerKey.Y, d)
+
+	return (&btcec.PublicKey{
+		Curve: btcec.S256(),
+		X:     x,
+		Y:     y,
+	}).SerializeUncompressed(), nil
+}
+
+// X25519SharedSecret computes the X25519 shared secret between key and the Curve25519 public key peer.
+// The X25519 private scalar is the secp256k1 private key of key, clamped as mandated by RFC 7748.
+// The X25519 public key of key is the shared secret with curve25519.Basepoint.
+func X25519SharedSecret(key *hdkeychain.ExtendedKey, peer []byte) ([]byte, error) {
+	pk, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeECPrivateKey(pk)
+
+	scalar := bytes32(pk.D)
+	defer Wipe(scalar)
+
+	return curve25519.X25519(scalar, peer)
+}
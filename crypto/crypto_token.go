@@ -1,40 +1,45 @@
 package crypto
 
 import (
-	"crypto/hmac"
-	"crypto/rand"
-	"crypto/sha256"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"fmt"
-	"strings"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
-	"github.com/cosmos/go-bip39"
+	"github.com/wallera-computer/wallera/entropy"
+	"github.com/wallera-computer/wallera/storage"
+	"golang.org/x/crypto/curve25519"
 )
 
 // Compile-time check which fails if dumbToken doesn't comply with
-// crypto.Token interface.
-var _ Token = (*dumbToken)(nil)
-
-var defaultEntropy = []byte{
-	118, 252, 209, 103,
-	94, 240, 60, 245,
-	18, 224, 156, 240,
-	11, 232, 52, 25,
-	31, 134, 125, 135,
-	192, 2, 31, 206,
-	216, 100, 159, 234,
-	150, 9, 236, 57,
-}
+// crypto.DeviceToken interface.
+var _ DeviceToken = (*dumbToken)(nil)
 
+// dumbToken keeps no key material between operations: seeds and keys are derived in buffers owned by
+// each operation, which wipes them before returning.
+// Only its session, holding the passphrase, and its KeyCache outlive operations.
 type dumbToken struct {
-	privKey *hdkeychain.ExtendedKey
+	storage storage.Storage
+	session Session
+
+	cache *KeyCache
 }
 
-// NewDumbToken returns a new instance of dumbToken.
-// Callers should Clone() this instance and then call Initialize().
-func NewDumbToken() Token {
-	return &dumbToken{}
+// NewDumbToken returns a new instance of dumbToken, which keeps its seed in s.
+func NewDumbToken(s storage.Storage) DeviceToken {
+	cache, err := NewKeyCache(DefaultKeyCacheSize)
+	if err != nil {
+		// only fails if the random number generator does
+		panic(err)
+	}
+
+	return &dumbToken{
+		storage: s,
+		cache:   cache,
+	}
 }
 
 func (dt *dumbToken) RandomBytes(amount uint64) ([]byte, error) {
@@ -43,7 +48,7 @@ func (dt *dumbToken) RandomBytes(amount uint64) ([]byte, error) {
 	}
 
 	b := make([]byte, amount)
-	_, err := rand.Read(b)
+	_, err := entropy.Read(b)
 	if err != nil {
 		return nil, err
 	}
@@ -51,94 +56,452 @@ func (dt *dumbToken) RandomBytes(amount uint64) ([]byte, error) {
 	return b, nil
 }
 
-func (dt *dumbToken) DeriveSecret() ([32]byte, error) {
-	h := hmac.New(sha256.New, Diversifier())
-	if _, err := h.Write(defaultEntropy); err != nil {
-		return [32]byte{}, fmt.Errorf("cannot generate secret, %w", err)
+func (dt *dumbToken) HasSeed() (bool, error) {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return false, err
 	}
 
-	res := h.Sum(nil)
-	if res == nil {
-		panic(fmt.Errorf("hmac.SHA256 sum result empty"))
-	}
+	return HasSeed(s)
+}
+
+func (dt *dumbToken) HasAnySeed() (bool, error) {
+	return AnySeed(dt.storage)
+}
 
-	ret := [32]byte{}
-	copy(ret[:], res)
+func (dt *dumbToken) GenerateSeed(entropyBits int) error {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return err
+	}
 
-	return ret, nil
+	return GenerateSeed(dt, s, entropyBits)
 }
 
-func (dt *dumbToken) Initialize(path DerivationPath) error {
-	secret, err := dt.DeriveSecret()
+func (dt *dumbToken) ImportSeed(words []string) error {
+	s, err := dt.seedStorage()
 	if err != nil {
 		return err
 	}
 
-	params := chaincfg.MainNetParams
-	params.HDCoinType = path.CoinType
+	dt.cache.Purge()
+	return ImportSeed(s, words)
+}
 
-	sb, err := hdkeychain.NewMaster(secret[:], &params)
+func (dt *dumbToken) ImportSLIP39Shares(shares [][]string) error {
+	s, err := dt.seedStorage()
 	if err != nil {
 		return err
 	}
 
-	dt.privKey, err = KeyFromPath(sb, path)
+	dt.cache.Purge()
+	return ImportSLIP39Seed(s, shares)
+}
+
+func (dt *dumbToken) Profiles() ([]string, string, error) {
+	s := SeedStorage(dt.storage, dt.session)
+
+	labels, err := Profiles(s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	active, err := ActiveProfile(s)
 	if err != nil {
+		return nil, "", err
+	}
+
+	return labels, active, nil
+}
+
+func (dt *dumbToken) SelectProfile(label string) error {
+	if err := SelectProfile(SeedStorage(dt.storage, dt.session), label); err != nil {
+		return err
+	}
+
+	// passphrases select wallets of the seed they have been set for
+	Wipe(dt.session.Passphrase)
+	dt.session.Passphrase = nil
+	return nil
+}
+
+func (dt *dumbToken) DeleteProfile(label string) error {
+	dt.cache.Purge()
+	return DeleteProfile(SeedStorage(dt.storage, dt.session), label)
+}
+
+func (dt *dumbToken) SetPassphrase(passphrase []byte) error {
+	if err := ValidPassphrase(passphrase); err != nil {
 		return err
 	}
 
+	Wipe(dt.session.Passphrase)
+	dt.session.Passphrase = append([]byte{}, passphrase...)
 	return nil
 }
 
-func (dt *dumbToken) Sign(data []byte, algorithm Algorithm) ([]byte, error) {
-	pk, err := dt.privKey.ECPrivKey()
+func (dt *dumbToken) UseDecoy(decoy bool) {
+	dt.session.Decoy = decoy
+}
+
+func (dt *dumbToken) Wipe() error {
+	dt.session.Wipe()
+	dt.cache.Purge()
+	return WipeSeed(dt.storage)
+}
+
+// seedStorage returns the storage holding the seed of the active profile, selected by the current session.
+func (dt *dumbToken) seedStorage() (storage.Storage, error) {
+	return ActiveProfileStorage(SeedStorage(dt.storage, dt.session))
+}
+
+// readSeed returns the seed of the active profile, selected by the current session.
+// The caller must wipe it once done.
+func (dt *dumbToken) readSeed() (Seed, error) {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return Seed{}, err
+	}
+
+	return ReadSeed(s)
+}
+
+func (dt *dumbToken) Fingerprint() ([]byte, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(seed)
+
+	return Fingerprint(seed)
+}
+
+// masterSeed returns the BIP-32 seed of the device, for the current passphrase.
+// The caller must wipe it once done.
+func (dt *dumbToken) masterSeed() ([]byte, error) {
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	return dt.cache.MasterSeed(seed, dt.session.Passphrase)
+}
+
+// key returns the secp256k1 extended key at path.
+// The caller must wipe it once done.
+func (dt *dumbToken) key(path DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(seed)
+
+	return dt.cache.Derive(seed, path)
+}
+
+// ecKey returns the secp256k1 private key at path.
+// The caller must wipe it once done.
+func (dt *dumbToken) ecKey(path DerivationPath) (*btcec.PrivateKey, error) {
+	key, err := dt.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeExtendedKey(key)
+
+	return key.ECPrivKey()
+}
+
+func (dt *dumbToken) SignDigest(path DerivationPath, algorithm Algorithm, digest []byte) ([]byte, error) {
+	if err := CheckDigest(algorithm, digest); err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case AlgoSecp256K1, AlgoSecp256K1Schnorr:
+		pk, err := dt.ecKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeECPrivateKey(pk)
+
+		publicKey := pk.PubKey().SerializeCompressed()
+
+		if algorithm == AlgoSecp256K1Schnorr {
+			aux, err := dt.RandomBytes(32)
+			if err != nil {
+				return nil, err
+			}
+
+			signature, err := SignSchnorr(pk, digest, aux)
+			if err != nil {
+				return nil, err
+			}
+
+			return ReleaseSignature(algorithm, publicKey, digest, signature)
+		}
+
+		signature, err := pk.Sign(digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return ReleaseSignature(algorithm, publicKey, digest, signature.Serialize())
+	case AlgoP256:
+		seed, err := dt.masterSeed()
+		if err != nil {
+			return nil, err
+		}
+
+		defer Wipe(seed)
+
+		key, err := SLIP10P256Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeECDSAPrivateKey(key)
+
+		signature, err := ecdsa.SignASN1(entropy.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey := elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+
+		return ReleaseSignature(algorithm, publicKey, digest, signature)
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %v", algorithm)
+	}
+}
+
+func (dt *dumbToken) SignMessage(path DerivationPath, algorithm Algorithm, hash Hash, message []byte) ([]byte, error) {
+	digest, err := MessageDigest(algorithm, hash, message)
 	if err != nil {
 		return nil, err
 	}
 
-	signature, err := pk.Sign(data)
+	if digest != nil {
+		return dt.SignDigest(path, algorithm, digest)
+	}
+
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(seed)
+
+	key, err := SLIP10Ed25519Key(seed, path)
 	if err != nil {
 		return nil, err
 	}
 
-	return signature.Serialize(), nil
+	defer WipeEd25519PrivateKey(key)
+
+	return ReleaseSignature(algorithm, key.Public().(ed25519.PublicKey), message, ed25519.Sign(key, message))
 }
 
-func (dt *dumbToken) PublicKey() ([]byte, error) {
-	epubk, err := dt.privKey.Neuter()
+func (dt *dumbToken) ECDH(path DerivationPath, algorithm Algorithm, kdf KDF, peerPublicKey []byte) ([]byte, error) {
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := dt.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeExtendedKey(key)
+
+	var secret []byte
+
+	switch algorithm {
+	case AlgoSecp256K1:
+		secret, err = SharedPoint(key, peerPublicKey)
+	case AlgoX25519:
+		secret, err = X25519SharedSecret(key, peerPublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported ECDH algorithm %v", algorithm)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	pp, err := epubk.ECPubKey()
+	defer Wipe(secret)
+
+	return kdf.Derive(algorithm, secret)
+}
+
+func (dt *dumbToken) PublicKey(path DerivationPath, algorithm Algorithm) ([]byte, error) {
+	seed, err := dt.masterSeed()
 	if err != nil {
 		return nil, err
 	}
 
-	return pp.SerializeCompressed(), nil
+	defer Wipe(seed)
+
+	switch algorithm {
+	case AlgoSecp256K1, AlgoSecp256K1Schnorr:
+		key, err := dt.cache.Derive(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeExtendedKey(key)
+
+		pp, err := key.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return pp.SerializeCompressed(), nil
+	case AlgoX25519:
+		key, err := dt.cache.Derive(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeExtendedKey(key)
+
+		return X25519SharedSecret(key, curve25519.Basepoint)
+	case AlgoEd25519:
+		key, err := SLIP10Ed25519Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeEd25519PrivateKey(key)
+
+		return append([]byte{}, key.Public().(ed25519.PublicKey)...), nil
+	case AlgoP256:
+		key, err := SLIP10P256Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer WipeECDSAPrivateKey(key)
+
+		return elliptic.MarshalCompressed(key.Curve, key.X, key.Y), nil
+	default:
+		return nil, fmt.Errorf("unsupported public key algorithm %v", algorithm)
+	}
+}
+
+func (dt *dumbToken) AccountKey(path DerivationPath) (AccountKey, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	defer Wipe(seed)
+
+	return NewAccountKey(seed, path)
 }
 
 func (dt *dumbToken) Mnemonic() ([]string, error) {
-	secret, err := dt.DeriveSecret()
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	if seed.Type != SeedBIP39 {
+		return nil, fmt.Errorf("seeds recovered from SLIP-39 shares have no BIP-39 mnemonic")
+	}
+
+	indexes, err := MnemonicIndexes(seed.Entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeIndexes(indexes)
+
+	return MnemonicWords(indexes)
+}
+
+func (dt *dumbToken) SLIP39Shares(groupThreshold int, groups []SLIP39Group) ([][][]string, error) {
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	// SLIP-39 shares of BIP-39 entropy would restore another wallet on any other SLIP-39 device
+	if seed.Type != SeedSLIP39 {
+		return nil, fmt.Errorf("BIP-39 seeds can't be split into SLIP-39 shares, back up their mnemonic instead")
+	}
+
+	return SLIP39SplitEncrypted(entropy.Reader, seed.SLIP39, groupThreshold, groups)
+}
+
+func (dt *dumbToken) BIP85(app BIP85Application, length, index uint32) ([]byte, error) {
+	path, err := BIP85Path(app, length, index)
 	if err != nil {
 		return nil, err
 	}
 
-	mnemonic, err := bip39.NewMnemonic(secret[:])
+	seed, err := dt.masterSeed()
 	if err != nil {
 		return nil, err
 	}
 
-	return strings.Split(mnemonic, " "), nil
+	defer Wipe(seed)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeExtendedKey(master)
+
+	// child secrets are derived outside of the KeyCache, which would keep their nodes around
+	key, err := KeyFromPath(master, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeExtendedKey(key)
+
+	entropy, err := BIP85Entropy(key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(entropy)
+
+	return BIP85Secret(app, length, entropy)
 }
 
 func (dt *dumbToken) SupportedSignAlgorithms() []Algorithm {
 	return []Algorithm{
 		AlgoSecp256K1,
+		AlgoSecp256K1Schnorr,
+		AlgoEd25519,
+		AlgoP256,
 	}
 }
 
-func (dt *dumbToken) Clone() Token {
-	cl := *dt
-	return &cl
+func (dt *dumbToken) AttestationCSR() ([]byte, error) {
+	return AttestationCSR(dt.storage)
+}
+
+func (dt *dumbToken) SetAttestationChain(chain []byte) error {
+	return SetAttestationChain(dt.storage, chain)
+}
+
+func (dt *dumbToken) AttestationChain() ([]byte, error) {
+	return AttestationChain(dt.storage)
+}
+
+func (dt *dumbToken) Attest(challenge []byte, revision string) ([]byte, error) {
+	return Attest(dt.storage, challenge, revision)
 }
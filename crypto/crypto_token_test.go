@@ -2,27 +2,40 @@ package crypto
 
 import (
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/cosmos/go-bip39"
 	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
 )
 
 const (
-	standardSecret = "10cbc65c342e07b730ffb0f48fe2c41e9776363c73ade5e699c4cccb1257188d"
-	standardPubkey = "029ac8e45aa5c4bf453980d6f5fce78f6b7f55b42add2e7700709320ac8da8bb9e"
+	standardPubkey = "033a6301fc2c4615abd777dc0ae8dce626d4feddec7b708d52d0d967fdce48b100"
 )
 
 var (
-	standardMnemonic = []string{"awkward", "funny", "novel", "hair", "there", "swear", "sentence", "voyage", "virus", "lecture", "rain", "diamond", "jazz", "brand", "vault", "interest", "tourist", "crumble", "illness", "office", "raise", "clog", "giraffe", "pencil"}
+	standardEntropy = []byte{
+		118, 252, 209, 103,
+		94, 240, 60, 245,
+		18, 224, 156, 240,
+		11, 232, 52, 25,
+		31, 134, 125, 135,
+		192, 2, 31, 206,
+		216, 100, 159, 234,
+		150, 9, 236, 57,
+	}
+
+	standardMnemonic = []string{"ivory", "track", "flush", "sadness", "adult", "kind", "entire", "bean", "useless", "gap", "artist", "cram", "wear", "disagree", "business", "able", "cabin", "item", "bomb", "divert", "practice", "agent", "rail", "charge"}
 )
 
-func secretBytes(t *testing.T) [32]byte {
+// seededToken returns a dumbToken set up with standardEntropy.
+func seededToken(t *testing.T) *dumbToken {
 	t.Helper()
-	h, err := hex.DecodeString(standardSecret)
-	require.NoError(t, err)
-	ret := [32]byte{}
-	copy(ret[:], h)
-	return ret
+	s := storage.NewMemory()
+	require.NoError(t, s.Set(seedKey, standardEntropy))
+	return NewDumbToken(s).(*dumbToken)
 }
 
 func pubKeyBytes(t *testing.T) []byte {
@@ -33,14 +46,7 @@ func pubKeyBytes(t *testing.T) []byte {
 }
 
 func Test_dumbToken_MnemonicReturnsAFullSlice(t *testing.T) {
-	dt := &dumbToken{}
-	require.NoError(t, dt.Initialize(DerivationPath{
-		Purpose:      44,
-		CoinType:     118,
-		Account:      0,
-		Change:       0,
-		AddressIndex: 0,
-	}))
+	dt := seededToken(t)
 
 	m, err := dt.Mnemonic()
 
@@ -49,38 +55,10 @@ func Test_dumbToken_MnemonicReturnsAFullSlice(t *testing.T) {
 	require.Equal(t, standardMnemonic, m)
 }
 
-func Test_dumbToken_DeriveSecretReturnsExpectedSecret(t *testing.T) {
-	dt := &dumbToken{}
-	s, err := dt.DeriveSecret()
-	require.NoError(t, err)
-	require.NotNil(t, s)
-	require.Equal(t, secretBytes(t), s)
-}
-
-func Test_dumbToken_DeriveSecretIsIdempotent(t *testing.T) {
-	dt := &dumbToken{}
-	s1, err := dt.DeriveSecret()
-	require.NoError(t, err)
-	require.NotNil(t, s1)
-
-	s2, err := dt.DeriveSecret()
-	require.NoError(t, err)
-	require.NotNil(t, s2)
-
-	require.Equal(t, s1, s2)
-}
-
 func Test_dumbToken_PublicKey(t *testing.T) {
-	dt := &dumbToken{}
-	require.NoError(t, dt.Initialize(DerivationPath{
-		Purpose:      44,
-		CoinType:     118,
-		Account:      0,
-		Change:       0,
-		AddressIndex: 0,
-	}))
+	dt := seededToken(t)
 
-	pk, err := dt.PublicKey()
+	pk, err := dt.PublicKey(BIP44Path(118, 0, 0, 0), AlgoSecp256K1)
 
 	require.NoError(t, err)
 	require.NotNil(t, pk)
@@ -115,7 +93,7 @@ func Test_dumbToken_RandomBytes(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			dt := &dumbToken{}
+			dt := seededToken(t)
 			got, err := dt.RandomBytes(tt.amount)
 			tt.errAssertion(t, err)
 			tt.dataAssertion(t, got)
@@ -123,3 +101,249 @@ func Test_dumbToken_RandomBytes(t *testing.T) {
 		})
 	}
 }
+
+func Test_dumbToken_FreshDeviceHasNoSeed(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory())
+
+	found, err := dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, err = dt.Mnemonic()
+	require.ErrorIs(t, err, ErrNoSeed)
+
+	_, err = dt.PublicKey(DerivationPath{}, AlgoSecp256K1)
+	require.ErrorIs(t, err, ErrNoSeed)
+}
+
+func Test_dumbToken_GenerateSeed(t *testing.T) {
+	tests := []struct {
+		entropyBits int
+		words       int
+	}{
+		{128, 12},
+		{192, 18},
+		{256, 24},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v bits", tt.entropyBits), func(t *testing.T) {
+			dt := NewDumbToken(storage.NewMemory())
+			require.NoError(t, dt.GenerateSeed(tt.entropyBits))
+
+			found, err := dt.HasSeed()
+			require.NoError(t, err)
+			require.True(t, found)
+
+			m, err := dt.Mnemonic()
+			require.NoError(t, err)
+			require.Len(t, m, tt.words)
+
+			_, err = dt.PublicKey(DerivationPath{}, AlgoSecp256K1)
+			require.NoError(t, err)
+
+			// an existing seed is never overwritten
+			require.Error(t, dt.GenerateSeed(tt.entropyBits))
+
+			again, err := dt.Mnemonic()
+			require.NoError(t, err)
+			require.Equal(t, m, again)
+		})
+	}
+}
+
+func Test_dumbToken_GenerateSeedRejectsUnsupportedSizes(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory())
+	require.Error(t, dt.GenerateSeed(0))
+	require.Error(t, dt.GenerateSeed(160))
+
+	found, err := dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func Test_dumbToken_SeedsAreUnique(t *testing.T) {
+	a := NewDumbToken(storage.NewMemory())
+	b := NewDumbToken(storage.NewMemory())
+	require.NoError(t, a.GenerateSeed(256))
+	require.NoError(t, b.GenerateSeed(256))
+
+	ma, err := a.Mnemonic()
+	require.NoError(t, err)
+
+	mb, err := b.Mnemonic()
+	require.NoError(t, err)
+
+	require.NotEqual(t, ma, mb)
+}
+
+func Test_dumbToken_ImportSeed(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory())
+	require.NoError(t, dt.ImportSeed(standardMnemonic))
+
+	m, err := dt.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, standardMnemonic, m)
+
+	pk, err := dt.PublicKey(BIP44Path(118, 0, 0, 0), AlgoSecp256K1)
+	require.NoError(t, err)
+	require.Equal(t, pubKeyBytes(t), pk)
+}
+
+func Test_dumbToken_ImportSeedReplacesExistingSeed(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory())
+	require.NoError(t, dt.GenerateSeed(128))
+	require.NoError(t, dt.ImportSeed(standardMnemonic))
+
+	m, err := dt.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, standardMnemonic, m)
+}
+
+func Test_dumbToken_ImportSeedLengths(t *testing.T) {
+	for _, bits := range []int{128, 192, 256} {
+		t.Run(fmt.Sprintf("%v bits", bits), func(t *testing.T) {
+			src := NewDumbToken(storage.NewMemory())
+			entropy, err := src.RandomBytes(uint64(bits / 8))
+			require.NoError(t, err)
+
+			words, err := bip39.NewMnemonic(entropy)
+			require.NoError(t, err)
+
+			dt := NewDumbToken(storage.NewMemory())
+			require.NoError(t, dt.ImportSeed(strings.Fields(words)))
+
+			m, err := dt.Mnemonic()
+			require.NoError(t, err)
+			require.Equal(t, strings.Fields(words), m)
+		})
+	}
+}
+
+func Test_dumbToken_ImportSeedRejectsInvalidMnemonics(t *testing.T) {
+	badChecksum := append([]string{}, standardMnemonic...)
+	badChecksum[len(badChecksum)-1] = "abandon"
+
+	unknownWord := append([]string{}, standardMnemonic...)
+	unknownWord[3] = "wallera"
+
+	tests := []struct {
+		name  string
+		words []string
+	}{
+		{"bad checksum", badChecksum},
+		{"unknown word", unknownWord},
+		{"too short", standardMnemonic[:11]},
+		{"unsupported length", standardMnemonic[:15]},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt := NewDumbToken(storage.NewMemory())
+			require.Error(t, dt.ImportSeed(tt.words))
+
+			found, err := dt.HasSeed()
+			require.NoError(t, err)
+			require.False(t, found)
+		})
+	}
+}
+
+func TestMasterSeed(t *testing.T) {
+	// BIP-39 test vector
+	seed, err := MasterSeed(make([]byte, 16), []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t,
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		hex.EncodeToString(seed),
+	)
+
+	_, err = MasterSeed(make([]byte, 16), []byte("caffè"))
+	require.Error(t, err)
+}
+
+func TestFingerprint(t *testing.T) {
+	// BIP-32 test vector 1
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	fp, err := Fingerprint(seed)
+	require.NoError(t, err)
+	require.Equal(t, "3442193e", hex.EncodeToString(fp))
+}
+
+func Test_dumbToken_Passphrase(t *testing.T) {
+	path := BIP44Path(118, 0, 0, 0)
+
+	dt := seededToken(t)
+	standardFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+
+	require.NoError(t, dt.SetPassphrase([]byte("hidden wallet")))
+	hiddenFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.NotEqual(t, standardFp, hiddenFp)
+
+	pk, err := dt.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.NotEqual(t, pubKeyBytes(t), pk)
+
+	// the mnemonic doesn't depend on the passphrase
+	m, err := dt.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, standardMnemonic, m)
+
+	require.Error(t, dt.SetPassphrase([]byte("\n")))
+
+	require.NoError(t, dt.SetPassphrase([]byte("")))
+	fp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, standardFp, fp)
+
+	pk, err = dt.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.Equal(t, pubKeyBytes(t), pk)
+}
+
+func Test_dumbToken_Decoy(t *testing.T) {
+	dt := seededToken(t)
+	standardFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+
+	dt.UseDecoy(true)
+
+	found, err := dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, dt.GenerateSeed(256))
+
+	decoyFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.NotEqual(t, standardFp, decoyFp)
+
+	m, err := dt.Mnemonic()
+	require.NoError(t, err)
+	require.NotEqual(t, standardMnemonic, m)
+
+	dt.UseDecoy(false)
+
+	fp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, standardFp, fp)
+}
+
+func Test_dumbToken_Wipe(t *testing.T) {
+	dt := seededToken(t)
+
+	dt.UseDecoy(true)
+	require.NoError(t, dt.GenerateSeed(128))
+
+	require.NoError(t, dt.Wipe())
+
+	for _, decoy := range []bool{false, true} {
+		dt.UseDecoy(decoy)
+		found, err := dt.HasSeed()
+		require.NoError(t, err)
+		require.False(t, found)
+	}
+}
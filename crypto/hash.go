@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+//go:generate stringer -type=Hash
+type Hash uint
+
+// Hashes Token.SignMessage digests messages with.
+const (
+	// HashNone signs messages as-is, which only ed25519 does.
+	HashNone Hash = iota
+	HashSHA256
+	HashDoubleSHA256
+	HashKeccak256
+)
+
+// Digest returns the digest of message.
+func (h Hash) Digest(message []byte) ([]byte, error) {
+	switch h {
+	case HashSHA256:
+		d := sha256.Sum256(message)
+		return d[:], nil
+	case HashDoubleSHA256:
+		d := sha256.Sum256(message)
+		d = sha256.Sum256(d[:])
+		return d[:], nil
+	case HashKeccak256:
+		k := sha3.NewLegacyKeccak256()
+		k.Write(message)
+		return k.Sum(nil), nil
+	default:
+		return nil, fmt.Errorf("cannot digest messages with %v", h)
+	}
+}
+
+// DigestSize is the size of the digests signed by the ECDSA and Schnorr algorithms.
+const DigestSize = 32
+
+// CheckDigest returns an error if algorithm can't sign digest.
+// ed25519 only signs messages, since it hashes them along with the key.
+func CheckDigest(algorithm Algorithm, digest []byte) error {
+	switch algorithm {
+	case AlgoSecp256K1, AlgoSecp256K1Schnorr, AlgoP256:
+		if len(digest) != DigestSize {
+			return fmt.Errorf("%v digests must be %v bytes long", algorithm, DigestSize)
+		}
+
+		return nil
+	case AlgoEd25519:
+		return fmt.Errorf("%v signs messages, not digests", algorithm)
+	default:
+		return fmt.Errorf("unsupported signature algorithm %v", algorithm)
+	}
+}
+
+// MessageDigest returns the digest of message a Token signs with algorithm, or nil if
+// algorithm signs message as-is.
+func MessageDigest(algorithm Algorithm, hash Hash, message []byte) ([]byte, error) {
+	if algorithm == AlgoEd25519 {
+		if hash != HashNone {
+			return nil, fmt.Errorf("%v signs messages as-is, not their %v digest", algorithm, hash)
+		}
+
+		return nil, nil
+	}
+
+	digest, err := hash.Digest(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return digest, CheckDigest(algorithm, digest)
+}
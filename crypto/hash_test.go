@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashDigest(t *testing.T) {
+	tests := []struct {
+		hash     Hash
+		expected string
+	}{
+		{HashSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{HashDoubleSHA256, "4f8b42c22dd3729b519ba6f68d2da7cc5b2d606d05daed5ad5128cc03e6c6358"},
+		{HashKeccak256, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hash.String(), func(t *testing.T) {
+			d, err := tt.hash.Digest([]byte("abc"))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, hex.EncodeToString(d))
+		})
+	}
+
+	_, err := HashNone.Digest([]byte("abc"))
+	require.Error(t, err)
+}
+
+func TestMessageDigest(t *testing.T) {
+	d, err := MessageDigest(AlgoEd25519, HashNone, []byte("abc"))
+	require.NoError(t, err)
+	require.Nil(t, d)
+
+	_, err = MessageDigest(AlgoEd25519, HashSHA256, []byte("abc"))
+	require.Error(t, err)
+
+	_, err = MessageDigest(AlgoSecp256K1, HashNone, []byte("abc"))
+	require.Error(t, err)
+
+	_, err = MessageDigest(AlgoX25519, HashSHA256, []byte("abc"))
+	require.Error(t, err)
+
+	require.Error(t, CheckDigest(AlgoP256, make([]byte, 20)))
+	require.NoError(t, CheckDigest(AlgoP256, make([]byte, DigestSize)))
+}
+
+func Test_dumbToken_SignMessage(t *testing.T) {
+	dt := seededToken(t)
+	path := BIP44Path(118, 0, 0, 0)
+	message := []byte("message")
+
+	pk, err := dt.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+
+	pub, err := btcec.ParsePubKey(pk, btcec.S256())
+	require.NoError(t, err)
+
+	der, err := dt.SignMessage(path, AlgoSecp256K1, HashDoubleSHA256, message)
+	require.NoError(t, err)
+
+	sig, err := btcec.ParseDERSignature(der, btcec.S256())
+	require.NoError(t, err)
+
+	// the message is hashed once, by the token
+	digest, err := HashDoubleSHA256.Digest(message)
+	require.NoError(t, err)
+	require.True(t, sig.Verify(digest, pub))
+
+	_, err = dt.SignDigest(path, AlgoSecp256K1, message)
+	require.Error(t, err)
+}
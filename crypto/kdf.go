@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+//go:generate stringer -type=KDFAlgorithm
+type KDFAlgorithm uint
+
+// Key derivation functions Token.ECDH applies to shared secrets.
+const (
+	// KDFNone returns the shared secret as-is: the uncompressed shared point for secp256k1, and the
+	// 32 bytes X25519 output.
+	KDFNone KDFAlgorithm = iota
+
+	// KDFSHA256 returns the SHA-256 digest of the shared secret KDFNone returns.
+	KDFSHA256
+
+	// KDFHKDFSHA256 returns the RFC 5869 HKDF-SHA256 of Z, the shared point x-coordinate for secp256k1
+	// and the X25519 output, with Salt and Info.
+	KDFHKDFSHA256
+
+	// KDFX963SHA256 returns the ANSI X9.63 KDF of Z with SHA-256 and Info as shared info, like SEC 1 ECIES.
+	KDFX963SHA256
+)
+
+const (
+	// DefaultKDFLength is the size of the keys derived by KDFs whose KDF.Length is 0.
+	DefaultKDFLength = 32
+
+	// MaxKDFLength is the maximum size of the keys KDFs derive, enough for an encryption and a MAC key.
+	MaxKDFLength = 64
+
+	// MaxKDFParameterSize is the maximum size of KDF salts and infos.
+	MaxKDFParameterSize = 256
+)
+
+// KDF is a key derivation function and its parameters.
+// The zero KDF returns shared secrets as-is.
+type KDF struct {
+	Algorithm KDFAlgorithm
+
+	// Salt is the HKDF salt, only KDFHKDFSHA256 takes it.
+	Salt []byte
+
+	// Info is the HKDF info or the X9.63 shared info.
+	Info []byte
+
+	// Length is the size of the derived key, DefaultKDFLength if 0.
+	// KDFNone and KDFSHA256 have a fixed output size, and must be given 0.
+	Length int
+}
+
+// Validate returns an error if k parameters don't suit its algorithm.
+func (k KDF) Validate() error {
+	if len(k.Salt) > MaxKDFParameterSize || len(k.Info) > MaxKDFParameterSize {
+		return fmt.Errorf("KDF salt and info must be at most %v bytes long", MaxKDFParameterSize)
+	}
+
+	switch k.Algorithm {
+	case KDFNone, KDFSHA256:
+		if len(k.Salt) != 0 || len(k.Info) != 0 || k.Length != 0 {
+			return fmt.Errorf("%v takes no parameters", k.Algorithm)
+		}
+	case KDFX963SHA256:
+		if len(k.Salt) != 0 {
+			return fmt.Errorf("%v takes no salt", k.Algorithm)
+		}
+
+		fallthrough
+	case KDFHKDFSHA256:
+		if k.Length < 0 || k.Length > MaxKDFLength {
+			return fmt.Errorf("%v keys must be at most %v bytes long", k.Algorithm, MaxKDFLength)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF %v", k.Algorithm)
+	}
+
+	return nil
+}
+
+// Derive returns the key k derives from secret, the shared secret ECDH returns with KDFNone.
+// secret is left untouched.
+func (k KDF) Derive(algorithm Algorithm, secret []byte) ([]byte, error) {
+	if err := k.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch k.Algorithm {
+	case KDFNone:
+		return append([]byte{}, secret...), nil
+	case KDFSHA256:
+		d := sha256.Sum256(secret)
+		return d[:], nil
+	}
+
+	z, err := sharedZ(algorithm, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	length := k.Length
+	if length == 0 {
+		length = DefaultKDFLength
+	}
+
+	key := make([]byte, length)
+
+	switch k.Algorithm {
+	case KDFHKDFSHA256:
+		if _, err := io.ReadFull(hkdf.New(sha256.New, z, k.Salt, k.Info), key); err != nil {
+			Wipe(key)
+			return nil, err
+		}
+	case KDFX963SHA256:
+		x963(key, z, k.Info)
+	}
+
+	return key, nil
+}
+
+// sharedZ returns the shared secret Z of secret, as SEC 1 and RFC 7748 define it.
+// Z shares its memory with secret.
+func sharedZ(algorithm Algorithm, secret []byte) ([]byte, error) {
+	switch algorithm {
+	case AlgoSecp256K1:
+		// 0x04 || x || y
+		if len(secret) != 65 {
+			return nil, fmt.Errorf("malformed secp256k1 shared point")
+		}
+
+		return secret[1:33], nil
+	case AlgoX25519:
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported ECDH algorithm %v", algorithm)
+	}
+}
+
+// x963 fills key with the ANSI X9.63 KDF of z and info: the concatenated SHA-256 digests of z, a
+// 32 bits big-endian counter starting from 1, and info.
+func x963(key, z, info []byte) {
+	counter := make([]byte, 4)
+
+	for i, done := uint32(1), 0; done < len(key); i++ {
+		binary.BigEndian.PutUint32(counter, i)
+
+		h := sha256.New()
+		h.Write(z)
+		h.Write(counter)
+		h.Write(info)
+
+		block := h.Sum(nil)
+		done += copy(key[done:], block)
+		Wipe(block)
+	}
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKDFDerive(t *testing.T) {
+	// RFC 5869 test case 1
+	hkdfKey, err := KDF{
+		Algorithm: KDFHKDFSHA256,
+		Salt:      mustHex(t, "000102030405060708090a0b0c"),
+		Info:      mustHex(t, "f0f1f2f3f4f5f6f7f8f9"),
+		Length:    42,
+	}.Derive(AlgoX25519, bytes.Repeat([]byte{0x0b}, 22))
+	require.NoError(t, err)
+	require.Equal(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865", hex.EncodeToString(hkdfKey))
+
+	// NIST CAVS ANSI X9.63 SHA-256 vector, without shared info
+	x963Key, err := KDF{
+		Algorithm: KDFX963SHA256,
+		Length:    16,
+	}.Derive(AlgoX25519, mustHex(t, "96c05619d56c328ab95fe84b18264b08725b85e33fd34f08"))
+	require.NoError(t, err)
+	require.Equal(t, "443024c3dae66b95e6f5670601558f71", hex.EncodeToString(x963Key))
+
+	// secp256k1 KDFs are given the shared point x-coordinate
+	point := append([]byte{0x04}, bytes.Repeat([]byte{0x0b}, 22)...)
+	point = append(point, make([]byte, 64-22)...)
+
+	pointKey, err := KDF{
+		Algorithm: KDFHKDFSHA256,
+		Salt:      mustHex(t, "000102030405060708090a0b0c"),
+		Info:      mustHex(t, "f0f1f2f3f4f5f6f7f8f9"),
+		Length:    42,
+	}.Derive(AlgoSecp256K1, point)
+	require.NoError(t, err)
+	require.NotEqual(t, hkdfKey, pointKey)
+
+	_, err = KDF{Algorithm: KDFHKDFSHA256}.Derive(AlgoSecp256K1, point[:33])
+	require.Error(t, err)
+
+	_, err = KDF{Algorithm: KDFHKDFSHA256}.Derive(AlgoEd25519, point)
+	require.Error(t, err)
+}
+
+func TestKDFValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		kdf     KDF
+		wantErr bool
+	}{
+		{"none", KDF{}, false},
+		{"sha256", KDF{Algorithm: KDFSHA256}, false},
+		{"hkdf default length", KDF{Algorithm: KDFHKDFSHA256, Salt: []byte("salt"), Info: []byte("info")}, false},
+		{"hkdf max length", KDF{Algorithm: KDFHKDFSHA256, Length: MaxKDFLength}, false},
+		{"x963", KDF{Algorithm: KDFX963SHA256, Info: []byte("info"), Length: 48}, false},
+		{"none with length", KDF{Length: 32}, true},
+		{"sha256 with info", KDF{Algorithm: KDFSHA256, Info: []byte("info")}, true},
+		{"hkdf too long", KDF{Algorithm: KDFHKDFSHA256, Length: MaxKDFLength + 1}, true},
+		{"hkdf negative length", KDF{Algorithm: KDFHKDFSHA256, Length: -1}, true},
+		{"hkdf salt too long", KDF{Algorithm: KDFHKDFSHA256, Salt: make([]byte, MaxKDFParameterSize+1)}, true},
+		{"x963 with salt", KDF{Algorithm: KDFX963SHA256, Salt: []byte("salt")}, true},
+		{"unknown", KDF{Algorithm: KDFX963SHA256 + 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.kdf.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_dumbToken_ECDH(t *testing.T) {
+	dt := seededToken(t)
+
+	alice := BIP44Path(118, 0, 0, 0)
+	bob := BIP44Path(118, 1, 0, 0)
+
+	kdfs := []KDF{
+		{},
+		{Algorithm: KDFSHA256},
+		{Algorithm: KDFHKDFSHA256, Salt: []byte("salt"), Info: []byte("memo"), Length: 64},
+		{Algorithm: KDFX963SHA256, Info: []byte("ecies")},
+	}
+
+	for _, algorithm := range []Algorithm{AlgoSecp256K1, AlgoX25519} {
+		alicePub, err := dt.PublicKey(alice, algorithm)
+		require.NoError(t, err)
+
+		bobPub, err := dt.PublicKey(bob, algorithm)
+		require.NoError(t, err)
+
+		raw, err := dt.ECDH(alice, algorithm, KDF{}, bobPub)
+		require.NoError(t, err)
+
+		for _, kdf := range kdfs {
+			t.Run(algorithm.String()+"/"+kdf.Algorithm.String(), func(t *testing.T) {
+				aliceKey, err := dt.ECDH(alice, algorithm, kdf, bobPub)
+				require.NoError(t, err)
+
+				bobKey, err := dt.ECDH(bob, algorithm, kdf, alicePub)
+				require.NoError(t, err)
+
+				require.Equal(t, aliceKey, bobKey)
+
+				expected, err := kdf.Derive(algorithm, raw)
+				require.NoError(t, err)
+				require.Equal(t, expected, aliceKey)
+			})
+		}
+
+		digest := sha256.Sum256(raw)
+		sha, err := dt.ECDH(alice, algorithm, KDF{Algorithm: KDFSHA256}, bobPub)
+		require.NoError(t, err)
+		require.Equal(t, digest[:], sha)
+	}
+
+	_, err := dt.ECDH(alice, AlgoEd25519, KDF{}, pubKeyBytes(t))
+	require.Error(t, err)
+
+	_, err = dt.ECDH(alice, AlgoSecp256K1, KDF{Algorithm: KDFSHA256, Length: 16}, pubKeyBytes(t))
+	require.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/wallera-computer/wallera/entropy"
+)
+
+// DefaultKeyCacheSize is the amount of entries held by the KeyCache of the tokens.
+const DefaultKeyCacheSize = 16
+
+// Kinds of KeyCache entries, part of their identifiers.
+const (
+	cacheEntrySeed byte = 0x01
+	cacheEntryKey  byte = 0x02
+)
+
+type keyCacheEntry struct {
+	id [sha256.Size]byte
+
+	// only one of them is set
+	seed []byte
+	key  *hdkeychain.ExtendedKey
+}
+
+func (e keyCacheEntry) zero() {
+	if e.seed != nil {
+		Wipe(e.seed)
+	}
+
+	if e.key != nil {
+		e.key.Zero()
+	}
+}
+
+// KeyCache is a bounded cache of BIP-32 seeds and of the hardened nodes derived from them, like
+// account nodes, so that repeated operations on the same account skip the PBKDF2 stretching of
+// the mnemonic and the hardened derivation steps.
+//
+// Entries are identified by an HMAC of their inputs under a random key, so that identifiers don't
+// reveal the seeds they have been derived from.
+// When full, the least recently used entry is evicted, and like every entry removed by Purge,
+// its key material is zeroed.
+type KeyCache struct {
+	mu sync.Mutex
+
+	size  int
+	idKey []byte
+
+	// least recently used first
+	entries []keyCacheEntry
+}
+
+// NewKeyCache returns a KeyCache holding up to size entries.
+func NewKeyCache(size int) (*KeyCache, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("key cache size must be positive")
+	}
+
+	idKey := make([]byte, sha256.Size)
+	if _, err := entropy.Read(idKey); err != nil {
+		return nil, fmt.Errorf("cannot generate key cache identifier key, %w", err)
+	}
+
+	return &KeyCache{
+		size:  size,
+		idKey: idKey,
+	}, nil
+}
+
+func (c *KeyCache) entryID(kind byte, parts ...[]byte) [sha256.Size]byte {
+	h := hmac.New(sha256.New, c.idKey)
+	h.Write([]byte{kind})
+
+	for _, p := range parts {
+		l := make([]byte, 4)
+		binary.BigEndian.PutUint32(l, uint32(len(p)))
+		h.Write(l)
+		h.Write(p)
+	}
+
+	ret := [sha256.Size]byte{}
+	copy(ret[:], h.Sum(nil))
+
+	return ret
+}
+
+// lookup returns the entry identified by id, marking it as the most recently used.
+// c.mu must be held.
+func (c *KeyCache) lookup(id [sha256.Size]byte) (keyCacheEntry, bool) {
+	for i, e := range c.entries {
+		if !hmac.Equal(e.id[:], id[:]) {
+			continue
+		}
+
+		c.entries = append(append(c.entries[:i], c.entries[i+1:]...), e)
+		return e, true
+	}
+
+	return keyCacheEntry{}, false
+}
+
+// add inserts e as the most recently used entry, evicting the least recently used one if c is full.
+// c.mu must be held.
+func (c *KeyCache) add(e keyCacheEntry) {
+	if len(c.entries) == c.size {
+		c.entries[0].zero()
+		c.entries[0] = keyCacheEntry{}
+		c.entries = c.entries[1:]
+	}
+
+	c.entries = append(c.entries, e)
+}
+
+// Len returns the amount of entries held by c.
+func (c *KeyCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Purge zeroes and removes every entry of c, which must be done once the seeds they have been
+// derived from are replaced or wiped.
+func (c *KeyCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.entries {
+		c.entries[i].zero()
+		c.entries[i] = keyCacheEntry{}
+	}
+
+	c.entries = nil
+}
+
+// MasterSeed returns the BIP-32 seed of the wallet of seed for passphrase, like Seed.MasterSeed does.
+// The returned slice is a copy the caller can zero.
+func (c *KeyCache) MasterSeed(seed Seed, passphrase []byte) ([]byte, error) {
+	raw := seed.encode()
+	defer Wipe(raw)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.entryID(cacheEntrySeed, []byte{byte(seed.Type)}, raw, passphrase)
+	if e, found := c.lookup(id); found {
+		return append([]byte{}, e.seed...), nil
+	}
+
+	master, err := seed.MasterSeed(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(keyCacheEntry{
+		id:   id,
+		seed: append([]byte{}, master...),
+	})
+
+	return master, nil
+}
+
+// Derive returns the extended key at path under the master key of seed, like KeyFromPath does.
+// The deepest hardened node of path, or its parent if path is made of hardened components only,
+// is cached: keys returned by Derive are always derived from it, and are never shared with c.
+func (c *KeyCache) Derive(seed []byte, path DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(path) == 0 {
+		return hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	}
+
+	depth := 0
+	for depth < len(path) && path[depth] >= HardenedKeyStart {
+		depth++
+	}
+
+	if depth == len(path) {
+		depth--
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// node can't be evicted, and zeroed, until its children have been derived
+	node, err := c.node(seed, path[:depth])
+	if err != nil {
+		return nil, err
+	}
+
+	return KeyFromPath(node, path[depth:])
+}
+
+// node returns the cached node at path under the master key of seed, deriving it if needed.
+// c.mu must be held.
+func (c *KeyCache) node(seed []byte, path DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	rawPath, err := path.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.entryID(cacheEntryKey, seed, rawPath)
+	if e, found := c.lookup(id); found {
+		return e.key, nil
+	}
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := KeyFromPath(master, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(path) != 0 {
+		WipeExtendedKey(master)
+	}
+
+	c.add(keyCacheEntry{
+		id:  id,
+		key: node,
+	})
+
+	return node, nil
+}
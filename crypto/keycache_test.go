@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyCacheDerive(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	c, err := NewKeyCache(DefaultKeyCacheSize)
+	require.NoError(t, err)
+
+	for _, path := range []string{"m", "m/0", "m/0'", "m/0'/1/2'/2/1000000000", "m/44'/118'/0'/0/0", "m/44'/118'/0'/0/1", "m/44'/118'/0'"} {
+		t.Run(path, func(t *testing.T) {
+			dp, err := ParseDerivationPath(path)
+			require.NoError(t, err)
+
+			expected, err := KeyFromPath(master, dp)
+			require.NoError(t, err)
+
+			// twice, the second time from the cache
+			for i := 0; i < 2; i++ {
+				key, err := c.Derive(seed, dp)
+				require.NoError(t, err)
+				require.Equal(t, expected.String(), key.String())
+			}
+		})
+	}
+
+	// the master key, m/0', m/44'/118'/0' and m/44'/118' nodes
+	require.Equal(t, 4, c.Len())
+}
+
+func TestKeyCacheKeysAreNotShared(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	c, err := NewKeyCache(DefaultKeyCacheSize)
+	require.NoError(t, err)
+
+	key, err := c.Derive(seed, DerivationPath{Hardened(44), Hardened(118), Hardened(0)})
+	require.NoError(t, err)
+
+	expected := key.String()
+	c.Purge()
+	require.Zero(t, c.Len())
+	require.Equal(t, expected, key.String())
+}
+
+func TestKeyCacheEvictsAndZeroes(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	c, err := NewKeyCache(2)
+	require.NoError(t, err)
+
+	_, err = c.Derive(seed, BIP44Path(118, 0, 0, 0))
+	require.NoError(t, err)
+
+	evicted := c.entries[0].key
+	require.True(t, evicted.IsPrivate())
+
+	_, err = c.Derive(seed, BIP44Path(118, 1, 0, 0))
+	require.NoError(t, err)
+	_, err = c.Derive(seed, BIP44Path(118, 2, 0, 0))
+	require.NoError(t, err)
+
+	require.Equal(t, 2, c.Len())
+	require.False(t, evicted.IsPrivate())
+
+	_, err = evicted.ECPrivKey()
+	require.Error(t, err)
+
+	// the most recently used entry survives
+	c.lookup(c.entries[0].id)
+	kept := c.entries[1].key
+
+	_, err = c.Derive(seed, BIP44Path(118, 3, 0, 0))
+	require.NoError(t, err)
+	require.True(t, kept.IsPrivate())
+
+	c.Purge()
+	require.False(t, kept.IsPrivate())
+}
+
+func TestKeyCacheMasterSeed(t *testing.T) {
+	c, err := NewKeyCache(DefaultKeyCacheSize)
+	require.NoError(t, err)
+
+	expected, err := MasterSeed(standardEntropy, []byte("TREZOR"))
+	require.NoError(t, err)
+
+	seed, err := c.MasterSeed(Seed{Type: SeedBIP39, Entropy: standardEntropy}, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, expected, seed)
+
+	cached := c.entries[0].seed
+
+	// callers can zero their copy
+	Wipe(seed)
+
+	seed, err = c.MasterSeed(Seed{Type: SeedBIP39, Entropy: standardEntropy}, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, expected, seed)
+	require.Equal(t, 1, c.Len())
+
+	c.Purge()
+	require.Equal(t, make([]byte, len(expected)), cached)
+}
+
+func Test_dumbToken_PurgesKeyCache(t *testing.T) {
+	dt := seededToken(t)
+
+	_, err := dt.PublicKey(BIP44Path(118, 0, 0, 0), AlgoSecp256K1)
+	require.NoError(t, err)
+	require.NotZero(t, dt.cache.Len())
+
+	require.NoError(t, dt.Wipe())
+	require.Zero(t, dt.cache.Len())
+}
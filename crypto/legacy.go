@@ -0,0 +1,133 @@
+package crypto
+
+import "fmt"
+
+// LegacyToken is the interface Tokens implemented before their operations took a derivation path:
+// callers had to Clone them and Initialize the clone on a path before each operation.
+type LegacyToken interface {
+	RandomBytes(amount uint64) ([]byte, error)
+	Initialize(path DerivationPath) error
+	Sign(data []byte, algorithm Algorithm) ([]byte, error)
+	ECDH(peerPublicKey []byte, algorithm Algorithm) ([]byte, error)
+	PublicKey(algorithm Algorithm) ([]byte, error)
+	AccountKey() (AccountKey, error)
+	Clone() LegacyToken
+	SupportedSignAlgorithms() []Algorithm
+}
+
+// Compile-time check which fails if legacyToken doesn't comply with
+// crypto.Token interface.
+var _ Token = legacyToken{}
+
+// legacyToken adapts a LegacyToken to the Token interface.
+type legacyToken struct {
+	t LegacyToken
+}
+
+// FromLegacy returns a Token running each operation on a clone of t, initialized on the operation path.
+// Sign is given digests, except for ed25519 which is given messages: messages are digested by the adapter.
+func FromLegacy(t LegacyToken) Token {
+	return legacyToken{t: t}
+}
+
+func (lt legacyToken) on(path DerivationPath) (LegacyToken, error) {
+	t := lt.t.Clone()
+	if err := t.Initialize(path); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (lt legacyToken) RandomBytes(amount uint64) ([]byte, error) {
+	return lt.t.RandomBytes(amount)
+}
+
+func (lt legacyToken) PublicKey(path DerivationPath, algorithm Algorithm) ([]byte, error) {
+	t, err := lt.on(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.PublicKey(algorithm)
+}
+
+func (lt legacyToken) AccountKey(path DerivationPath) (AccountKey, error) {
+	t, err := lt.on(path)
+	if err != nil {
+		return AccountKey{}, err
+	}
+
+	return t.AccountKey()
+}
+
+func (lt legacyToken) SignDigest(path DerivationPath, algorithm Algorithm, digest []byte) ([]byte, error) {
+	if err := CheckDigest(algorithm, digest); err != nil {
+		return nil, err
+	}
+
+	t, err := lt.on(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Sign(digest, algorithm)
+}
+
+func (lt legacyToken) SignMessage(path DerivationPath, algorithm Algorithm, hash Hash, message []byte) ([]byte, error) {
+	digest, err := MessageDigest(algorithm, hash, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if digest != nil {
+		return lt.SignDigest(path, algorithm, digest)
+	}
+
+	if algorithm != AlgoEd25519 {
+		return nil, fmt.Errorf("unsupported signature algorithm %v", algorithm)
+	}
+
+	t, err := lt.on(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Sign(message, algorithm)
+}
+
+// ECDH applies kdf to the shared secret of the LegacyToken, which has no KDF of its own.
+func (lt legacyToken) ECDH(path DerivationPath, algorithm Algorithm, kdf KDF, peerPublicKey []byte) ([]byte, error) {
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+
+	t, err := lt.on(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := t.ECDH(peerPublicKey, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(secret)
+
+	return kdf.Derive(algorithm, secret)
+}
+
+// Fingerprint returns the master key fingerprint carried by the account key of m/44'/0'/0', since
+// LegacyTokens never expose their master key.
+func (lt legacyToken) Fingerprint() ([]byte, error) {
+	key, err := lt.AccountKey(DerivationPath{Hardened(44), Hardened(0), Hardened(0)})
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Fingerprint, nil
+}
+
+func (lt legacyToken) SupportedSignAlgorithms() []Algorithm {
+	return lt.t.SupportedSignAlgorithms()
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubLegacyToken implements LegacyToken on top of a Token.
+type stubLegacyToken struct {
+	t      Token
+	path   DerivationPath
+	clones *int
+}
+
+func (s *stubLegacyToken) RandomBytes(amount uint64) ([]byte, error) {
+	return s.t.RandomBytes(amount)
+}
+
+func (s *stubLegacyToken) Initialize(path DerivationPath) error {
+	s.path = path
+	return nil
+}
+
+func (s *stubLegacyToken) Sign(data []byte, algorithm Algorithm) ([]byte, error) {
+	if algorithm == AlgoEd25519 {
+		return s.t.SignMessage(s.path, algorithm, HashNone, data)
+	}
+
+	return s.t.SignDigest(s.path, algorithm, data)
+}
+
+func (s *stubLegacyToken) ECDH(peerPublicKey []byte, algorithm Algorithm) ([]byte, error) {
+	return s.t.ECDH(s.path, algorithm, KDF{}, peerPublicKey)
+}
+
+func (s *stubLegacyToken) PublicKey(algorithm Algorithm) ([]byte, error) {
+	return s.t.PublicKey(s.path, algorithm)
+}
+
+func (s *stubLegacyToken) AccountKey() (AccountKey, error) {
+	return s.t.AccountKey(s.path)
+}
+
+func (s *stubLegacyToken) Clone() LegacyToken {
+	*s.clones++
+	cl := *s
+	return &cl
+}
+
+func (s *stubLegacyToken) SupportedSignAlgorithms() []Algorithm {
+	return s.t.SupportedSignAlgorithms()
+}
+
+func TestFromLegacy(t *testing.T) {
+	dt := seededToken(t)
+	clones := 0
+	legacy := &stubLegacyToken{t: dt, clones: &clones}
+	lt := FromLegacy(legacy)
+
+	path := BIP44Path(118, 0, 0, 0)
+	other := BIP44Path(118, 1, 0, 0)
+
+	pk, err := lt.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.Equal(t, pubKeyBytes(t), pk)
+
+	pk, err = lt.PublicKey(other, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.NotEqual(t, pubKeyBytes(t), pk)
+
+	// every operation runs on its own clone, the adapted token is never initialized
+	require.Equal(t, 2, clones)
+	require.Nil(t, legacy.path)
+
+	digest, err := HashSHA256.Digest([]byte("message"))
+	require.NoError(t, err)
+
+	_, err = lt.SignMessage(path, AlgoSecp256K1Schnorr, HashSHA256, []byte("message"))
+	require.NoError(t, err)
+
+	_, err = lt.SignDigest(path, AlgoSecp256K1, digest[:20])
+	require.Error(t, err)
+
+	edPath := DerivationPath{Hardened(44), Hardened(501), Hardened(0)}
+	sig, err := lt.SignMessage(edPath, AlgoEd25519, HashNone, []byte("message"))
+	require.NoError(t, err)
+
+	expected, err := dt.SignMessage(edPath, AlgoEd25519, HashNone, []byte("message"))
+	require.NoError(t, err)
+	require.Equal(t, expected, sig)
+
+	key, err := lt.AccountKey(DerivationPath{Hardened(44), Hardened(118), Hardened(0)})
+	require.NoError(t, err)
+	require.NotEmpty(t, key.XPub)
+
+	fp, err := lt.Fingerprint()
+	require.NoError(t, err)
+
+	expectedFP, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expectedFP, fp)
+}
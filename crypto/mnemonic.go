@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/cosmos/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BIP-39 constants.
+const (
+	mnemonicWordBits     = 11
+	mnemonicWordlistSize = 1 << mnemonicWordBits
+	mnemonicMaxWordLen   = 8
+	mnemonicSaltPrefix   = "mnemonic"
+	mnemonicPBKDF2Rounds = 2048
+	mnemonicSeedSize     = 64
+)
+
+// MnemonicIndexes returns the wordlist indexes of the BIP-39 mnemonic encoding entropy, which must be
+// between 128 and 256 bits long, in multiples of 32 bits.
+// The caller owns the returned slice, and should wipe it once done.
+func MnemonicIndexes(entropy []byte) ([]uint16, error) {
+	entropyBits := len(entropy) * 8
+	if entropyBits < 128 || entropyBits > 256 || entropyBits%32 != 0 {
+		return nil, fmt.Errorf("unsupported entropy size %v bits", entropyBits)
+	}
+
+	checksum := sha256.Sum256(entropy)
+	defer Wipe(checksum[:])
+
+	// the entropy is followed by one checksum bit every 32 bits of entropy
+	bit := func(i int) uint16 {
+		if i < entropyBits {
+			return uint16(entropy[i/8]>>(7-i%8)) & 1
+		}
+
+		i -= entropyBits
+		return uint16(checksum[i/8]>>(7-i%8)) & 1
+	}
+
+	indexes := make([]uint16, (entropyBits+entropyBits/32)/mnemonicWordBits)
+	for w := range indexes {
+		for b := 0; b < mnemonicWordBits; b++ {
+			indexes[w] = indexes[w]<<1 | bit(w*mnemonicWordBits+b)
+		}
+	}
+
+	return indexes, nil
+}
+
+// MnemonicWords returns the BIP-39 english words at indexes.
+// Words are references to the wordlist: secrets are never copied to strings.
+func MnemonicWords(indexes []uint16) ([]string, error) {
+	words := make([]string, len(indexes))
+	for i, idx := range indexes {
+		if idx >= mnemonicWordlistSize {
+			WipeWords(words)
+			return nil, fmt.Errorf("invalid mnemonic word index %v", idx)
+		}
+
+		words[i] = bip39.WordList[idx]
+	}
+
+	return words, nil
+}
+
+// MnemonicWord returns the BIP-39 wordlist word matching raw, once trimmed and lowercased.
+// raw is normalized in a buffer which gets wiped, and the returned string is a reference to the wordlist.
+func MnemonicWord(raw []byte) (string, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > mnemonicMaxWordLen {
+		return "", false
+	}
+
+	buf := [mnemonicMaxWordLen]byte{}
+	defer Wipe(buf[:])
+
+	for i, c := range raw {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+
+		buf[i] = c
+	}
+
+	// map lookups indexed by a converted byte slice don't allocate a string
+	idx, found := bip39.ReverseWordMap[string(buf[:len(raw)])]
+	if !found {
+		return "", false
+	}
+
+	return bip39.WordList[idx], true
+}
+
+// MnemonicEntropy returns the entropy encoded by the BIP-39 mnemonic made of the wordlist indexes,
+// after verifying its checksum.
+// The caller owns the returned slice, and should wipe it once done.
+func MnemonicEntropy(indexes []uint16) ([]byte, error) {
+	if !ValidMnemonicLength(len(indexes)) {
+		return nil, fmt.Errorf("unsupported mnemonic length %v, must be either 12, 18 or 24 words", len(indexes))
+	}
+
+	checksumBits := len(indexes) / 3
+	entropy := make([]byte, checksumBits*4)
+
+	checksum := uint16(0)
+	for i := 0; i < len(indexes)*mnemonicWordBits; i++ {
+		idx := indexes[i/mnemonicWordBits]
+		if idx >= mnemonicWordlistSize {
+			Wipe(entropy)
+			return nil, fmt.Errorf("invalid mnemonic word index %v", idx)
+		}
+
+		b := byte(idx>>(mnemonicWordBits-1-i%mnemonicWordBits)) & 1
+		if i < len(entropy)*8 {
+			entropy[i/8] |= b << (7 - i%8)
+		} else {
+			checksum = checksum<<1 | uint16(b)
+		}
+	}
+
+	expected := sha256.Sum256(entropy)
+	defer Wipe(expected[:])
+
+	if checksum != uint16(expected[0]>>(8-checksumBits)) {
+		Wipe(entropy)
+		return nil, fmt.Errorf("invalid mnemonic, checksum mismatch")
+	}
+
+	return entropy, nil
+}
+
+// MnemonicIndexesOf returns the wordlist indexes of the BIP-39 mnemonic words.
+// The caller owns the returned slice, and should wipe it once done.
+func MnemonicIndexesOf(words []string) ([]uint16, error) {
+	indexes := make([]uint16, len(words))
+	for i, w := range words {
+		idx, found := bip39.ReverseWordMap[w]
+		if !found {
+			WipeIndexes(indexes)
+			return nil, fmt.Errorf("word %v is not in the BIP-39 wordlist", i+1)
+		}
+
+		indexes[i] = uint16(idx)
+	}
+
+	return indexes, nil
+}
+
+// mnemonicSentence returns the space-separated BIP-39 mnemonic of entropy, in a buffer the caller must wipe.
+func mnemonicSentence(entropy []byte) ([]byte, error) {
+	indexes, err := MnemonicIndexes(entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeIndexes(indexes)
+
+	words, err := MnemonicWords(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeWords(words)
+
+	return JoinWords(words), nil
+}
+
+// mnemonicSeed returns the BIP-39 seed of the mnemonic encoding entropy, stretched with passphrase
+// through PBKDF2-HMAC-SHA512.
+func mnemonicSeed(entropy []byte, passphrase []byte) ([]byte, error) {
+	sentence, err := mnemonicSentence(entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(sentence)
+
+	salt := make([]byte, 0, len(mnemonicSaltPrefix)+len(passphrase))
+	salt = append(salt, mnemonicSaltPrefix...)
+	salt = append(salt, passphrase...)
+	defer Wipe(salt)
+
+	return pbkdf2.Key(sentence, salt, mnemonicPBKDF2Rounds, mnemonicSeedSize, sha512.New), nil
+}
@@ -0,0 +1,188 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+const (
+	// MaxPathDepth is the maximum amount of components of a DerivationPath.
+	MaxPathDepth = 10
+
+	// HardenedKeyStart is added to path components to derive hardened children.
+	HardenedKeyStart = hdkeychain.HardenedKeyStart
+)
+
+// DerivationPath is a BIP-32 derivation path, made of up to MaxPathDepth components.
+// Hardened components include HardenedKeyStart, the empty path is the master key.
+//
+// Its wire encoding, shared by the APDU apps and the TEE token protocol, is one byte holding
+// the amount of components, followed by each component as a big-endian uint32.
+type DerivationPath []uint32
+
+// Hardened returns the hardened path component for index.
+func Hardened(index uint32) uint32 {
+	return index | HardenedKeyStart
+}
+
+// BIP44Path returns the m / 44' / coinType' / account' / change / addressIndex path.
+func BIP44Path(coinType, account, change, addressIndex uint32) DerivationPath {
+	return DerivationPath{
+		Hardened(44),
+		Hardened(coinType),
+		Hardened(account),
+		change,
+		addressIndex,
+	}
+}
+
+// ParseDerivationPath parses the string representation of a path, like "m/44'/118'/0'/0/0".
+// Hardened components are suffixed by either ' or h, indexes must be canonical decimal numbers
+// lower than 2^31.
+func ParseDerivationPath(s string) (DerivationPath, error) {
+	components := strings.Split(s, "/")
+	if components[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", s)
+	}
+
+	components = components[1:]
+	if len(components) > MaxPathDepth {
+		return nil, fmt.Errorf("derivation path %q is deeper than %v", s, MaxPathDepth)
+	}
+
+	ret := DerivationPath{}
+	for _, c := range components {
+		hardened := strings.HasSuffix(c, "'") || strings.HasSuffix(c, "h")
+		if hardened {
+			c = c[:len(c)-1]
+		}
+
+		if c == "" || (len(c) > 1 && c[0] == '0') || strings.IndexFunc(c, notDigit) != -1 {
+			return nil, fmt.Errorf("malformed component %q in derivation path %q", c, s)
+		}
+
+		index, err := strconv.ParseUint(c, 10, 32)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, fmt.Errorf("component %q out of range in derivation path %q", c, s)
+		}
+
+		if hardened {
+			index |= HardenedKeyStart
+		}
+
+		ret = append(ret, uint32(index))
+	}
+
+	return ret, nil
+}
+
+func notDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
+
+// String returns the string representation of d, like "m/44'/118'/0'/0/0".
+func (d DerivationPath) String() string {
+	b := &strings.Builder{}
+	b.WriteString("m")
+
+	for _, c := range d {
+		b.WriteString("/")
+		b.WriteString(strconv.FormatUint(uint64(c&^HardenedKeyStart), 10))
+
+		if c >= HardenedKeyStart {
+			b.WriteString("'")
+		}
+	}
+
+	return b.String()
+}
+
+// Validate returns an error if d is too deep.
+func (d DerivationPath) Validate() error {
+	if len(d) > MaxPathDepth {
+		return fmt.Errorf("derivation path is deeper than %v", MaxPathDepth)
+	}
+
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (d DerivationPath) MarshalBinary() ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	ret := make([]byte, 1+4*len(d))
+	ret[0] = byte(len(d))
+
+	for i, c := range d {
+		binary.BigEndian.PutUint32(ret[1+4*i:], c)
+	}
+
+	return ret, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (d *DerivationPath) UnmarshalBinary(data []byte) error {
+	path, rest, err := DecodeDerivationPath(data)
+	if err != nil {
+		return err
+	}
+
+	if len(rest) != 0 {
+		return fmt.Errorf("trailing data after derivation path")
+	}
+
+	*d = path
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface, encoding d in its wire encoding.
+func (d DerivationPath) MarshalJSON() ([]byte, error) {
+	raw, err := d.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *DerivationPath) UnmarshalJSON(data []byte) error {
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	return d.UnmarshalBinary(raw)
+}
+
+// DecodeDerivationPath decodes the wire encoded path found at the beginning of data,
+// and returns it along with the bytes following it.
+func DecodeDerivationPath(data []byte) (DerivationPath, []byte, error) {
+	if len(data) < 1 {
+		return nil, nil, fmt.Errorf("missing derivation path depth")
+	}
+
+	depth := int(data[0])
+	if depth > MaxPathDepth {
+		return nil, nil, fmt.Errorf("derivation path is deeper than %v", MaxPathDepth)
+	}
+
+	data = data[1:]
+	if len(data) < 4*depth {
+		return nil, nil, fmt.Errorf("derivation path is truncated")
+	}
+
+	ret := make(DerivationPath, depth)
+	for i := range ret {
+		ret[i] = binary.BigEndian.Uint32(data[4*i:])
+	}
+
+	return ret, data[4*depth:], nil
+}
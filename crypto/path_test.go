@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDerivationPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected DerivationPath
+	}{
+		{"m", DerivationPath{}},
+		{"m/44'/118'/0'/0/0", BIP44Path(118, 0, 0, 0)},
+		{"m/48h/0h/0h/2h", DerivationPath{Hardened(48), Hardened(0), Hardened(0), Hardened(2)}},
+		{"m/0/2147483647'", DerivationPath{0, 0xFFFFFFFF}},
+		{"m/1/2/3/4/5/6/7/8/9/10", DerivationPath{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			dp, err := ParseDerivationPath(tt.path)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, dp)
+		})
+	}
+}
+
+func TestParseDerivationPathIsStrict(t *testing.T) {
+	for _, path := range []string{
+		"",
+		"44'/0'",
+		"M/44'",
+		"m/",
+		"m//0",
+		"m/44''",
+		"m/'",
+		"m/01",
+		"m/+1",
+		"m/-1",
+		"m/0x10",
+		"m/2147483648",
+		"m/4294967295'",
+		"m/1/2/3/4/5/6/7/8/9/10/11",
+	} {
+		t.Run(path, func(t *testing.T) {
+			_, err := ParseDerivationPath(path)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDerivationPathString(t *testing.T) {
+	for _, path := range []string{"m", "m/44'/118'/0'/0/0", "m/0/2147483647'"} {
+		dp, err := ParseDerivationPath(path)
+		require.NoError(t, err)
+		require.Equal(t, path, dp.String())
+	}
+
+	dp, err := ParseDerivationPath("m/48h/0h")
+	require.NoError(t, err)
+	require.Equal(t, "m/48'/0'", dp.String())
+}
+
+func TestDerivationPathWireEncoding(t *testing.T) {
+	dp := BIP44Path(118, 0, 0, 1)
+
+	raw, err := dp.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, "058000002c80000076800000000000000000000001", hex.EncodeToString(raw))
+
+	decoded, rest, err := DecodeDerivationPath(append(raw, 0xAA))
+	require.NoError(t, err)
+	require.Equal(t, dp, decoded)
+	require.Equal(t, []byte{0xAA}, rest)
+
+	require.Error(t, new(DerivationPath).UnmarshalBinary(append(raw, 0xAA)))
+	require.Error(t, new(DerivationPath).UnmarshalBinary(raw[:len(raw)-1]))
+	require.Error(t, new(DerivationPath).UnmarshalBinary([]byte{MaxPathDepth + 1}))
+
+	_, err = make(DerivationPath, MaxPathDepth+1).MarshalBinary()
+	require.Error(t, err)
+
+	js, err := json.Marshal(struct{ Path DerivationPath }{dp})
+	require.NoError(t, err)
+
+	var back struct{ Path DerivationPath }
+	require.NoError(t, json.Unmarshal(js, &back))
+	require.Equal(t, dp, back.Path)
+}
+
+func TestKeyFromPath(t *testing.T) {
+	// BIP-32 test vector 1
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	dp, err := ParseDerivationPath("m/0'/1/2'/2/1000000000")
+	require.NoError(t, err)
+
+	key, err := KeyFromPath(master, dp)
+	require.NoError(t, err)
+
+	pub, err := key.Neuter()
+	require.NoError(t, err)
+	require.Equal(t,
+		"xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+		pub.String(),
+	)
+
+	root, err := KeyFromPath(master, DerivationPath{})
+	require.NoError(t, err)
+	require.Equal(t, master.String(), root.String())
+}
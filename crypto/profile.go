@@ -0,0 +1,209 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	// DefaultProfile is the profile every device has, whose seed is kept where devices without
+	// profiles kept theirs.
+	DefaultProfile = "default"
+
+	// MaxProfiles is the maximum amount of profiles, DefaultProfile included.
+	MaxProfiles = 8
+
+	maxProfileLabelLength = 16
+
+	profilesKey      = "crypto/profiles"
+	activeProfileKey = "crypto/active_profile"
+
+	// profilePrefix namespaces the seeds of the profiles other than DefaultProfile.
+	profilePrefix = "profile/"
+)
+
+// Profiler is implemented by Tokens which hold several independent seeds, one per profile.
+// Seed operations resolve against the seed of the active profile, which persists across restarts.
+type Profiler interface {
+	// Profiles returns the labels of the profiles, DefaultProfile first, along with the active one.
+	Profiles() (labels []string, active string, err error)
+
+	// SelectProfile makes label the active profile, creating it without a seed if it doesn't exist,
+	// and resets the passphrase to the empty one.
+	SelectProfile(label string) error
+
+	// DeleteProfile wipes the seed of the profile label, and deletes it.
+	// Neither DefaultProfile nor the active profile can be deleted.
+	DeleteProfile(label string) error
+}
+
+// ValidProfileLabel returns an error if label can't name a profile: labels are made of up to 16
+// lowercase letters, digits and dashes, like "team-ops".
+func ValidProfileLabel(label string) error {
+	if label == "" || len(label) > maxProfileLabelLength {
+		return fmt.Errorf("profile label must be between 1 and %v characters long", maxProfileLabelLength)
+	}
+
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return fmt.Errorf("profile label must only contain lowercase letters, digits and dashes")
+		}
+	}
+
+	return nil
+}
+
+// ProfileStorage returns the Storage holding the seed of the profile label, out of s.
+func ProfileStorage(s storage.Storage, label string) storage.Storage {
+	if label == DefaultProfile {
+		return s
+	}
+
+	return storage.NewPrefixed(s, profilePrefix+label+"/")
+}
+
+// Profiles returns the labels of the profiles held in s, DefaultProfile first.
+func Profiles(s storage.Storage) ([]string, error) {
+	raw, err := s.Get(profilesKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []string{DefaultProfile}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot read profiles, %w", err)
+	}
+
+	return append([]string{DefaultProfile}, strings.Split(string(raw), "\n")...), nil
+}
+
+// storeProfiles replaces the labels of the profiles held in s with labels, DefaultProfile first.
+func storeProfiles(s storage.Storage, labels []string) error {
+	if len(labels) == 1 {
+		return s.Delete(profilesKey)
+	}
+
+	return s.Set(profilesKey, []byte(strings.Join(labels[1:], "\n")))
+}
+
+// ActiveProfile returns the label of the active profile held in s.
+func ActiveProfile(s storage.Storage) (string, error) {
+	raw, err := s.Get(activeProfileKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return DefaultProfile, nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("cannot read active profile, %w", err)
+	}
+
+	return string(raw), nil
+}
+
+// ActiveProfileStorage returns the Storage holding the seed of the active profile, out of s.
+func ActiveProfileStorage(s storage.Storage) (storage.Storage, error) {
+	active, err := ActiveProfile(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return ProfileStorage(s, active), nil
+}
+
+// SelectProfile makes label the active profile held in s, creating it if it doesn't exist.
+func SelectProfile(s storage.Storage, label string) error {
+	if err := ValidProfileLabel(label); err != nil {
+		return err
+	}
+
+	labels, err := Profiles(s)
+	if err != nil {
+		return err
+	}
+
+	if !containsProfile(labels, label) {
+		if len(labels) == MaxProfiles {
+			return fmt.Errorf("device already has %v profiles", MaxProfiles)
+		}
+
+		if err := storeProfiles(s, append(labels, label)); err != nil {
+			return fmt.Errorf("cannot create profile, %w", err)
+		}
+	}
+
+	if label == DefaultProfile {
+		return s.Delete(activeProfileKey)
+	}
+
+	return s.Set(activeProfileKey, []byte(label))
+}
+
+// DeleteProfile deletes the seed of the profile label held in s, and the profile.
+func DeleteProfile(s storage.Storage, label string) error {
+	if label == DefaultProfile {
+		return fmt.Errorf("the default profile can't be deleted")
+	}
+
+	active, err := ActiveProfile(s)
+	if err != nil {
+		return err
+	}
+
+	if label == active {
+		return fmt.Errorf("the active profile can't be deleted")
+	}
+
+	labels, err := Profiles(s)
+	if err != nil {
+		return err
+	}
+
+	if !containsProfile(labels, label) {
+		return fmt.Errorf("profile %q not found", label)
+	}
+
+	if err := deleteSeed(ProfileStorage(s, label)); err != nil {
+		return err
+	}
+
+	remaining := []string{}
+	for _, l := range labels {
+		if l != label {
+			remaining = append(remaining, l)
+		}
+	}
+
+	return storeProfiles(s, remaining)
+}
+
+// wipeProfiles deletes the seeds of every profile held in s, and the profiles.
+func wipeProfiles(s storage.Storage) error {
+	labels, err := Profiles(s)
+	if err != nil {
+		return err
+	}
+
+	for _, label := range labels {
+		if err := deleteSeed(ProfileStorage(s, label)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Delete(activeProfileKey); err != nil {
+		return err
+	}
+
+	return s.Delete(profilesKey)
+}
+
+func containsProfile(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func TestValidProfileLabel(t *testing.T) {
+	for _, label := range []string{"personal", "team-ops", "testnet", DefaultProfile, "0123456789abcdef"} {
+		require.NoError(t, ValidProfileLabel(label), label)
+	}
+
+	for _, label := range []string{"", "Personal", "team ops", "team/ops", "0123456789abcdefg", "équipe"} {
+		require.Error(t, ValidProfileLabel(label), label)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	s := storage.NewMemory()
+
+	labels, err := Profiles(s)
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile}, labels)
+
+	active, err := ActiveProfile(s)
+	require.NoError(t, err)
+	require.Equal(t, DefaultProfile, active)
+
+	require.NoError(t, SelectProfile(s, "personal"))
+	require.NoError(t, SelectProfile(s, "team-ops"))
+	require.NoError(t, SelectProfile(s, "personal"))
+
+	labels, err = Profiles(s)
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile, "personal", "team-ops"}, labels)
+
+	active, err = ActiveProfile(s)
+	require.NoError(t, err)
+	require.Equal(t, "personal", active)
+
+	require.Error(t, DeleteProfile(s, DefaultProfile))
+	require.Error(t, DeleteProfile(s, "personal"))
+	require.Error(t, DeleteProfile(s, "testnet"))
+	require.NoError(t, DeleteProfile(s, "team-ops"))
+
+	labels, err = Profiles(s)
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile, "personal"}, labels)
+
+	require.NoError(t, SelectProfile(s, DefaultProfile))
+	require.NoError(t, DeleteProfile(s, "personal"))
+
+	labels, err = Profiles(s)
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile}, labels)
+}
+
+func TestProfilesLimit(t *testing.T) {
+	s := storage.NewMemory()
+
+	for _, label := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		require.NoError(t, SelectProfile(s, label))
+	}
+
+	require.Error(t, SelectProfile(s, "h"))
+
+	// existing profiles can still be selected
+	require.NoError(t, SelectProfile(s, "a"))
+}
+
+func Test_dumbToken_Profiles(t *testing.T) {
+	dt := seededToken(t)
+	standardFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+
+	require.NoError(t, dt.SetPassphrase([]byte("hidden wallet")))
+	require.NoError(t, dt.SelectProfile("team-ops"))
+
+	// profiles are created without a seed, and the passphrase is reset
+	require.Empty(t, dt.session.Passphrase)
+
+	found, err := dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, err = dt.Fingerprint()
+	require.ErrorIs(t, err, ErrNoSeed)
+
+	require.NoError(t, dt.GenerateSeed(256))
+
+	teamFp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.NotEqual(t, standardFp, teamFp)
+
+	labels, active, err := dt.Profiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile, "team-ops"}, labels)
+	require.Equal(t, "team-ops", active)
+
+	// the active profile persists across restarts
+	restarted := NewDumbToken(dt.storage)
+	fp, err := restarted.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, teamFp, fp)
+
+	require.NoError(t, dt.SelectProfile(DefaultProfile))
+	fp, err = dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, standardFp, fp)
+
+	m, err := dt.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, standardMnemonic, m)
+
+	// the decoy seed has profiles of its own
+	dt.UseDecoy(true)
+	labels, active, err = dt.Profiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile}, labels)
+	require.Equal(t, DefaultProfile, active)
+	dt.UseDecoy(false)
+
+	require.NoError(t, dt.DeleteProfile("team-ops"))
+	require.NoError(t, dt.SelectProfile("team-ops"))
+
+	found, err = dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func Test_dumbToken_WipeProfiles(t *testing.T) {
+	dt := seededToken(t)
+
+	require.NoError(t, dt.SelectProfile("testnet"))
+	require.NoError(t, dt.GenerateSeed(128))
+
+	require.NoError(t, dt.Wipe())
+
+	labels, active, err := dt.Profiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultProfile}, labels)
+	require.Equal(t, DefaultProfile, active)
+
+	require.NoError(t, dt.SelectProfile("testnet"))
+	found, err := dt.HasSeed()
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func Test_dumbToken_HasAnySeed(t *testing.T) {
+	dt := NewDumbToken(storage.NewMemory())
+
+	found, err := dt.HasAnySeed()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// seeds of profiles other than the active one count
+	require.NoError(t, dt.SelectProfile("testnet"))
+	require.NoError(t, dt.GenerateSeed(128))
+	require.NoError(t, dt.SelectProfile(DefaultProfile))
+
+	found, err = dt.HasAnySeed()
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// and so do decoy seeds
+	require.NoError(t, dt.Wipe())
+
+	dt.UseDecoy(true)
+	require.NoError(t, dt.GenerateSeed(128))
+	dt.UseDecoy(false)
+
+	found, err = dt.HasAnySeed()
+	require.NoError(t, err)
+	require.True(t, found)
+}
@@ -0,0 +1,185 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// BIP-340 tagged hashes tags.
+const (
+	tagAux       = "BIP0340/aux"
+	tagNonce     = "BIP0340/nonce"
+	tagChallenge = "BIP0340/challenge"
+)
+
+// taggedHash implements the BIP-340 tagged hash function:
+// SHA256(SHA256(tag) || SHA256(tag) || msg).
+func taggedHash(tag string, msg ...[]byte) []byte {
+	th := sha256.Sum256([]byte(tag))
+
+	h := sha256.New()
+	h.Write(th[:])
+	h.Write(th[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+
+	return h.Sum(nil)
+}
+
+// bytes32 returns the 32 bytes big-endian representation of i.
+func bytes32(i *big.Int) []byte {
+	return i.FillBytes(make([]byte, 32))
+}
+
+// SignSchnorr returns a 64 bytes BIP-340 Schnorr signature of the 32 bytes message msg, using
+// 32 bytes of auxiliary randomness aux.
+func SignSchnorr(key *btcec.PrivateKey, msg []byte, aux []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, fmt.Errorf("schnorr message must be 32 bytes long, found %v", len(msg))
+	}
+
+	if len(aux) != 32 {
+		return nil, fmt.Errorf("schnorr auxiliary randomness must be 32 bytes long, found %v", len(aux))
+	}
+
+	curve := btcec.S256()
+	n := curve.N
+
+	d := new(big.Int).Set(key.D)
+	defer wipeBigInt(d)
+
+	if d.Sign() == 0 || d.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+
+	db := bytes32(d)
+	px, py := curve.ScalarBaseMult(db)
+	Wipe(db)
+
+	if py.Bit(0) == 1 {
+		d.Sub(n, d)
+	}
+
+	t := bytes32(d)
+	defer Wipe(t)
+
+	for i, b := range taggedHash(tagAux, aux) {
+		t[i] ^= b
+	}
+
+	nonce := taggedHash(tagNonce, t, bytes32(px), msg)
+	k := new(big.Int).SetBytes(nonce)
+	Wipe(nonce)
+	defer wipeBigInt(k)
+
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil, fmt.Errorf("schnorr nonce is zero")
+	}
+
+	kb := bytes32(k)
+	rx, ry := curve.ScalarBaseMult(kb)
+	Wipe(kb)
+
+	if ry.Bit(0) == 1 {
+		k.Sub(n, k)
+	}
+
+	e := new(big.Int).SetBytes(taggedHash(tagChallenge, bytes32(rx), bytes32(px), msg))
+	e.Mod(e, n)
+
+	// s holds e*d until k is added
+	s := new(big.Int).Mul(e, d)
+	defer wipeBigInt(s)
+
+	s.Add(s, k)
+	s.Mod(s, n)
+
+	sig := append(bytes32(rx), bytes32(s)...)
+
+	if !VerifySchnorr(bytes32(px), msg, sig) {
+		return nil, fmt.Errorf("produced schnorr signature doesn't verify")
+	}
+
+	return sig, nil
+}
+
+// VerifySchnorr verifies a BIP-340 Schnorr signature of msg against the x-only public key pubkey.
+func VerifySchnorr(pubkey []byte, msg []byte, sig []byte) bool {
+	if len(pubkey) != 32 || len(msg) != 32 || len(sig) != 64 {
+		return false
+	}
+
+	curve := btcec.S256()
+
+	px, py, err := liftX(pubkey)
+	if err != nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(curve.P) >= 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	e := new(big.Int).SetBytes(taggedHash(tagChallenge, sig[:32], pubkey, msg))
+	e.Mod(e, curve.N)
+
+	// R = s*G - e*P
+	sx, sy := curve.ScalarBaseMult(bytes32(s))
+	ex, ey := curve.ScalarMult(px, py, bytes32(e))
+	ey.Sub(curve.P, ey)
+	rx, ry := curve.Add(sx, sy, ex, ey)
+
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+
+	return ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+// liftX returns the point with even y coordinate whose x coordinate is x.
+func liftX(x []byte) (*big.Int, *big.Int, error) {
+	curve := btcec.S256()
+	p := curve.P
+
+	px := new(big.Int).SetBytes(x)
+	if px.Cmp(p) >= 0 {
+		return nil, nil, fmt.Errorf("x coordinate out of range")
+	}
+
+	// y^2 = x^3 + 7
+	c := new(big.Int).Exp(px, big.NewInt(3), p)
+	c.Add(c, curve.B)
+	c.Mod(c, p)
+
+	// p = 3 mod 4, so y = c^((p+1)/4)
+	e := new(big.Int).Add(p, big.NewInt(1))
+	e.Rsh(e, 2)
+	py := new(big.Int).Exp(c, e, p)
+
+	if new(big.Int).Exp(py, big.NewInt(2), p).Cmp(c) != 0 {
+		return nil, nil, fmt.Errorf("x coordinate not on curve")
+	}
+
+	if py.Bit(0) == 1 {
+		py.Sub(p, py)
+	}
+
+	return px, py, nil
+}
+
+// XOnlyPublicKey returns the BIP-340 x-only representation of a compressed secp256k1 public key.
+func XOnlyPublicKey(compressed []byte) ([]byte, error) {
+	pk, err := btcec.ParsePubKey(compressed, btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes32(pk.X), nil
+}
@@ -0,0 +1,98 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// Test vectors from https://github.com/bitcoin/bips/blob/master/bip-0340/test-vectors.csv
+func TestSignSchnorr(t *testing.T) {
+	tests := []struct {
+		name      string
+		secretKey string
+		publicKey string
+		aux       string
+		message   string
+		signature string
+	}{
+		{
+			"vector 0",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		},
+		{
+			"vector 1",
+			"B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		},
+		{
+			"vector 2",
+			"C90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B14E5C9",
+			"DD308AFEC5777E13121FA72B9CC1B7CC0139715309B086C960E18FD969774EB8",
+			"C87AA53824B4D7AE2EB035A2B5BBBCCC080E76CDC6D1692C4B0B62D798E6D906",
+			"7E2D58D8B3BCDF1ABADEC7829054F90DDA9805AAB56C77333024B9D0A508B75C",
+			"5831AAEED7B44BB74E5EAB94BA9D4294C49BCF2A60728D8B4C200F50DD313C1BAB745879A5AD954A72C45A91C3A51D3C7ADEA98D82F8481E0E1E03674A6F3FB7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, pub := btcec.PrivKeyFromBytes(btcec.S256(), mustHex(t, tt.secretKey))
+
+			xonly, err := XOnlyPublicKey(pub.SerializeCompressed())
+			require.NoError(t, err)
+			require.Equal(t, mustHex(t, tt.publicKey), xonly)
+
+			sig, err := SignSchnorr(key, mustHex(t, tt.message), mustHex(t, tt.aux))
+			require.NoError(t, err)
+			require.Equal(t, mustHex(t, tt.signature), sig)
+
+			require.True(t, VerifySchnorr(xonly, mustHex(t, tt.message), sig))
+		})
+	}
+}
+
+func TestVerifySchnorrRejectsTamperedSignature(t *testing.T) {
+	pub := mustHex(t, "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659")
+	msg := mustHex(t, "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89")
+	sig := mustHex(t, "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A")
+
+	require.True(t, VerifySchnorr(pub, msg, sig))
+
+	sig[63] ^= 0x01
+	require.False(t, VerifySchnorr(pub, msg, sig))
+}
+
+func Test_dumbToken_SignSchnorr(t *testing.T) {
+	dt := seededToken(t)
+	path := BIP44Path(1237, 0, 0, 0)
+
+	pk, err := dt.PublicKey(path, AlgoSecp256K1Schnorr)
+	require.NoError(t, err)
+
+	xonly, err := XOnlyPublicKey(pk)
+	require.NoError(t, err)
+
+	msg := make([]byte, 32)
+	sig, err := dt.SignDigest(path, AlgoSecp256K1Schnorr, msg)
+	require.NoError(t, err)
+	require.True(t, VerifySchnorr(xonly, msg, sig))
+
+	_, err = dt.SignDigest(path, AlgoSecp256K1Schnorr, msg[:31])
+	require.Error(t, err)
+}
@@ -0,0 +1,238 @@
+package crypto
+
+import (
+	"fmt"
+)
+
+// Scope is a key domain: the keys of Algorithms, at paths whose first component is one of Purposes
+// and second component one of CoinTypes.
+// Purposes and coin types are hardened path components, like Hardened(44) and Hardened(118).
+type Scope struct {
+	Purposes   []uint32
+	CoinTypes  []uint32
+	Algorithms []Algorithm
+}
+
+//go:generate stringer -type=ScopeID
+
+// ScopeID identifies one of the fixed Scopes of the device.
+// Key operations name their Scope by ScopeID, so that the key domains they're restricted to are
+// chosen by the Token, rather than described by its callers.
+type ScopeID byte
+
+const (
+	// ScopeNone is the zero ScopeID, which identifies no Scope: key operations can't run with it.
+	ScopeNone ScopeID = iota
+
+	// ScopeCosmos holds the keys of the Cosmos app.
+	ScopeCosmos
+
+	// ScopeNostr holds the keys of the Nostr app.
+	ScopeNostr
+
+	// ScopeAge holds the keys of the age app.
+	ScopeAge
+
+	// ScopeOpenPGP holds the keys of the OpenPGP card app.
+	ScopeOpenPGP
+
+	// ScopeOATH holds the keys of the OATH app.
+	ScopeOATH
+
+	// ScopeAccountExport holds the Bitcoin and Cosmos accounts the DEVICE app exports to watch-only wallets.
+	ScopeAccountExport
+)
+
+// scopes holds the Scope of every ScopeID but ScopeNone.
+var scopes = map[ScopeID]Scope{
+	ScopeCosmos: {
+		Purposes:   []uint32{Hardened(44)},
+		CoinTypes:  []uint32{Hardened(118)},
+		Algorithms: []Algorithm{AlgoSecp256K1},
+	},
+	ScopeNostr: {
+		Purposes:   []uint32{Hardened(44)},
+		CoinTypes:  []uint32{Hardened(1237)},
+		Algorithms: []Algorithm{AlgoSecp256K1Schnorr},
+	},
+	ScopeAge: {
+		Purposes:   []uint32{Hardened(44)},
+		CoinTypes:  []uint32{Hardened(6383461)},
+		Algorithms: []Algorithm{AlgoX25519},
+	},
+	ScopeOpenPGP: {
+		Purposes:   []uint32{Hardened(44)},
+		CoinTypes:  []uint32{Hardened(5261136)},
+		Algorithms: []Algorithm{AlgoSecp256K1},
+	},
+	ScopeOATH: {
+		Purposes:   []uint32{Hardened(44)},
+		CoinTypes:  []uint32{Hardened(1329681480)},
+		Algorithms: []Algorithm{AlgoSecp256K1},
+	},
+	ScopeAccountExport: {
+		Purposes:   []uint32{Hardened(44), Hardened(48), Hardened(49), Hardened(84), Hardened(86)},
+		CoinTypes:  []uint32{Hardened(0), Hardened(1), Hardened(118)},
+		Algorithms: []Algorithm{AlgoSecp256K1},
+	},
+}
+
+// Scope returns the Scope identified by id, or an error if id identifies none.
+func (id ScopeID) Scope() (Scope, error) {
+	scope, found := scopes[id]
+	if !found {
+		return Scope{}, fmt.Errorf("unknown key scope %v", id)
+	}
+
+	return scope, nil
+}
+
+// Check returns an error if the key of algorithm at path is out of s.
+func (s Scope) Check(path DerivationPath, algorithm Algorithm) error {
+	if len(path) < 2 {
+		return fmt.Errorf("derivation path %v is out of scope, it has no coin type", path)
+	}
+
+	if !containsComponent(s.Purposes, path[0]) {
+		return fmt.Errorf("purpose of derivation path %v is out of scope", path)
+	}
+
+	if !containsComponent(s.CoinTypes, path[1]) {
+		return fmt.Errorf("coin type of derivation path %v is out of scope", path)
+	}
+
+	if !s.allows(algorithm) {
+		return fmt.Errorf("algorithm %v is out of scope", algorithm)
+	}
+
+	return nil
+}
+
+func (s Scope) allows(algorithm Algorithm) bool {
+	for _, a := range s.Algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsComponent(components []uint32, c uint32) bool {
+	for _, cc := range components {
+		if cc == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopedTokenProvider is implemented by Tokens which enforce scopes on their own, like Tokens
+// running their operations in another execution environment.
+type ScopedTokenProvider interface {
+	// WithScope returns a handle of the Token, sharing its state, whose operations are refused
+	// outside of the Scope identified by id.
+	WithScope(id ScopeID) Token
+}
+
+// Compile-time check which fails if scopedToken doesn't comply with
+// crypto.Token interface.
+var _ Token = scopedToken{}
+
+// scopedToken refuses the operations of t outside of scope.
+type scopedToken struct {
+	t     Token
+	scope Scope
+
+	// err is set when the handle has been created with an unknown ScopeID, and refuses every key operation
+	err error
+}
+
+// NewScopedToken returns a handle of t which refuses every operation on keys out of the Scope
+// identified by id, so that it can be handed to components which must only use the keys of their
+// own domain.
+// If t is a ScopedTokenProvider, operations are refused by both the handle and t.
+func NewScopedToken(t Token, id ScopeID) Token {
+	if sp, ok := t.(ScopedTokenProvider); ok {
+		t = sp.WithScope(id)
+	}
+
+	scope, err := id.Scope()
+
+	return scopedToken{
+		t:     t,
+		scope: scope,
+		err:   err,
+	}
+}
+
+// check returns an error if the key of algorithm at path is out of the scope of st.
+func (st scopedToken) check(path DerivationPath, algorithm Algorithm) error {
+	if st.err != nil {
+		return st.err
+	}
+
+	return st.scope.Check(path, algorithm)
+}
+
+func (st scopedToken) RandomBytes(amount uint64) ([]byte, error) {
+	return st.t.RandomBytes(amount)
+}
+
+func (st scopedToken) PublicKey(path DerivationPath, algorithm Algorithm) ([]byte, error) {
+	if err := st.check(path, algorithm); err != nil {
+		return nil, err
+	}
+
+	return st.t.PublicKey(path, algorithm)
+}
+
+// AccountKey requires AlgoSecp256K1 to be in scope, since account keys are BIP-32 extended keys.
+func (st scopedToken) AccountKey(path DerivationPath) (AccountKey, error) {
+	if err := st.check(path, AlgoSecp256K1); err != nil {
+		return AccountKey{}, err
+	}
+
+	return st.t.AccountKey(path)
+}
+
+func (st scopedToken) SignDigest(path DerivationPath, algorithm Algorithm, digest []byte) ([]byte, error) {
+	if err := st.check(path, algorithm); err != nil {
+		return nil, err
+	}
+
+	return st.t.SignDigest(path, algorithm, digest)
+}
+
+func (st scopedToken) SignMessage(path DerivationPath, algorithm Algorithm, hash Hash, message []byte) ([]byte, error) {
+	if err := st.check(path, algorithm); err != nil {
+		return nil, err
+	}
+
+	return st.t.SignMessage(path, algorithm, hash, message)
+}
+
+func (st scopedToken) Fingerprint() ([]byte, error) {
+	return st.t.Fingerprint()
+}
+
+func (st scopedToken) ECDH(path DerivationPath, algorithm Algorithm, kdf KDF, peerPublicKey []byte) ([]byte, error) {
+	if err := st.check(path, algorithm); err != nil {
+		return nil, err
+	}
+
+	return st.t.ECDH(path, algorithm, kdf, peerPublicKey)
+}
+
+// SupportedSignAlgorithms only returns the algorithms of t in scope.
+func (st scopedToken) SupportedSignAlgorithms() []Algorithm {
+	ret := []Algorithm{}
+	for _, a := range st.t.SupportedSignAlgorithms() {
+		if st.scope.allows(a) {
+			ret = append(ret, a)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeCheck(t *testing.T) {
+	cosmosScope, err := ScopeCosmos.Scope()
+	require.NoError(t, err)
+
+	require.NoError(t, cosmosScope.Check(BIP44Path(118, 0, 0, 0), AlgoSecp256K1))
+	require.NoError(t, cosmosScope.Check(DerivationPath{Hardened(44), Hardened(118)}, AlgoSecp256K1))
+
+	tests := []struct {
+		name      string
+		path      DerivationPath
+		algorithm Algorithm
+	}{
+		{"master key", DerivationPath{}, AlgoSecp256K1},
+		{"purpose key", DerivationPath{Hardened(44)}, AlgoSecp256K1},
+		{"other purpose", DerivationPath{Hardened(84), Hardened(118), Hardened(0)}, AlgoSecp256K1},
+		{"other coin type", BIP44Path(0, 0, 0, 0), AlgoSecp256K1},
+		{"non-hardened coin type", DerivationPath{Hardened(44), 118, Hardened(0)}, AlgoSecp256K1},
+		{"other algorithm", BIP44Path(118, 0, 0, 0), AlgoSecp256K1Schnorr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, cosmosScope.Check(tt.path, tt.algorithm))
+		})
+	}
+}
+
+func TestNewScopedToken(t *testing.T) {
+	dt := seededToken(t)
+	st := NewScopedToken(dt, ScopeCosmos)
+
+	path := BIP44Path(118, 0, 0, 0)
+	other := BIP44Path(60, 0, 0, 0)
+
+	pk, err := st.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.Equal(t, pubKeyBytes(t), pk)
+
+	_, err = st.PublicKey(other, AlgoSecp256K1)
+	require.Error(t, err)
+
+	_, err = st.SignMessage(path, AlgoSecp256K1, HashSHA256, []byte("message"))
+	require.NoError(t, err)
+
+	_, err = st.SignMessage(other, AlgoSecp256K1, HashSHA256, []byte("message"))
+	require.Error(t, err)
+
+	_, err = st.SignDigest(path, AlgoSecp256K1Schnorr, make([]byte, 32))
+	require.Error(t, err)
+
+	_, err = st.ECDH(other, AlgoSecp256K1, KDF{}, pubKeyBytes(t))
+	require.Error(t, err)
+
+	_, err = st.AccountKey(DerivationPath{Hardened(44), Hardened(118), Hardened(0)})
+	require.NoError(t, err)
+
+	_, err = st.AccountKey(DerivationPath{Hardened(44), Hardened(0), Hardened(0)})
+	require.Error(t, err)
+
+	require.Equal(t, []Algorithm{AlgoSecp256K1}, st.SupportedSignAlgorithms())
+
+	fp, err := st.Fingerprint()
+	require.NoError(t, err)
+
+	expected, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+
+	// scoped handles can't be turned back into the Token they restrict
+	_, ok := st.(DeviceToken)
+	require.False(t, ok)
+}
+
+func TestNewScopedTokenUnknownScope(t *testing.T) {
+	dt := seededToken(t)
+
+	for _, id := range []ScopeID{ScopeNone, ScopeAccountExport + 1} {
+		st := NewScopedToken(dt, id)
+
+		_, err := st.PublicKey(BIP44Path(118, 0, 0, 0), AlgoSecp256K1)
+		require.Error(t, err, id)
+
+		_, err = st.AccountKey(DerivationPath{Hardened(44), Hardened(118), Hardened(0)})
+		require.Error(t, err, id)
+	}
+}
+
+func TestScopeAccountExport(t *testing.T) {
+	st := NewScopedToken(seededToken(t), ScopeAccountExport)
+
+	for _, path := range []DerivationPath{
+		{Hardened(84), Hardened(0), Hardened(0)},
+		{Hardened(48), Hardened(1), Hardened(0), Hardened(2)},
+		{Hardened(44), Hardened(118), Hardened(0)},
+	} {
+		_, err := st.AccountKey(path)
+		require.NoError(t, err, path)
+	}
+
+	// the keys of the apps which don't hand out public keys stay out of exports
+	_, err := st.AccountKey(DerivationPath{Hardened(44), Hardened(1329681480), Hardened(0)})
+	require.Error(t, err)
+}
+
+type scopingToken struct {
+	Token
+	scopes []ScopeID
+}
+
+func (s *scopingToken) WithScope(id ScopeID) Token {
+	s.scopes = append(s.scopes, id)
+	return s
+}
+
+func TestNewScopedTokenProvider(t *testing.T) {
+	provider := &scopingToken{Token: seededToken(t)}
+
+	st := NewScopedToken(provider, ScopeCosmos)
+	require.Equal(t, []ScopeID{ScopeCosmos}, provider.scopes)
+
+	_, err := st.PublicKey(BIP44Path(60, 0, 0, 0), AlgoSecp256K1)
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// Go gives no way to lock memory or to prevent the garbage collector from moving it, but key material
+// can at least be kept in buffers owned by a single operation, which wipe them before returning.
+// The functions below wipe the types secrets are held in: byte slices, big integers and the key types
+// of the libraries Tokens are built upon.
+// Secrets are never converted to strings, since strings are immutable and can't be wiped.
+
+// Wipe sets every byte of buffers to zero.
+func Wipe(buffers ...[]byte) {
+	for _, b := range buffers {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+}
+
+// WipeWords replaces every word of a mnemonic with the empty string.
+// Mnemonic words are references to the wordlist, so this only wipes which words the mnemonic is made of.
+func WipeWords(words []string) {
+	for i := range words {
+		words[i] = ""
+	}
+}
+
+// JoinWords returns words separated by spaces, in a buffer sized upfront so that appending never leaves
+// partial copies behind.
+// The caller owns the returned slice, and should wipe it once done.
+func JoinWords(words []string) []byte {
+	size := len(words) - 1
+	for _, w := range words {
+		size += len(w)
+	}
+
+	if size < 0 {
+		return nil
+	}
+
+	ret := make([]byte, 0, size)
+	for i, w := range words {
+		if i > 0 {
+			ret = append(ret, ' ')
+		}
+
+		ret = append(ret, w...)
+	}
+
+	return ret
+}
+
+// WipeIndexes sets every wordlist index of a mnemonic to zero.
+func WipeIndexes(indexes []uint16) {
+	for i := range indexes {
+		indexes[i] = 0
+	}
+}
+
+// wipeBigInt sets the words backing i to zero, and i to zero.
+func wipeBigInt(i *big.Int) {
+	if i == nil {
+		return
+	}
+
+	words := i.Bits()
+	for j := range words {
+		words[j] = 0
+	}
+
+	i.SetInt64(0)
+}
+
+// WipeExtendedKey zeroes the private key and the chain code of key.
+func WipeExtendedKey(key *hdkeychain.ExtendedKey) {
+	if key != nil {
+		key.Zero()
+	}
+}
+
+// WipeECPrivateKey zeroes the secp256k1 private scalar of key.
+func WipeECPrivateKey(key *btcec.PrivateKey) {
+	if key != nil {
+		wipeBigInt(key.D)
+	}
+}
+
+// WipeECDSAPrivateKey zeroes the private scalar of key.
+func WipeECDSAPrivateKey(key *ecdsa.PrivateKey) {
+	if key != nil {
+		wipeBigInt(key.D)
+	}
+}
+
+// WipeEd25519PrivateKey zeroes the seed and the public key of key.
+func WipeEd25519PrivateKey(key ed25519.PrivateKey) {
+	Wipe(key)
+}
@@ -0,0 +1,202 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/cosmos/go-bip39"
+	"github.com/stretchr/testify/require"
+)
+
+func requireWiped(t *testing.T, b []byte) {
+	t.Helper()
+	require.Equal(t, make([]byte, len(b)), b)
+}
+
+func TestWipe(t *testing.T) {
+	a := []byte{1, 2, 3}
+	b := []byte{4, 5}
+
+	Wipe(a, b, nil)
+	requireWiped(t, a)
+	requireWiped(t, b)
+}
+
+func TestWipeBigInt(t *testing.T) {
+	i, ok := new(big.Int).SetString("c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e5349553", 16)
+	require.True(t, ok)
+
+	words := i.Bits()
+	wipeBigInt(i)
+
+	require.Zero(t, i.Sign())
+	for _, w := range words {
+		require.Zero(t, w)
+	}
+
+	wipeBigInt(nil)
+}
+
+func TestWipeKeys(t *testing.T) {
+	seed, err := MasterSeed(standardEntropy, nil)
+	require.NoError(t, err)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	pk, err := master.ECPrivKey()
+	require.NoError(t, err)
+
+	words := pk.D.Bits()
+	WipeECPrivateKey(pk)
+	for _, w := range words {
+		require.Zero(t, w)
+	}
+
+	WipeExtendedKey(master)
+	require.False(t, master.IsPrivate())
+	_, err = master.ECPrivKey()
+	require.Error(t, err)
+
+	edKey, err := SLIP10Ed25519Key(seed, DerivationPath{Hardened(0)})
+	require.NoError(t, err)
+	WipeEd25519PrivateKey(edKey)
+	requireWiped(t, edKey)
+
+	p256Key, err := SLIP10P256Key(seed, DerivationPath{Hardened(0)})
+	require.NoError(t, err)
+	words = p256Key.D.Bits()
+	WipeECDSAPrivateKey(p256Key)
+	for _, w := range words {
+		require.Zero(t, w)
+	}
+}
+
+func TestKeyFromPathLeavesParentUntouched(t *testing.T) {
+	seed, err := MasterSeed(standardEntropy, nil)
+	require.NoError(t, err)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	serialized := master.String()
+
+	_, err = KeyFromPath(master, BIP44Path(118, 0, 0, 0))
+	require.NoError(t, err)
+	require.Equal(t, serialized, master.String())
+}
+
+func TestMnemonicIndexes(t *testing.T) {
+	for _, size := range []int{16, 20, 24, 28, 32} {
+		entropy := make([]byte, size)
+		_, err := rand.Read(entropy)
+		require.NoError(t, err)
+
+		expected, err := bip39.NewMnemonic(entropy)
+		require.NoError(t, err)
+
+		indexes, err := MnemonicIndexes(entropy)
+		require.NoError(t, err)
+
+		words, err := MnemonicWords(indexes)
+		require.NoError(t, err)
+		require.Equal(t, expected, strings.Join(words, " "))
+
+		decoded, err := MnemonicEntropy(indexes)
+		if ValidMnemonicLength(len(indexes)) {
+			require.NoError(t, err)
+			require.Equal(t, entropy, decoded)
+		} else {
+			require.Error(t, err)
+		}
+	}
+
+	_, err := MnemonicIndexes(make([]byte, 15))
+	require.Error(t, err)
+}
+
+func TestMnemonicEntropyRejectsBadChecksum(t *testing.T) {
+	indexes, err := MnemonicIndexesOf(standardMnemonic)
+	require.NoError(t, err)
+
+	indexes[len(indexes)-1] ^= 1
+	_, err = MnemonicEntropy(indexes)
+	require.Error(t, err)
+
+	indexes[len(indexes)-1] = mnemonicWordlistSize
+	_, err = MnemonicEntropy(indexes)
+	require.Error(t, err)
+}
+
+func TestMnemonicWord(t *testing.T) {
+	raw := []byte(" IVory\n")
+
+	word, found := MnemonicWord(raw)
+	require.True(t, found)
+	require.Equal(t, "ivory", word)
+
+	// the returned word is the wordlist one, not a copy of raw
+	Wipe(raw)
+	require.Equal(t, "ivory", word)
+
+	for _, w := range []string{"", "ivor", "ivoryivory", "ivöry"} {
+		_, found := MnemonicWord([]byte(w))
+		require.False(t, found, w)
+	}
+}
+
+func TestMnemonicSeedMatchesBIP39(t *testing.T) {
+	mnemonic, err := bip39.NewMnemonic(standardEntropy)
+	require.NoError(t, err)
+
+	seed, err := MasterSeed(standardEntropy, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(bip39.NewSeed(mnemonic, "TREZOR")), hex.EncodeToString(seed))
+}
+
+func TestSessionWipe(t *testing.T) {
+	passphrase := []byte("hidden wallet")
+	s := Session{Passphrase: passphrase, Decoy: true}
+
+	s.Wipe()
+	requireWiped(t, passphrase)
+	require.Equal(t, Session{}, s)
+}
+
+func Test_dumbToken_SetPassphraseCopies(t *testing.T) {
+	dt := seededToken(t)
+
+	require.NoError(t, dt.SetPassphrase([]byte("hidden wallet")))
+	expected, err := dt.Fingerprint()
+	require.NoError(t, err)
+
+	passphrase := []byte("hidden wallet")
+	require.NoError(t, dt.SetPassphrase(passphrase))
+	Wipe(passphrase)
+
+	fp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+
+	// the previous passphrase is wiped when replaced, the current one when the token is wiped
+	previous := dt.session.Passphrase
+	require.NoError(t, dt.SetPassphrase([]byte("other wallet")))
+	requireWiped(t, previous)
+
+	current := dt.session.Passphrase
+	require.NoError(t, dt.Wipe())
+	requireWiped(t, current)
+}
+
+func TestJoinWords(t *testing.T) {
+	joined := JoinWords(standardMnemonic)
+	require.Equal(t, strings.Join(standardMnemonic, " "), string(joined))
+	require.Equal(t, len(joined), cap(joined))
+
+	require.Empty(t, JoinWords(nil))
+}
@@ -0,0 +1,423 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/cosmos/go-bip39"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	seedKey = "crypto/seed"
+
+	// slip39SeedKey holds the seeds recovered from SLIP-39 shares, in place of seedKey.
+	slip39SeedKey = "crypto/slip39-seed"
+
+	// decoyPrefix namespaces the decoy seed in the Token storage.
+	decoyPrefix = "decoy/"
+
+	maxPassphraseLength = 100
+)
+
+// SeedType tells how the BIP-32 seed of a wallet is obtained from the device seed.
+type SeedType byte
+
+const (
+	// SeedBIP39 seeds are BIP-39 entropy: the BIP-32 seed is their mnemonic, stretched with the passphrase.
+	SeedBIP39 SeedType = iota
+
+	// SeedSLIP39 seeds are SLIP-39 encrypted master secrets: the BIP-32 seed is the master secret
+	// they decrypt to with the passphrase.
+	SeedSLIP39
+)
+
+// Seed is the seed of a profile, as held in storage.
+type Seed struct {
+	Type SeedType
+
+	// Entropy is the BIP-39 entropy of SeedBIP39 seeds.
+	Entropy []byte
+
+	// SLIP39 is the encrypted master secret of SeedSLIP39 seeds.
+	SLIP39 SLIP39Secret
+}
+
+// Wipe zeroes the secrets of s.
+func (s Seed) Wipe() {
+	Wipe(s.Entropy, s.SLIP39.Encrypted)
+}
+
+// MasterSeed returns the BIP-32 seed of the wallet of s for passphrase.
+// The caller owns the returned slice, and should wipe it once done.
+func (s Seed) MasterSeed(passphrase []byte) ([]byte, error) {
+	switch s.Type {
+	case SeedBIP39:
+		return MasterSeed(s.Entropy, passphrase)
+	case SeedSLIP39:
+		return s.SLIP39.MasterSecret(passphrase)
+	default:
+		return nil, fmt.Errorf("unknown seed type %v", s.Type)
+	}
+}
+
+// storageKey returns the key seeds of type t are held at.
+func (t SeedType) storageKey() string {
+	if t == SeedSLIP39 {
+		return slip39SeedKey
+	}
+
+	return seedKey
+}
+
+// encode returns the stored form of s: the entropy of SeedBIP39 seeds, and the identifier, the
+// extendable flag and the iteration exponent of SeedSLIP39 seeds followed by their encrypted master secret.
+// The caller owns the returned slice, and should wipe it once done.
+func (s Seed) encode() []byte {
+	if s.Type == SeedBIP39 {
+		return append([]byte{}, s.Entropy...)
+	}
+
+	flags := byte(s.SLIP39.IterationExponent)
+	if s.SLIP39.Extendable {
+		flags |= 0x10
+	}
+
+	ret := make([]byte, 3, 3+len(s.SLIP39.Encrypted))
+	binary.BigEndian.PutUint16(ret, s.SLIP39.Identifier)
+	ret[2] = flags
+
+	return append(ret, s.SLIP39.Encrypted...)
+}
+
+// decodeSLIP39Seed decodes the stored form of a SeedSLIP39 seed.
+func decodeSLIP39Seed(raw []byte) (Seed, error) {
+	if len(raw) < 3+slip39MinSecretSize || len(raw)%2 != 1 {
+		return Seed{}, fmt.Errorf("malformed SLIP-39 seed")
+	}
+
+	return Seed{
+		Type: SeedSLIP39,
+		SLIP39: SLIP39Secret{
+			Identifier:        binary.BigEndian.Uint16(raw) & slip39IDMask,
+			Extendable:        raw[2]&0x10 != 0,
+			IterationExponent: int(raw[2] & 0x0F),
+			Encrypted:         append([]byte{}, raw[3:]...),
+		},
+	}, nil
+}
+
+// ErrNoSeed is returned by Tokens asked to derive keys before the device has been set up.
+var ErrNoSeed = errors.New("device has no seed, it must be set up first")
+
+// Seeder is implemented by Tokens which hold a per-device seed.
+type Seeder interface {
+	// HasSeed returns true if the device has been set up with a seed.
+	HasSeed() (bool, error)
+
+	// HasAnySeed returns true if any profile holds a device or a decoy seed, whatever the session:
+	// unlike HasSeed, it tells whether the device holds secrets at all.
+	HasAnySeed() (bool, error)
+
+	// GenerateSeed sets the device up with entropyBits bits of fresh entropy.
+	GenerateSeed(entropyBits int) error
+
+	// ImportSeed replaces the device seed with the one encoded by the BIP-39 mnemonic words.
+	ImportSeed(words []string) error
+
+	// ImportSLIP39Shares replaces the device seed with the one recovered from the SLIP-39 mnemonic shares.
+	ImportSLIP39Shares(shares [][]string) error
+
+	// SetPassphrase sets the BIP-39 passphrase keys are derived with, until the device restarts.
+	// The empty passphrase selects the standard wallet.
+	// The Token keeps its own copy of passphrase, which the caller can wipe.
+	SetPassphrase(passphrase []byte) error
+
+	// UseDecoy switches every seed operation to the decoy seed, until the device restarts.
+	// Seed operations behave the same on either seed, so that callers can't tell which one is in use.
+	UseDecoy(decoy bool)
+
+	// Wipe deletes the device and the decoy seeds of every profile, and the profiles.
+	Wipe() error
+}
+
+// Session holds the volatile state Tokens derive keys with.
+type Session struct {
+	// Passphrase is the BIP-39 passphrase.
+	Passphrase []byte
+
+	// Decoy selects the decoy seed instead of the device one.
+	Decoy bool
+}
+
+// Wipe zeroes the passphrase of s, and resets s to the standard wallet of the device seed.
+func (s *Session) Wipe() {
+	Wipe(s.Passphrase)
+	*s = Session{}
+}
+
+// SeedStorage returns the Storage holding the profiles selected by session, out of s: the seed
+// operations use is held by the ActiveProfileStorage of the returned Storage.
+func SeedStorage(s storage.Storage, session Session) storage.Storage {
+	if session.Decoy {
+		return storage.NewPrefixed(s, decoyPrefix)
+	}
+
+	return s
+}
+
+// DeviceToken is the privileged interface of a Token, which sets its seed up and reveals its secrets.
+// Only the components managing the device, like the DEVICE app, should be handed one: apps get a Token.
+type DeviceToken interface {
+	Token
+	Seeder
+	Profiler
+	Secrets
+	Attester
+}
+
+// validEntropyBits returns an error if entropyBits isn't a supported seed entropy size: the ones of
+// 12, 18 and 24 words BIP-39 mnemonics.
+func validEntropyBits(entropyBits int) error {
+	if entropyBits != 128 && entropyBits != 192 && entropyBits != 256 {
+		return fmt.Errorf("unsupported entropy size %v, must be either 128, 192 or 256 bits", entropyBits)
+	}
+
+	return nil
+}
+
+// ReadSeed returns the seed held in s, or ErrNoSeed.
+// The caller owns the returned Seed, and should wipe it once done.
+func ReadSeed(s storage.Storage) (Seed, error) {
+	entropy, err := s.Get(seedKey)
+	if err == nil {
+		return Seed{Type: SeedBIP39, Entropy: entropy}, nil
+	}
+
+	if !errors.Is(err, storage.ErrNotFound) {
+		return Seed{}, fmt.Errorf("cannot read seed, %w", err)
+	}
+
+	raw, err := s.Get(slip39SeedKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return Seed{}, ErrNoSeed
+	}
+
+	if err != nil {
+		return Seed{}, fmt.Errorf("cannot read seed, %w", err)
+	}
+
+	defer Wipe(raw)
+
+	return decodeSLIP39Seed(raw)
+}
+
+// storeSeed stores seed in s, replacing any existing seed.
+// The seed of the other type is deleted first: if storing seed fails, s is left without a seed
+// rather than with two.
+func storeSeed(s storage.Storage, seed Seed) error {
+	other := SeedBIP39
+	if seed.Type == SeedBIP39 {
+		other = SeedSLIP39
+	}
+
+	if err := s.Delete(other.storageKey()); err != nil {
+		return fmt.Errorf("cannot replace seed, %w", err)
+	}
+
+	raw := seed.encode()
+	defer Wipe(raw)
+
+	return s.Set(seed.Type.storageKey(), raw)
+}
+
+// deleteSeed deletes the seed held in s, whatever its type.
+func deleteSeed(s storage.Storage) error {
+	for _, key := range []string{seedKey, slip39SeedKey} {
+		if err := s.Delete(key); err != nil {
+			return fmt.Errorf("cannot wipe seed, %w", err)
+		}
+	}
+
+	return nil
+}
+
+// HasSeed returns true if s holds a seed.
+func HasSeed(s storage.Storage) (bool, error) {
+	seed, err := ReadSeed(s)
+	seed.Wipe()
+
+	switch {
+	case errors.Is(err, ErrNoSeed):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// AnySeed returns true if any profile held in s holds a device or a decoy seed.
+func AnySeed(s storage.Storage) (bool, error) {
+	for _, ss := range []storage.Storage{s, SeedStorage(s, Session{Decoy: true})} {
+		labels, err := Profiles(ss)
+		if err != nil {
+			return false, err
+		}
+
+		for _, label := range labels {
+			has, err := HasSeed(ProfileStorage(ss, label))
+			if err != nil || has {
+				return has, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// GenerateSeed stores entropyBits bits of entropy read from t in s.
+// Existing seeds are never overwritten.
+func GenerateSeed(t Token, s storage.Storage, entropyBits int) error {
+	if err := validEntropyBits(entropyBits); err != nil {
+		return err
+	}
+
+	found, err := HasSeed(s)
+	if err != nil {
+		return err
+	}
+
+	if found {
+		return fmt.Errorf("device already has a seed")
+	}
+
+	entropy, err := t.RandomBytes(uint64(entropyBits / 8))
+	if err != nil {
+		return fmt.Errorf("cannot generate seed entropy, %w", err)
+	}
+
+	defer Wipe(entropy)
+
+	return storeSeed(s, Seed{Type: SeedBIP39, Entropy: entropy})
+}
+
+// WipeSeed deletes the device and the decoy seeds of every profile held in s, and the profiles.
+func WipeSeed(s storage.Storage) error {
+	for _, ss := range []storage.Storage{s, SeedStorage(s, Session{Decoy: true})} {
+		if err := wipeProfiles(ss); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidMnemonicLength returns true if a BIP-39 mnemonic made of count words can be imported.
+func ValidMnemonicLength(count int) bool {
+	return count == 12 || count == 18 || count == 24
+}
+
+// IsMnemonicWord returns true if word is part of the BIP-39 english wordlist.
+func IsMnemonicWord(word string) bool {
+	_, found := bip39.ReverseWordMap[word]
+	return found
+}
+
+// ImportSeed validates the BIP-39 mnemonic words and stores the entropy they encode in s,
+// replacing any existing seed.
+func ImportSeed(s storage.Storage, words []string) error {
+	if !ValidMnemonicLength(len(words)) {
+		return fmt.Errorf("unsupported mnemonic length %v, must be either 12, 18 or 24 words", len(words))
+	}
+
+	indexes, err := MnemonicIndexesOf(words)
+	if err != nil {
+		return err
+	}
+
+	defer WipeIndexes(indexes)
+
+	entropy, err := MnemonicEntropy(indexes)
+	if err != nil {
+		return err
+	}
+
+	defer Wipe(entropy)
+
+	return storeSeed(s, Seed{Type: SeedBIP39, Entropy: entropy})
+}
+
+// ImportSLIP39Seed recovers the encrypted master secret split into the SLIP-39 mnemonic shares, and
+// stores it in s as a SeedSLIP39 seed, replacing any existing seed.
+// Like every SLIP-39 wallet, the master secret is decrypted with the passphrase only when keys are
+// derived, and is used as is as the BIP-32 seed.
+func ImportSLIP39Seed(s storage.Storage, shares [][]string) error {
+	secret, err := SLIP39CombineEncrypted(shares)
+	if err != nil {
+		return err
+	}
+
+	defer Wipe(secret.Encrypted)
+
+	if err := validEntropyBits(len(secret.Encrypted) * 8); err != nil {
+		return err
+	}
+
+	return storeSeed(s, Seed{Type: SeedSLIP39, SLIP39: secret})
+}
+
+// ValidPassphrase returns an error if passphrase can't be used as a BIP-39 passphrase.
+// Only printable ASCII is accepted, since it's left unchanged by the NFKD normalization
+// BIP-39 mandates, and can be typed on any host.
+func ValidPassphrase(passphrase []byte) error {
+	if len(passphrase) > maxPassphraseLength {
+		return fmt.Errorf("passphrase is longer than %v characters", maxPassphraseLength)
+	}
+
+	for _, c := range passphrase {
+		if c < 0x20 || c > 0x7E {
+			return fmt.Errorf("passphrase must only contain printable ASCII characters")
+		}
+	}
+
+	return nil
+}
+
+// MasterSeed returns the BIP-39 seed of the mnemonic encoding entropy, stretched with passphrase
+// through PBKDF2-HMAC-SHA512.
+// The mnemonic is never turned into a string: the caller owns the returned slice, and should wipe it once done.
+func MasterSeed(entropy []byte, passphrase []byte) ([]byte, error) {
+	if err := ValidPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	seed, err := mnemonicSeed(entropy, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode seed mnemonic, %w", err)
+	}
+
+	return seed, nil
+}
+
+// Fingerprint returns the BIP-32 fingerprint of the master key generated from seed, that is
+// the first four bytes of the HASH160 of its public key.
+func Fingerprint(seed []byte) ([]byte, error) {
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	defer WipeExtendedKey(master)
+
+	pk, err := master.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return btcutil.Hash160(pk.SerializeCompressed())[:4], nil
+}
@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/cosmos/btcutil/bech32"
+	"github.com/wallera-computer/wallera/entropy"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// knownAnswerTest checks a primitive the Token relies on against a known answer.
+type knownAnswerTest struct {
+	name string
+	run  func() error
+}
+
+var knownAnswerTests = []knownAnswerTest{
+	{"SHA-256", sha256SelfTest},
+	{"RIPEMD-160", ripemd160SelfTest},
+	{"BIP-32", bip32SelfTest},
+	{"ECDSA", ecdsaSelfTest},
+	{"BIP-340", schnorrSelfTest},
+	{"bech32", bech32SelfTest},
+	{"HMAC-DRBG", entropy.SelfTest},
+}
+
+// SelfTest runs known-answer tests of the primitives the Token relies on, and health tests of the random
+// number generator of t.
+// Devices must refuse to run when it returns an error, before any host can talk to them.
+func SelfTest(t Token) error {
+	for _, kat := range knownAnswerTests {
+		if err := kat.run(); err != nil {
+			return fmt.Errorf("%v self-test failed, %w", kat.name, err)
+		}
+	}
+
+	if err := rngSelfTest(t); err != nil {
+		return fmt.Errorf("random number generator self-test failed, %w", err)
+	}
+
+	return nil
+}
+
+// expectHex returns an error if got isn't the hex-encoded expected value.
+func expectHex(got []byte, expected string) error {
+	if hex.EncodeToString(got) != expected {
+		return fmt.Errorf("got %x, expected %v", got, expected)
+	}
+
+	return nil
+}
+
+func sha256SelfTest() error {
+	digest := sha256.Sum256([]byte("abc"))
+	return expectHex(digest[:], "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+}
+
+func ripemd160SelfTest() error {
+	r := ripemd160.New()
+	_, _ = r.Write([]byte("abc"))
+	return expectHex(r.Sum(nil), "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc")
+}
+
+// bip32SelfTest derives m/0'/1 out of the seed of the first BIP-32 test vector.
+func bip32SelfTest() error {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return err
+	}
+
+	key, err := KeyFromPath(master, DerivationPath{Hardened(0), 1})
+	if err != nil {
+		return err
+	}
+
+	const expected = "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs"
+	if key.String() != expected {
+		return fmt.Errorf("got %v, expected %v", key.String(), expected)
+	}
+
+	return nil
+}
+
+// ecdsaSelfTest signs with the secp256k1 private key 1, whose RFC 6979 nonces are well known, and checks
+// that both the signature and a tampered copy of it verify as they should.
+func ecdsaSelfTest() error {
+	one := make([]byte, 32)
+	one[31] = 1
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), one)
+	digest := sha256.Sum256([]byte("Satoshi Nakamoto"))
+
+	signature, err := key.Sign(digest[:])
+	if err != nil {
+		return err
+	}
+
+	return signatureSelfTest(AlgoSecp256K1, key, digest[:], signature.Serialize(),
+		"3045022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d802202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5")
+}
+
+// schnorrSelfTest runs the first BIP-340 test vector.
+func schnorrSelfTest() error {
+	secret := make([]byte, 32)
+	secret[31] = 3
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), secret)
+	digest := make([]byte, 32)
+
+	signature, err := SignSchnorr(key, digest, make([]byte, 32))
+	if err != nil {
+		return err
+	}
+
+	return signatureSelfTest(AlgoSecp256K1Schnorr, key, digest, signature,
+		"e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0")
+}
+
+func signatureSelfTest(algorithm Algorithm, key *btcec.PrivateKey, digest, signature []byte, expected string) error {
+	if err := expectHex(signature, expected); err != nil {
+		return err
+	}
+
+	publicKey := key.PubKey().SerializeCompressed()
+	if !VerifySignature(algorithm, publicKey, digest, signature) {
+		return fmt.Errorf("valid signature doesn't verify")
+	}
+
+	tampered := append([]byte{}, signature...)
+	tampered[len(tampered)-1] ^= 1
+	if VerifySignature(algorithm, publicKey, digest, tampered) {
+		return fmt.Errorf("tampered signature verifies")
+	}
+
+	return nil
+}
+
+// bech32SelfTest encodes every 5 bits value with one of the BIP-173 test vectors, and decodes it back.
+func bech32SelfTest() error {
+	data := make([]byte, 32)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	const expected = "abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw"
+
+	encoded, err := bech32.Encode("abcdef", data)
+	if err != nil {
+		return err
+	}
+
+	if encoded != expected {
+		return fmt.Errorf("got %v, expected %v", encoded, expected)
+	}
+
+	hrp, decoded, err := bech32.Decode(expected, len(expected))
+	if err != nil {
+		return err
+	}
+
+	if hrp != "abcdef" || !bytes.Equal(decoded, data) {
+		return fmt.Errorf("got %v %v, expected abcdef %v", hrp, decoded, data)
+	}
+
+	return nil
+}
+
+// rngSelfTest catches random number generators stuck on a value, or repeating their output.
+func rngSelfTest(t Token) error {
+	a, err := t.RandomBytes(32)
+	if err != nil {
+		return err
+	}
+
+	b, err := t.RandomBytes(32)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(a, b) {
+		return fmt.Errorf("output repeated")
+	}
+
+	for _, block := range [][]byte{a, b} {
+		if bytes.Count(block, block[:1]) == len(block) {
+			return fmt.Errorf("output stuck at %#x", block[0])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func TestSelfTest(t *testing.T) {
+	require.NoError(t, SelfTest(NewDumbToken(storage.NewMemory())))
+}
+
+// stuckToken is a Token whose random number generator always returns the same bytes.
+type stuckToken struct {
+	Token
+	value byte
+}
+
+func (st stuckToken) RandomBytes(amount uint64) ([]byte, error) {
+	return bytes.Repeat([]byte{st.value}, int(amount)), nil
+}
+
+func TestSelfTestRejectsStuckRNG(t *testing.T) {
+	require.Error(t, SelfTest(stuckToken{value: 0}))
+	require.Error(t, SelfTest(stuckToken{value: 0xff}))
+}
+
+func TestVerifySignature(t *testing.T) {
+	dt := seededToken(t)
+	// ed25519 only derives hardened components
+	path := DerivationPath{Hardened(44), Hardened(118), Hardened(0)}
+	otherPath := DerivationPath{Hardened(44), Hardened(118), Hardened(1)}
+	message := []byte("message")
+
+	for _, algorithm := range dt.SupportedSignAlgorithms() {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			hash := HashSHA256
+			if algorithm == AlgoEd25519 {
+				hash = HashNone
+			}
+
+			digest, err := MessageDigest(algorithm, hash, message)
+			require.NoError(t, err)
+
+			if digest == nil {
+				digest = message
+			}
+
+			pk, err := dt.PublicKey(path, algorithm)
+			require.NoError(t, err)
+
+			signature, err := dt.SignMessage(path, algorithm, hash, message)
+			require.NoError(t, err)
+			require.True(t, VerifySignature(algorithm, pk, digest, signature))
+
+			other, err := dt.PublicKey(otherPath, algorithm)
+			require.NoError(t, err)
+			require.False(t, VerifySignature(algorithm, other, digest, signature))
+
+			_, err = ReleaseSignature(algorithm, other, digest, signature)
+			require.ErrorIs(t, err, ErrFaultySignature)
+		})
+	}
+
+	require.False(t, VerifySignature(AlgoX25519, pubKeyBytes(t), make([]byte, DigestSize), make([]byte, 64)))
+}
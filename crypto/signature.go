@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// ErrFaultySignature is returned by Tokens in place of signatures which don't verify against the
+// public key they were made with.
+// Faulty signatures are the footprint of fault injection, like voltage or clock glitches, and can
+// leak the key they were made with: they never leave the Token.
+var ErrFaultySignature = errors.New("signature doesn't verify against the signing key, refusing to release it")
+
+// VerifySignature returns true if signature is a valid signature of digest by publicKey, for algorithm.
+// publicKey is encoded like Token.PublicKey returns it, and digest is the message for ed25519.
+func VerifySignature(algorithm Algorithm, publicKey, digest, signature []byte) bool {
+	switch algorithm {
+	case AlgoSecp256K1:
+		pk, err := btcec.ParsePubKey(publicKey, btcec.S256())
+		if err != nil {
+			return false
+		}
+
+		sig, err := btcec.ParseDERSignature(signature, btcec.S256())
+		if err != nil {
+			return false
+		}
+
+		return sig.Verify(digest, pk)
+	case AlgoSecp256K1Schnorr:
+		xonly, err := XOnlyPublicKey(publicKey)
+		if err != nil {
+			return false
+		}
+
+		return VerifySchnorr(xonly, digest, signature)
+	case AlgoP256:
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKey)
+		if x == nil {
+			return false
+		}
+
+		return ecdsa.VerifyASN1(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest, signature)
+	case AlgoEd25519:
+		if len(publicKey) != ed25519.PublicKeySize {
+			return false
+		}
+
+		return ed25519.Verify(publicKey, digest, signature)
+	default:
+		return false
+	}
+}
+
+// ReleaseSignature returns signature if it verifies against publicKey, ErrFaultySignature otherwise.
+// Tokens pass every signature they make through it.
+func ReleaseSignature(algorithm Algorithm, publicKey, digest, signature []byte) ([]byte, error) {
+	if !VerifySignature(algorithm, publicKey, digest, signature) {
+		return nil, ErrFaultySignature
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,144 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// SLIP-0010 master key HMAC keys.
+const (
+	slip10Ed25519Seed = "ed25519 seed"
+	slip10P256Seed    = "Nist256p1 seed"
+)
+
+// slip10Key is a SLIP-0010 extended private key.
+type slip10Key struct {
+	key       []byte
+	chainCode []byte
+}
+
+// wipe zeroes k, which may share its buffers with the HMAC output it has been split from.
+func (k slip10Key) wipe() {
+	Wipe(k.key, k.chainCode)
+}
+
+func hmacSHA512(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha512.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+
+	return h.Sum(nil)
+}
+
+func ser32(i uint32) []byte {
+	ret := make([]byte, 4)
+	binary.BigEndian.PutUint32(ret, i)
+	return ret
+}
+
+// validScalar returns true if k is a valid private key for a curve of order n.
+func validScalar(k []byte, n *big.Int) bool {
+	i := new(big.Int).SetBytes(k)
+	return i.Sign() != 0 && i.Cmp(n) < 0
+}
+
+// SLIP10Ed25519Key derives the ed25519 private key at path from the BIP-39 seed, as defined by SLIP-0010.
+// ed25519 only supports hardened derivation.
+func SLIP10Ed25519Key(seed []byte, path DerivationPath) (ed25519.PrivateKey, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	i := hmacSHA512([]byte(slip10Ed25519Seed), seed)
+	k := slip10Key{i[:32], i[32:]}
+	defer func() { k.wipe() }()
+
+	for _, c := range path {
+		if c < HardenedKeyStart {
+			return nil, fmt.Errorf("ed25519 derivation path %v must only have hardened components", path)
+		}
+
+		i := hmacSHA512(k.chainCode, []byte{0x00}, k.key, ser32(c))
+		k.wipe()
+		k = slip10Key{i[:32], i[32:]}
+	}
+
+	return ed25519.NewKeyFromSeed(k.key), nil
+}
+
+// SLIP10P256Key derives the NIST P-256 private key at path from the BIP-39 seed, as defined by SLIP-0010.
+func SLIP10P256Key(seed []byte, path DerivationPath) (*ecdsa.PrivateKey, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	i := hmacSHA512([]byte(slip10P256Seed), seed)
+	for !validScalar(i[:32], n) {
+		next := hmacSHA512([]byte(slip10P256Seed), i)
+		Wipe(i)
+		i = next
+	}
+
+	k := slip10Key{i[:32], i[32:]}
+	defer func() { k.wipe() }()
+
+	for _, c := range path {
+		var data []byte
+		if c >= HardenedKeyStart {
+			data = append([]byte{0x00}, k.key...)
+		} else {
+			x, y := curve.ScalarBaseMult(k.key)
+			data = elliptic.MarshalCompressed(curve, x, y)
+		}
+
+		i := hmacSHA512(k.chainCode, data, ser32(c))
+		Wipe(data)
+
+		for {
+			il := new(big.Int).SetBytes(i[:32])
+			parent := new(big.Int).SetBytes(k.key)
+			child := new(big.Int).Add(il, parent)
+			child.Mod(child, n)
+
+			valid := il.Cmp(n) < 0 && child.Sign() != 0
+			wipeBigInt(il)
+			wipeBigInt(parent)
+
+			if valid {
+				Wipe(i[:32])
+				k.wipe()
+				k = slip10Key{bytes32(child), i[32:]}
+				wipeBigInt(child)
+				break
+			}
+
+			wipeBigInt(child)
+
+			next := hmacSHA512(k.chainCode, []byte{0x01}, i[32:], ser32(c))
+			Wipe(i)
+			i = next
+		}
+	}
+
+	d := new(big.Int).SetBytes(k.key)
+	x, y := curve.ScalarBaseMult(k.key)
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     x,
+			Y:     y,
+		},
+		D: d,
+	}, nil
+}
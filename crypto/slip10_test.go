@@ -0,0 +1,137 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// SLIP-0010 test vector 1
+const slip10Seed = "000102030405060708090a0b0c0d0e0f"
+
+func TestSLIP10Ed25519Key(t *testing.T) {
+	tests := []struct {
+		path    string
+		private string
+		public  string
+	}{
+		{
+			"m",
+			"2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+			"a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed",
+		},
+		{
+			"m/0'/1'/2'/2'/1000000000'",
+			"8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793",
+			"3c24da049451555d51a7014a37337aa4e12d41e485abccfa46b47dfb2af54b7a",
+		},
+	}
+
+	seed, err := hex.DecodeString(slip10Seed)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			dp, err := ParseDerivationPath(tt.path)
+			require.NoError(t, err)
+
+			key, err := SLIP10Ed25519Key(seed, dp)
+			require.NoError(t, err)
+			require.Equal(t, tt.private, hex.EncodeToString(key.Seed()))
+			require.Equal(t, tt.public, hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+		})
+	}
+
+	_, err = SLIP10Ed25519Key(seed, DerivationPath{Hardened(0), 1})
+	require.Error(t, err)
+}
+
+func TestSLIP10P256Key(t *testing.T) {
+	tests := []struct {
+		path    string
+		private string
+		public  string
+	}{
+		{
+			"m",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			"0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8",
+		},
+		{
+			"m/0'/1/2'/2/1000000000",
+			"21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119",
+			"02216cd26d31147f72427a453c443ed2cde8a1e53c9cc44e5ddf739725413fe3f4",
+		},
+	}
+
+	seed, err := hex.DecodeString(slip10Seed)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			dp, err := ParseDerivationPath(tt.path)
+			require.NoError(t, err)
+
+			key, err := SLIP10P256Key(seed, dp)
+			require.NoError(t, err)
+			require.Equal(t, tt.private, hex.EncodeToString(bytes32(key.D)))
+			require.Equal(t, tt.public, hex.EncodeToString(elliptic.MarshalCompressed(key.Curve, key.X, key.Y)))
+		})
+	}
+}
+
+func Test_dumbToken_Ed25519(t *testing.T) {
+	dt := seededToken(t)
+	require.Contains(t, dt.SupportedSignAlgorithms(), AlgoEd25519)
+
+	// Solana's path
+	path := DerivationPath{Hardened(44), Hardened(501), Hardened(0), Hardened(0)}
+
+	pk, err := dt.PublicKey(path, AlgoEd25519)
+	require.NoError(t, err)
+	require.Len(t, pk, ed25519.PublicKeySize)
+
+	msg := []byte("message")
+	sig, err := dt.SignMessage(path, AlgoEd25519, HashNone, msg)
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(pk, msg, sig))
+
+	// ed25519 signs messages only
+	_, err = dt.SignMessage(path, AlgoEd25519, HashSHA256, msg)
+	require.Error(t, err)
+
+	digest := sha256.Sum256(msg)
+	_, err = dt.SignDigest(path, AlgoEd25519, digest[:])
+	require.Error(t, err)
+
+	// ed25519 can't derive non-hardened children
+	_, err = dt.SignMessage(BIP44Path(501, 0, 0, 0), AlgoEd25519, HashNone, msg)
+	require.Error(t, err)
+}
+
+func Test_dumbToken_P256(t *testing.T) {
+	dt := seededToken(t)
+	require.Contains(t, dt.SupportedSignAlgorithms(), AlgoP256)
+	path := BIP44Path(0, 0, 0, 0)
+
+	pk, err := dt.PublicKey(path, AlgoP256)
+	require.NoError(t, err)
+
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), pk)
+	require.NotNil(t, x)
+
+	digest := sha256.Sum256([]byte("message"))
+	sig, err := dt.SignDigest(path, AlgoP256, digest[:])
+	require.NoError(t, err)
+	require.True(t, ecdsa.VerifyASN1(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, digest[:], sig))
+
+	// each curve has its own keys
+	secp, err := dt.PublicKey(path, AlgoSecp256K1)
+	require.NoError(t, err)
+	require.NotEqual(t, secp, pk)
+}
@@ -0,0 +1,740 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// SLIP-39 constants.
+const (
+	slip39RadixBits      = 10
+	slip39Radix          = 1 << slip39RadixBits
+	slip39IDMask         = 1<<15 - 1
+	slip39MaxShares      = 16
+	slip39HeaderWords    = 4
+	slip39ChecksumWords  = 3
+	slip39MetadataWords  = slip39HeaderWords + slip39ChecksumWords
+	slip39MinSecretSize  = 16
+	slip39MaxWordLen     = 8
+	slip39BaseIterations = 10000
+	slip39RoundCount     = 4
+	slip39DigestIndex    = 254
+	slip39SecretIndex    = 255
+	slip39DigestSize     = 4
+
+	slip39Customization           = "shamir"
+	slip39CustomizationExtendable = "shamir_extendable"
+)
+
+// slip39IterationExponent is the iteration exponent of the shares Tokens create, like most wallets do:
+// each Feistel round runs 5000 PBKDF2 iterations.
+const slip39IterationExponent = 1
+
+// SLIP39Group describes a group of SLIP-39 shares: Count member shares, Threshold of which
+// recover the group share.
+type SLIP39Group struct {
+	Threshold int
+	Count     int
+}
+
+// slip39Share is a decoded SLIP-39 mnemonic share.
+type slip39Share struct {
+	identifier        uint16
+	extendable        bool
+	iterationExponent int
+	groupIndex        int
+	groupThreshold    int
+	groupCount        int
+	memberIndex       int
+	memberThreshold   int
+	value             []byte
+}
+
+// ValidSLIP39Length returns true if a SLIP-39 mnemonic share made of count words can hold a
+// device seed, that is 128, 192 or 256 bits.
+func ValidSLIP39Length(count int) bool {
+	return count == slip39ShareWords(16) || count == slip39ShareWords(24) || count == slip39ShareWords(32)
+}
+
+// slip39ShareWords returns the amount of words of a share holding a secret of size bytes.
+func slip39ShareWords(size int) int {
+	return slip39MetadataWords + (size*8+slip39RadixBits-1)/slip39RadixBits
+}
+
+// SLIP39Word returns the SLIP-39 wordlist word matching raw, once trimmed and lowercased.
+// Like MnemonicWord, raw is normalized in a buffer which gets wiped, and the returned string is
+// a reference to the wordlist.
+func SLIP39Word(raw []byte) (string, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > slip39MaxWordLen {
+		return "", false
+	}
+
+	buf := [slip39MaxWordLen]byte{}
+	defer Wipe(buf[:])
+
+	for i, c := range raw {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+
+		buf[i] = c
+	}
+
+	idx, found := slip39Index(buf[:len(raw)])
+	if !found {
+		return "", false
+	}
+
+	return slip39WordList[idx], true
+}
+
+// slip39Index returns the wordlist index of word.
+func slip39Index(word []byte) (uint16, bool) {
+	idx := sort.Search(len(slip39WordList), func(i int) bool {
+		return slip39WordList[i] >= string(word)
+	})
+
+	if idx == len(slip39WordList) || slip39WordList[idx] != string(word) {
+		return 0, false
+	}
+
+	return uint16(idx), true
+}
+
+// SLIP39IndexesOf returns the wordlist indexes of the SLIP-39 mnemonic share words.
+// The caller owns the returned slice, and should wipe it once done.
+func SLIP39IndexesOf(words []string) ([]uint16, error) {
+	indexes := make([]uint16, len(words))
+	for i, w := range words {
+		idx, found := slip39Index([]byte(w))
+		if !found {
+			WipeIndexes(indexes)
+			return nil, fmt.Errorf("word %v is not in the SLIP-39 wordlist", i+1)
+		}
+
+		indexes[i] = idx
+	}
+
+	return indexes, nil
+}
+
+// SLIP39Words returns the SLIP-39 words at indexes, as references to the wordlist.
+func SLIP39Words(indexes []uint16) ([]string, error) {
+	words := make([]string, len(indexes))
+	for i, idx := range indexes {
+		if idx >= slip39Radix {
+			WipeWords(words)
+			return nil, fmt.Errorf("invalid SLIP-39 word index %v", idx)
+		}
+
+		words[i] = slip39WordList[idx]
+	}
+
+	return words, nil
+}
+
+// slip39Polymod is the RS1024 checksum function.
+func slip39Polymod(values []uint16) uint32 {
+	gen := [...]uint32{
+		0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009,
+		0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120,
+	}
+
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xFFFFF)<<10 ^ uint32(v)
+
+		for i, g := range gen {
+			if (b>>i)&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+
+	return chk
+}
+
+func slip39Customized(extendable bool, data []uint16) []uint16 {
+	cs := slip39Customization
+	if extendable {
+		cs = slip39CustomizationExtendable
+	}
+
+	values := make([]uint16, 0, len(cs)+len(data)+slip39ChecksumWords)
+	for _, c := range []byte(cs) {
+		values = append(values, uint16(c))
+	}
+
+	return append(values, data...)
+}
+
+func slip39Checksum(extendable bool, data []uint16) []uint16 {
+	values := slip39Customized(extendable, data)
+	defer WipeIndexes(values)
+
+	polymod := slip39Polymod(append(values, 0, 0, 0)) ^ 1
+
+	ret := make([]uint16, slip39ChecksumWords)
+	for i := range ret {
+		ret[i] = uint16(polymod>>(slip39RadixBits*(2-i))) & (slip39Radix - 1)
+	}
+
+	return ret
+}
+
+func slip39VerifyChecksum(extendable bool, indexes []uint16) bool {
+	values := slip39Customized(extendable, indexes)
+	defer WipeIndexes(values)
+
+	return slip39Polymod(values) == 1
+}
+
+// encode returns the wordlist indexes of s.
+func (s slip39Share) encode() []uint16 {
+	ext := uint64(0)
+	if s.extendable {
+		ext = 1
+	}
+
+	header := uint64(s.identifier)<<25 | ext<<24 | uint64(s.iterationExponent)<<20 |
+		uint64(s.groupIndex)<<16 | uint64(s.groupThreshold-1)<<12 | uint64(s.groupCount-1)<<8 |
+		uint64(s.memberIndex)<<4 | uint64(s.memberThreshold-1)
+
+	valueWords := (len(s.value)*8 + slip39RadixBits - 1) / slip39RadixBits
+	padding := valueWords*slip39RadixBits - len(s.value)*8
+
+	indexes := make([]uint16, slip39HeaderWords+valueWords, slip39HeaderWords+valueWords+slip39ChecksumWords)
+	for i := 0; i < slip39HeaderWords; i++ {
+		indexes[i] = uint16(header>>(slip39RadixBits*(slip39HeaderWords-1-i))) & (slip39Radix - 1)
+	}
+
+	// the value is left-padded with zero bits up to a multiple of the word size
+	for i := padding; i < valueWords*slip39RadixBits; i++ {
+		b := i - padding
+		bit := uint16(s.value[b/8]>>(7-b%8)) & 1
+
+		w := slip39HeaderWords + i/slip39RadixBits
+		indexes[w] |= bit << (slip39RadixBits - 1 - i%slip39RadixBits)
+	}
+
+	return append(indexes, slip39Checksum(s.extendable, indexes)...)
+}
+
+// decodeSLIP39Share decodes the share whose words have the wordlist indexes.
+func decodeSLIP39Share(indexes []uint16) (slip39Share, error) {
+	if len(indexes) < slip39ShareWords(slip39MinSecretSize) {
+		return slip39Share{}, fmt.Errorf("SLIP-39 share is too short, %v words", len(indexes))
+	}
+
+	for _, idx := range indexes {
+		if idx >= slip39Radix {
+			return slip39Share{}, fmt.Errorf("invalid SLIP-39 word index %v", idx)
+		}
+	}
+
+	header := uint64(0)
+	for _, idx := range indexes[:slip39HeaderWords] {
+		header = header<<slip39RadixBits | uint64(idx)
+	}
+
+	s := slip39Share{
+		identifier:        uint16(header>>25) & slip39IDMask,
+		extendable:        (header>>24)&1 == 1,
+		iterationExponent: int(header>>20) & 0xF,
+		groupIndex:        int(header>>16) & 0xF,
+		groupThreshold:    int(header>>12)&0xF + 1,
+		groupCount:        int(header>>8)&0xF + 1,
+		memberIndex:       int(header>>4) & 0xF,
+		memberThreshold:   int(header)&0xF + 1,
+	}
+
+	if !slip39VerifyChecksum(s.extendable, indexes) {
+		return slip39Share{}, fmt.Errorf("invalid SLIP-39 share checksum")
+	}
+
+	if s.groupThreshold > s.groupCount {
+		return slip39Share{}, fmt.Errorf("SLIP-39 group threshold %v exceeds the group count %v", s.groupThreshold, s.groupCount)
+	}
+
+	valueWords := indexes[slip39HeaderWords : len(indexes)-slip39ChecksumWords]
+	bits := len(valueWords) * slip39RadixBits
+
+	// secrets are an even amount of bytes long, so padding is less than 16 bits, and at most 8
+	padding := bits % 16
+	if padding > 8 {
+		return slip39Share{}, fmt.Errorf("invalid SLIP-39 share length")
+	}
+
+	s.value = make([]byte, (bits-padding)/8)
+	for i := 0; i < bits; i++ {
+		bit := byte(valueWords[i/slip39RadixBits]>>(slip39RadixBits-1-i%slip39RadixBits)) & 1
+		if i < padding {
+			if bit != 0 {
+				Wipe(s.value)
+				return slip39Share{}, fmt.Errorf("invalid SLIP-39 share padding")
+			}
+
+			continue
+		}
+
+		b := i - padding
+		s.value[b/8] |= bit << (7 - b%8)
+	}
+
+	return s, nil
+}
+
+// gfMul multiplies a and b in GF(256), as defined by the Rijndael polynomial x^8 + x^4 + x^3 + x + 1.
+// It runs in constant time, since share values are secret.
+func gfMul(a, b byte) byte {
+	p := byte(0)
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		a = a<<1 ^ (-(a >> 7) & 0x1B)
+		b >>= 1
+	}
+
+	return p
+}
+
+// gfInv returns the multiplicative inverse of a in GF(256), which is a^254.
+func gfInv(a byte) byte {
+	ret := byte(1)
+	for e := 254; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			ret = gfMul(ret, a)
+		}
+
+		a = gfMul(a, a)
+	}
+
+	return ret
+}
+
+// slip39Interpolate returns the value at x of the polynomials going through the points (xs[i], ys[i]).
+// The x coordinates are public, only the values are secret.
+func slip39Interpolate(xs []byte, ys [][]byte, x byte) ([]byte, error) {
+	for i, xi := range xs {
+		if xi == x {
+			return append([]byte{}, ys[i]...), nil
+		}
+	}
+
+	ret := make([]byte, len(ys[0]))
+	for i, xi := range xs {
+		if len(ys[i]) != len(ret) {
+			Wipe(ret)
+			return nil, fmt.Errorf("SLIP-39 share values have different lengths")
+		}
+
+		// Lagrange basis polynomial of xi, evaluated at x: subtraction is addition in GF(256)
+		basis := byte(1)
+		for j, xj := range xs {
+			if j == i {
+				continue
+			}
+
+			if xi == xj {
+				Wipe(ret)
+				return nil, fmt.Errorf("duplicate SLIP-39 share index %v", xi)
+			}
+
+			basis = gfMul(basis, gfMul(x^xj, gfInv(xi^xj)))
+		}
+
+		for k, y := range ys[i] {
+			ret[k] ^= gfMul(basis, y)
+		}
+	}
+
+	return ret, nil
+}
+
+func slip39Digest(randomPart, secret []byte) []byte {
+	h := hmac.New(sha256.New, randomPart)
+	h.Write(secret)
+	return h.Sum(nil)[:slip39DigestSize]
+}
+
+// slip39SplitSecret splits secret into count shares, threshold of which recover it.
+// Share i is the value at x = i.
+func slip39SplitSecret(random io.Reader, threshold, count int, secret []byte) ([][]byte, error) {
+	if threshold < 1 || threshold > count || count > slip39MaxShares {
+		return nil, fmt.Errorf("invalid SLIP-39 threshold %v of %v shares", threshold, count)
+	}
+
+	shares := make([][]byte, count)
+
+	if threshold == 1 {
+		for i := range shares {
+			shares[i] = append([]byte{}, secret...)
+		}
+
+		return shares, nil
+	}
+
+	xs := []byte{}
+	ys := [][]byte{}
+
+	for i := 0; i < threshold-2; i++ {
+		shares[i] = make([]byte, len(secret))
+		if _, err := io.ReadFull(random, shares[i]); err != nil {
+			return nil, fmt.Errorf("cannot generate SLIP-39 share, %w", err)
+		}
+
+		xs = append(xs, byte(i))
+		ys = append(ys, shares[i])
+	}
+
+	digestShare := make([]byte, len(secret))
+	defer Wipe(digestShare)
+
+	if _, err := io.ReadFull(random, digestShare[slip39DigestSize:]); err != nil {
+		return nil, fmt.Errorf("cannot generate SLIP-39 share, %w", err)
+	}
+
+	digest := slip39Digest(digestShare[slip39DigestSize:], secret)
+	copy(digestShare, digest)
+	Wipe(digest)
+
+	xs = append(xs, slip39DigestIndex, slip39SecretIndex)
+	ys = append(ys, digestShare, secret)
+
+	for i := threshold - 2; i < count; i++ {
+		share, err := slip39Interpolate(xs, ys, byte(i))
+		if err != nil {
+			return nil, err
+		}
+
+		shares[i] = share
+	}
+
+	return shares, nil
+}
+
+// slip39RecoverSecret recovers the secret split into the shares at xs, threshold of which are needed.
+func slip39RecoverSecret(threshold int, xs []byte, ys [][]byte) ([]byte, error) {
+	if len(xs) < threshold {
+		return nil, fmt.Errorf("insufficient SLIP-39 shares, %v out of %v", len(xs), threshold)
+	}
+
+	xs, ys = xs[:threshold], ys[:threshold]
+
+	if threshold == 1 {
+		return append([]byte{}, ys[0]...), nil
+	}
+
+	secret, err := slip39Interpolate(xs, ys, slip39SecretIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	digestShare, err := slip39Interpolate(xs, ys, slip39DigestIndex)
+	if err != nil {
+		Wipe(secret)
+		return nil, err
+	}
+
+	defer Wipe(digestShare)
+
+	digest := slip39Digest(digestShare[slip39DigestSize:], secret)
+	defer Wipe(digest)
+
+	if !hmac.Equal(digest, digestShare[:slip39DigestSize]) {
+		Wipe(secret)
+		return nil, fmt.Errorf("invalid SLIP-39 shares, digest mismatch")
+	}
+
+	return secret, nil
+}
+
+// slip39Feistel encrypts, or decrypts, secret with the 4 rounds Feistel network of SLIP-39,
+// whose round function is PBKDF2-HMAC-SHA256.
+func slip39Feistel(secret, passphrase []byte, iterationExponent int, identifier uint16, extendable bool, encrypt bool) []byte {
+	half := len(secret) / 2
+	l := append([]byte{}, secret[:half]...)
+	r := append([]byte{}, secret[half:]...)
+	defer Wipe(l, r)
+
+	salt := []byte{}
+	if !extendable {
+		salt = append([]byte(slip39Customization), 0, 0)
+		binary.BigEndian.PutUint16(salt[len(slip39Customization):], identifier)
+	}
+
+	key := make([]byte, 1+len(passphrase))
+	copy(key[1:], passphrase)
+	defer Wipe(key)
+
+	iterations := (slip39BaseIterations << iterationExponent) / slip39RoundCount
+
+	for round := 0; round < slip39RoundCount; round++ {
+		i := round
+		if !encrypt {
+			i = slip39RoundCount - 1 - round
+		}
+
+		key[0] = byte(i)
+
+		roundSalt := append(append(make([]byte, 0, len(salt)+len(r)), salt...), r...)
+		f := pbkdf2.Key(key, roundSalt, iterations, len(r), sha256.New)
+		Wipe(roundSalt)
+
+		for k := range l {
+			l[k] ^= f[k]
+		}
+
+		Wipe(f)
+		l, r = r, l
+	}
+
+	return append(append(make([]byte, 0, len(secret)), r...), l...)
+}
+
+// SLIP39Secret is a SLIP-39 encrypted master secret, with the parameters it has been encrypted with:
+// it's what SLIP-39 shares split, and it can be split again into shares of the same backup.
+type SLIP39Secret struct {
+	Identifier        uint16
+	Extendable        bool
+	IterationExponent int
+
+	// Encrypted is the encrypted master secret.
+	Encrypted []byte
+}
+
+// MasterSecret decrypts the master secret of s with passphrase, which is the BIP-32 seed of the wallet.
+// The caller owns the returned slice, and should wipe it once done.
+func (s SLIP39Secret) MasterSecret(passphrase []byte) ([]byte, error) {
+	if err := ValidPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	return slip39Feistel(s.Encrypted, passphrase, s.IterationExponent, s.Identifier, s.Extendable, false), nil
+}
+
+// SLIP39Split splits secret into SLIP-39 mnemonic shares, encrypted with passphrase: groupThreshold
+// of the groups are needed to recover secret, each of them recovered by Threshold of its Count member shares.
+// Shares are returned per group, and their words are references to the wordlist.
+// The shares are extendable, as recommended for new backups.
+func SLIP39Split(random io.Reader, secret, passphrase []byte, groupThreshold int, groups []SLIP39Group) ([][][]string, error) {
+	if len(secret) < slip39MinSecretSize || len(secret)%2 != 0 {
+		return nil, fmt.Errorf("SLIP-39 secrets must be an even amount of bytes, at least %v", slip39MinSecretSize)
+	}
+
+	if err := ValidPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	rawID := make([]byte, 2)
+	if _, err := io.ReadFull(random, rawID); err != nil {
+		return nil, fmt.Errorf("cannot generate SLIP-39 identifier, %w", err)
+	}
+
+	identifier := binary.BigEndian.Uint16(rawID) & slip39IDMask
+
+	encrypted := slip39Feistel(secret, passphrase, slip39IterationExponent, identifier, true, true)
+	defer Wipe(encrypted)
+
+	return SLIP39SplitEncrypted(random, SLIP39Secret{
+		Identifier:        identifier,
+		Extendable:        true,
+		IterationExponent: slip39IterationExponent,
+		Encrypted:         encrypted,
+	}, groupThreshold, groups)
+}
+
+// SLIP39SplitEncrypted splits the encrypted master secret into SLIP-39 mnemonic shares, like SLIP39Split does.
+// Shares keep the identifier of secret, so that they belong to the same backup as the shares it was
+// recovered from.
+func SLIP39SplitEncrypted(random io.Reader, secret SLIP39Secret, groupThreshold int, groups []SLIP39Group) ([][][]string, error) {
+	if len(secret.Encrypted) < slip39MinSecretSize || len(secret.Encrypted)%2 != 0 {
+		return nil, fmt.Errorf("SLIP-39 secrets must be an even amount of bytes, at least %v", slip39MinSecretSize)
+	}
+
+	if secret.Identifier > slip39IDMask || secret.IterationExponent < 0 || secret.IterationExponent > 15 {
+		return nil, fmt.Errorf("invalid SLIP-39 encryption parameters")
+	}
+
+	if len(groups) == 0 || len(groups) > slip39MaxShares {
+		return nil, fmt.Errorf("SLIP-39 backups have between 1 and %v groups", slip39MaxShares)
+	}
+
+	if groupThreshold < 1 || groupThreshold > len(groups) {
+		return nil, fmt.Errorf("invalid SLIP-39 group threshold %v of %v groups", groupThreshold, len(groups))
+	}
+
+	for i, g := range groups {
+		if g.Threshold < 1 || g.Threshold > g.Count || g.Count > slip39MaxShares {
+			return nil, fmt.Errorf("invalid SLIP-39 threshold %v of %v shares for group %v", g.Threshold, g.Count, i+1)
+		}
+
+		if g.Threshold == 1 && g.Count > 1 {
+			return nil, fmt.Errorf("group %v would have several copies of the same share, use a 1 of 1 group instead", i+1)
+		}
+	}
+
+	groupShares, err := slip39SplitSecret(random, groupThreshold, len(groups), secret.Encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(groupShares...)
+
+	ret := make([][][]string, len(groups))
+	for gi, g := range groups {
+		memberShares, err := slip39SplitSecret(random, g.Threshold, g.Count, groupShares[gi])
+		if err != nil {
+			return nil, err
+		}
+
+		for mi, value := range memberShares {
+			indexes := slip39Share{
+				identifier:        secret.Identifier,
+				extendable:        secret.Extendable,
+				iterationExponent: secret.IterationExponent,
+				groupIndex:        gi,
+				groupThreshold:    groupThreshold,
+				groupCount:        len(groups),
+				memberIndex:       mi,
+				memberThreshold:   g.Threshold,
+				value:             value,
+			}.encode()
+
+			words, err := SLIP39Words(indexes)
+			WipeIndexes(indexes)
+
+			if err != nil {
+				Wipe(memberShares...)
+				return nil, err
+			}
+
+			ret[gi] = append(ret[gi], words)
+		}
+
+		Wipe(memberShares...)
+	}
+
+	return ret, nil
+}
+
+// SLIP39Combine recovers the secret split into the SLIP-39 mnemonic shares, decrypting it with passphrase.
+// Shares can be given in any order, as long as enough of them are given for enough groups.
+// The caller owns the returned slice, and should wipe it once done.
+func SLIP39Combine(mnemonics [][]string, passphrase []byte) ([]byte, error) {
+	secret, err := SLIP39CombineEncrypted(mnemonics)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Wipe(secret.Encrypted)
+
+	return secret.MasterSecret(passphrase)
+}
+
+// SLIP39CombineEncrypted recovers the encrypted master secret split into the SLIP-39 mnemonic shares,
+// like SLIP39Combine does, leaving it encrypted.
+// The caller owns the returned Encrypted slice, and should wipe it once done.
+func SLIP39CombineEncrypted(mnemonics [][]string) (SLIP39Secret, error) {
+	if len(mnemonics) == 0 {
+		return SLIP39Secret{}, fmt.Errorf("no SLIP-39 shares")
+	}
+
+	shares := make([]slip39Share, 0, len(mnemonics))
+	defer func() {
+		for _, s := range shares {
+			Wipe(s.value)
+		}
+	}()
+
+	for i, words := range mnemonics {
+		indexes, err := SLIP39IndexesOf(words)
+		if err != nil {
+			return SLIP39Secret{}, fmt.Errorf("share %v: %w", i+1, err)
+		}
+
+		s, err := decodeSLIP39Share(indexes)
+		WipeIndexes(indexes)
+
+		if err != nil {
+			return SLIP39Secret{}, fmt.Errorf("share %v: %w", i+1, err)
+		}
+
+		shares = append(shares, s)
+	}
+
+	first := shares[0]
+	for i, s := range shares {
+		if s.identifier != first.identifier || s.extendable != first.extendable ||
+			s.iterationExponent != first.iterationExponent || s.groupThreshold != first.groupThreshold ||
+			s.groupCount != first.groupCount || len(s.value) != len(first.value) {
+			return SLIP39Secret{}, fmt.Errorf("share %v doesn't belong to the same backup as share 1", i+1)
+		}
+	}
+
+	// member shares, by group index
+	groups := map[int][]slip39Share{}
+	for i, s := range shares {
+		for _, m := range groups[s.groupIndex] {
+			if m.memberThreshold != s.memberThreshold {
+				return SLIP39Secret{}, fmt.Errorf("share %v has a different member threshold than the rest of its group", i+1)
+			}
+
+			if m.memberIndex == s.memberIndex {
+				return SLIP39Secret{}, fmt.Errorf("share %v has been given twice", i+1)
+			}
+		}
+
+		groups[s.groupIndex] = append(groups[s.groupIndex], s)
+	}
+
+	xs := []byte{}
+	ys := [][]byte{}
+	defer func() { Wipe(ys...) }()
+
+	for gi := 0; gi < first.groupCount && len(xs) < first.groupThreshold; gi++ {
+		members := groups[gi]
+		if len(members) == 0 || len(members) < members[0].memberThreshold {
+			continue
+		}
+
+		mxs := []byte{}
+		mys := [][]byte{}
+		for _, m := range members {
+			mxs = append(mxs, byte(m.memberIndex))
+			mys = append(mys, m.value)
+		}
+
+		groupShare, err := slip39RecoverSecret(members[0].memberThreshold, mxs, mys)
+		if err != nil {
+			return SLIP39Secret{}, fmt.Errorf("group %v: %w", gi+1, err)
+		}
+
+		xs = append(xs, byte(gi))
+		ys = append(ys, groupShare)
+	}
+
+	if len(xs) < first.groupThreshold {
+		return SLIP39Secret{}, fmt.Errorf("insufficient SLIP-39 shares, %v complete groups out of %v", len(xs), first.groupThreshold)
+	}
+
+	encrypted, err := slip39RecoverSecret(first.groupThreshold, xs, ys)
+	if err != nil {
+		return SLIP39Secret{}, err
+	}
+
+	return SLIP39Secret{
+		Identifier:        first.identifier,
+		Extendable:        first.extendable,
+		IterationExponent: first.iterationExponent,
+		Encrypted:         encrypted,
+	}, nil
+}
@@ -0,0 +1,271 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func TestSLIP39WordList(t *testing.T) {
+	require.Len(t, slip39WordList, slip39Radix)
+
+	prefixes := map[string]bool{}
+	for i, w := range slip39WordList {
+		require.True(t, len(w) >= 4 && len(w) <= slip39MaxWordLen, w)
+		require.False(t, prefixes[w[:4]], w)
+		prefixes[w[:4]] = true
+
+		if i > 0 {
+			require.Less(t, slip39WordList[i-1], w)
+		}
+	}
+}
+
+func TestSLIP39Combine(t *testing.T) {
+	// SLIP-39 test vectors
+	tests := []struct {
+		name    string
+		shares  []string
+		secret  string
+		wantErr bool
+	}{
+		{
+			"128 bits without sharing",
+			[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard"},
+			"bb54aac4b89dc868ba37d9cc21b2cece",
+			false,
+		},
+		{
+			"128 bits with an invalid checksum",
+			[]string{"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision kidney"},
+			"",
+			true,
+		},
+		{
+			"128 bits, 2 of 3 shares",
+			[]string{
+				"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+				"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+			},
+			"b43ceb7e57a0ea8766221624d01b0864",
+			false,
+		},
+		{
+			"128 bits, 1 of 2 needed shares",
+			[]string{"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed"},
+			"",
+			true,
+		},
+		{
+			"256 bits without sharing",
+			[]string{"theory painting academic academic armed sweater year military elder discuss acne wildlife boring employer fused large satoshi bundle carbon diagnose anatomy hamster leaves tracks paces beyond phantom capital marvel lips brave detect luck"},
+			"989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mnemonics := [][]string{}
+			for _, s := range tt.shares {
+				mnemonics = append(mnemonics, strings.Fields(s))
+			}
+
+			secret, err := SLIP39Combine(mnemonics, []byte("TREZOR"))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.secret, hex.EncodeToString(secret))
+		})
+	}
+}
+
+func TestSLIP39Split(t *testing.T) {
+	secret := standardEntropy
+	passphrase := []byte("TREZOR")
+
+	// 2 of 3 groups: a single share, 2 of 3 shares, 3 of 5 shares
+	groups := []SLIP39Group{{1, 1}, {2, 3}, {3, 5}}
+
+	shares, err := SLIP39Split(rand.Reader, secret, passphrase, 2, groups)
+	require.NoError(t, err)
+	require.Len(t, shares, len(groups))
+
+	for gi, g := range groups {
+		require.Len(t, shares[gi], g.Count)
+		for _, words := range shares[gi] {
+			require.Len(t, words, 33)
+			require.True(t, ValidSLIP39Length(len(words)))
+		}
+	}
+
+	recovered, err := SLIP39Combine([][]string{shares[0][0], shares[2][4], shares[2][1], shares[2][0]}, passphrase)
+	require.NoError(t, err)
+	require.Equal(t, secret, recovered)
+
+	recovered, err = SLIP39Combine([][]string{shares[1][2], shares[2][3], shares[1][0], shares[2][1], shares[2][2]}, passphrase)
+	require.NoError(t, err)
+	require.Equal(t, secret, recovered)
+
+	// a single complete group
+	_, err = SLIP39Combine([][]string{shares[1][0], shares[1][1], shares[2][0]}, passphrase)
+	require.Error(t, err)
+
+	// the same share twice
+	_, err = SLIP39Combine([][]string{shares[0][0], shares[1][0], shares[1][0]}, passphrase)
+	require.Error(t, err)
+
+	// another passphrase decrypts another secret
+	recovered, err = SLIP39Combine([][]string{shares[0][0], shares[1][0], shares[1][1]}, nil)
+	require.NoError(t, err)
+	require.NotEqual(t, secret, recovered)
+
+	// shares of another backup
+	other, err := SLIP39Split(rand.Reader, secret, passphrase, 2, groups)
+	require.NoError(t, err)
+
+	_, err = SLIP39Combine([][]string{shares[0][0], other[1][0], other[1][1]}, passphrase)
+	require.Error(t, err)
+}
+
+func TestSLIP39SplitRejectsInvalidGroups(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         []byte
+		groupThreshold int
+		groups         []SLIP39Group
+	}{
+		{"short secret", make([]byte, 14), 1, []SLIP39Group{{1, 1}}},
+		{"odd secret", make([]byte, 17), 1, []SLIP39Group{{1, 1}}},
+		{"no groups", make([]byte, 16), 1, nil},
+		{"group threshold too high", make([]byte, 16), 2, []SLIP39Group{{1, 1}}},
+		{"member threshold too high", make([]byte, 16), 1, []SLIP39Group{{3, 2}}},
+		{"too many members", make([]byte, 16), 1, []SLIP39Group{{2, 17}}},
+		{"copies of the same share", make([]byte, 16), 1, []SLIP39Group{{1, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SLIP39Split(rand.Reader, tt.secret, nil, tt.groupThreshold, tt.groups)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSLIP39Word(t *testing.T) {
+	word, found := SLIP39Word([]byte(" Academic\n"))
+	require.True(t, found)
+	require.Equal(t, "academic", word)
+
+	for _, w := range []string{"", "acad", "abandon", "academics"} {
+		_, found := SLIP39Word([]byte(w))
+		require.False(t, found, w)
+	}
+}
+
+func Test_dumbToken_SLIP39(t *testing.T) {
+	// SLIP-39 test vector, whose master secret is the BIP-32 seed of the wallet
+	duckling := strings.Fields("duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard")
+	masterSecret, err := hex.DecodeString("bb54aac4b89dc868ba37d9cc21b2cece")
+	require.NoError(t, err)
+
+	expected, err := Fingerprint(masterSecret)
+	require.NoError(t, err)
+
+	dt := NewDumbToken(storage.NewMemory())
+	require.NoError(t, dt.ImportSLIP39Shares([][]string{duckling}))
+	require.NoError(t, dt.SetPassphrase([]byte("TREZOR")))
+
+	fp, err := dt.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+
+	// SLIP-39 seeds have no BIP-39 mnemonic
+	_, err = dt.Mnemonic()
+	require.Error(t, err)
+
+	shares, err := dt.SLIP39Shares(1, []SLIP39Group{{2, 3}})
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	require.Len(t, shares[0], 3)
+
+	// new shares split the same encrypted master secret
+	recovered, err := SLIP39Combine([][]string{shares[0][1], shares[0][0]}, []byte("TREZOR"))
+	require.NoError(t, err)
+	require.Equal(t, masterSecret, recovered)
+
+	restored := NewDumbToken(storage.NewMemory())
+	require.Error(t, restored.ImportSLIP39Shares([][]string{shares[0][1]}))
+
+	require.NoError(t, restored.ImportSLIP39Shares([][]string{shares[0][2], shares[0][0]}))
+	require.NoError(t, restored.SetPassphrase([]byte("TREZOR")))
+
+	fp, err = restored.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+
+	// the passphrase decrypts the master secret
+	require.NoError(t, restored.SetPassphrase(nil))
+
+	fp, err = restored.Fingerprint()
+	require.NoError(t, err)
+	require.NotEqual(t, expected, fp)
+
+	// SLIP-39 backups can hold more than seeds, but only seeds can be imported
+	other, err := SLIP39Split(rand.Reader, make([]byte, 20), nil, 1, []SLIP39Group{{1, 1}})
+	require.NoError(t, err)
+	require.False(t, ValidSLIP39Length(len(other[0][0])))
+	require.Error(t, restored.ImportSLIP39Shares(other[0]))
+
+	// BIP-39 seeds replace SLIP-39 ones
+	require.NoError(t, restored.ImportSeed(standardMnemonic))
+
+	m, err := restored.Mnemonic()
+	require.NoError(t, err)
+	require.Equal(t, standardMnemonic, m)
+}
+
+func Test_dumbToken_SLIP39OfBIP39Seeds(t *testing.T) {
+	// shares of the BIP-39 entropy would restore another wallet on other SLIP-39 devices
+	dt := seededToken(t)
+
+	_, err := dt.SLIP39Shares(1, []SLIP39Group{{1, 1}})
+	require.Error(t, err)
+}
+
+func Test_dumbToken_SLIP39Of192BitsSecrets(t *testing.T) {
+	secret := make([]byte, 24)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	expected, err := Fingerprint(secret)
+	require.NoError(t, err)
+
+	backup, err := SLIP39Split(rand.Reader, secret, nil, 1, []SLIP39Group{{1, 1}})
+	require.NoError(t, err)
+
+	dt := NewDumbToken(storage.NewMemory())
+	require.NoError(t, dt.ImportSLIP39Shares(backup[0]))
+
+	shares, err := dt.SLIP39Shares(1, []SLIP39Group{{2, 3}})
+	require.NoError(t, err)
+
+	for _, share := range shares[0] {
+		require.Len(t, share, 27)
+		require.True(t, ValidSLIP39Length(len(share)))
+	}
+
+	restored := NewDumbToken(storage.NewMemory())
+	require.NoError(t, restored.ImportSLIP39Shares([][]string{shares[0][0], shares[0][2]}))
+
+	fp, err := restored.Fingerprint()
+	require.NoError(t, err)
+	require.Equal(t, expected, fp)
+}
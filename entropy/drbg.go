@@ -0,0 +1,186 @@
+package entropy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// SecurityStrength is the security strength of DRBG, in bytes.
+	SecurityStrength = 32
+
+	// MaxRequestSize is the maximum amount of bytes a single DRBG.Generate call returns.
+	MaxRequestSize = 1 << 16
+
+	// reseedInterval is the maximum amount of DRBG.Generate calls between reseeds, well below the
+	// 2^48 SP 800-90A allows.
+	reseedInterval = 1 << 20
+)
+
+// ErrReseedRequired is returned by DRBG.Generate when the DRBG must be reseeded before generating again.
+var ErrReseedRequired = errors.New("DRBG must be reseeded")
+
+// DRBG is the SP 800-90A HMAC_DRBG, instantiated with HMAC-SHA256.
+// It isn't safe for concurrent use.
+type DRBG struct {
+	k             []byte
+	v             []byte
+	reseedCounter uint64
+}
+
+// NewDRBG instantiates a DRBG out of entropyInput, which must hold at least SecurityStrength bytes of
+// entropy, nonce and the optional personalization string.
+func NewDRBG(entropyInput, nonce, personalization []byte) (*DRBG, error) {
+	if len(entropyInput) < SecurityStrength {
+		return nil, fmt.Errorf("entropy input must be at least %v bytes long", SecurityStrength)
+	}
+
+	d := &DRBG{
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+
+	for i := range d.v {
+		d.v[i] = 0x01
+	}
+
+	d.update(entropyInput, nonce, personalization)
+	d.reseedCounter = 1
+
+	return d, nil
+}
+
+// update is the HMAC_DRBG update function, provided being the concatenation of the slices.
+func (d *DRBG) update(provided ...[]byte) {
+	d.updateRound(0x00, provided)
+
+	empty := true
+	for _, p := range provided {
+		if len(p) > 0 {
+			empty = false
+		}
+	}
+
+	if empty {
+		return
+	}
+
+	d.updateRound(0x01, provided)
+}
+
+func (d *DRBG) updateRound(separator byte, provided [][]byte) {
+	mac := hmac.New(sha256.New, d.k)
+	mac.Write(d.v)
+	mac.Write([]byte{separator})
+	for _, p := range provided {
+		mac.Write(p)
+	}
+
+	wipe(d.k)
+	d.k = mac.Sum(d.k[:0])
+
+	d.refreshV()
+}
+
+// refreshV sets V to HMAC(K, V).
+func (d *DRBG) refreshV() {
+	mac := hmac.New(sha256.New, d.k)
+	mac.Write(d.v)
+	d.v = mac.Sum(d.v[:0])
+}
+
+// Reseed mixes entropyInput, which must hold at least SecurityStrength bytes of entropy, and the
+// optional additional input into the state of d.
+func (d *DRBG) Reseed(entropyInput, additional []byte) error {
+	if len(entropyInput) < SecurityStrength {
+		return fmt.Errorf("entropy input must be at least %v bytes long", SecurityStrength)
+	}
+
+	d.update(entropyInput, additional)
+	d.reseedCounter = 1
+
+	return nil
+}
+
+// Generate fills out with up to MaxRequestSize pseudorandom bytes, mixing the optional additional
+// input into the state of d.
+func (d *DRBG) Generate(out, additional []byte) error {
+	if len(out) > MaxRequestSize {
+		return fmt.Errorf("cannot generate more than %v bytes at once", MaxRequestSize)
+	}
+
+	if d.reseedCounter > reseedInterval {
+		return ErrReseedRequired
+	}
+
+	if len(additional) > 0 {
+		d.update(additional)
+	}
+
+	for filled := 0; filled < len(out); {
+		d.refreshV()
+		filled += copy(out[filled:], d.v)
+	}
+
+	d.update(additional)
+	d.reseedCounter++
+
+	return nil
+}
+
+// Wipe zeroes the state of d, which can't be used anymore.
+func (d *DRBG) Wipe() {
+	wipe(d.k)
+	wipe(d.v)
+	d.reseedCounter = reseedInterval + 1
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// SelfTest runs a known-answer test of DRBG: instantiated with the secp256k1 private key 1 and
+// the SHA-256 digest of "Satoshi Nakamoto", it generates their well known RFC 6979 nonce first.
+func SelfTest() error {
+	entropyInput := make([]byte, SecurityStrength)
+	entropyInput[SecurityStrength-1] = 1
+	nonce := sha256.Sum256([]byte("Satoshi Nakamoto"))
+
+	d, err := NewDRBG(entropyInput, nonce[:], nil)
+	if err != nil {
+		return err
+	}
+
+	first := make([]byte, 32)
+	if err := d.Generate(first, nil); err != nil {
+		return err
+	}
+
+	if err := d.Reseed(make([]byte, SecurityStrength), []byte("additional")); err != nil {
+		return err
+	}
+
+	second := make([]byte, 64)
+	if err := d.Generate(second, []byte("more")); err != nil {
+		return err
+	}
+
+	for _, kat := range []struct {
+		got      []byte
+		expected string
+	}{
+		{first, "8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15"},
+		{second, "6239f69a63f21f7480683ff44f53ee2695dbb7d57f539c5c8f01b097aaaf4d04e64f78e2755855f2cea402a09ec46d1ccd13127c60804077411d19c2e41c771b"},
+	} {
+		if hex.EncodeToString(kat.got) != kat.expected {
+			return fmt.Errorf("got %x, expected %v", kat.got, kat.expected)
+		}
+	}
+
+	return nil
+}
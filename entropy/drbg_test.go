@@ -0,0 +1,52 @@
+package entropy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	require.NoError(t, SelfTest())
+}
+
+func TestNewDRBGRejectsShortEntropyInput(t *testing.T) {
+	_, err := NewDRBG(make([]byte, SecurityStrength-1), nil, nil)
+	require.Error(t, err)
+
+	d, err := NewDRBG(make([]byte, SecurityStrength), nil, nil)
+	require.NoError(t, err)
+	require.Error(t, d.Reseed(make([]byte, SecurityStrength-1), nil))
+}
+
+func TestDRBGPersonalization(t *testing.T) {
+	a, err := NewDRBG(make([]byte, SecurityStrength), nil, nil)
+	require.NoError(t, err)
+
+	b, err := NewDRBG(make([]byte, SecurityStrength), nil, []byte("wallera"))
+	require.NoError(t, err)
+
+	outA := make([]byte, 32)
+	outB := make([]byte, 32)
+	require.NoError(t, a.Generate(outA, nil))
+	require.NoError(t, b.Generate(outB, nil))
+	require.NotEqual(t, outA, outB)
+}
+
+func TestDRBGGenerateLimits(t *testing.T) {
+	d, err := NewDRBG(make([]byte, SecurityStrength), nil, nil)
+	require.NoError(t, err)
+
+	require.Error(t, d.Generate(make([]byte, MaxRequestSize+1), nil))
+	require.NoError(t, d.Generate(make([]byte, MaxRequestSize), nil))
+
+	d.reseedCounter = reseedInterval + 1
+	require.ErrorIs(t, d.Generate(make([]byte, 32), nil), ErrReseedRequired)
+
+	require.NoError(t, d.Reseed(make([]byte, SecurityStrength), nil))
+	require.NoError(t, d.Generate(make([]byte, 32), nil))
+
+	d.Wipe()
+	require.Equal(t, make([]byte, 32), d.k)
+	require.ErrorIs(t, d.Generate(make([]byte, 32), nil), ErrReseedRequired)
+}
@@ -0,0 +1,109 @@
+// Package entropy provides the random number generator of the device: an SP 800-90A HMAC_DRBG with
+// prediction resistance, seeded by a noise source under SP 800-90B health tests.
+package entropy
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+)
+
+// Reader is the Service of the device, fed by crypto/rand.Reader: the TRNG on the board, or the
+// SYS_GETRANDOM system call in the TEE applet.
+var Reader = NewService(rand.Reader)
+
+// Read fills b with random bytes out of Reader.
+func Read(b []byte) (int, error) {
+	return Reader.Read(b)
+}
+
+// Service generates random bytes with a DRBG, reseeded out of a health-tested Source before every read,
+// so that each read is as unpredictable as the noise source, even if the DRBG state leaked.
+// Once an error occurs, be it a tripped health test or a failing noise source, the Service fails
+// closed: its state is wiped, and every read returns the error.
+// It is safe for concurrent use.
+type Service struct {
+	mu     sync.Mutex
+	source *Source
+	drbg   *DRBG
+	err    error
+}
+
+// NewService returns a Service fed by the noise source r.
+// Its DRBG is instantiated on the first read.
+func NewService(r io.Reader) *Service {
+	return &Service{
+		source: NewSource(r),
+	}
+}
+
+// Read fills p with random bytes.
+func (s *Service) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	if err := s.read(p); err != nil {
+		wipe(p)
+
+		if s.drbg != nil {
+			s.drbg.Wipe()
+		}
+
+		s.err = err
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *Service) read(p []byte) error {
+	entropyInput := make([]byte, SecurityStrength)
+	defer wipe(entropyInput)
+
+	if s.drbg == nil {
+		if _, err := s.source.Read(entropyInput); err != nil {
+			return err
+		}
+
+		nonce := make([]byte, SecurityStrength/2)
+		defer wipe(nonce)
+
+		if _, err := s.source.Read(nonce); err != nil {
+			return err
+		}
+
+		drbg, err := NewDRBG(entropyInput, nonce, nil)
+		if err != nil {
+			return err
+		}
+
+		s.drbg = drbg
+	}
+
+	for len(p) > 0 {
+		if _, err := s.source.Read(entropyInput); err != nil {
+			return err
+		}
+
+		if err := s.drbg.Reseed(entropyInput, nil); err != nil {
+			return err
+		}
+
+		n := len(p)
+		if n > MaxRequestSize {
+			n = MaxRequestSize
+		}
+
+		if err := s.drbg.Generate(p[:n], nil); err != nil {
+			return err
+		}
+
+		p = p[n:]
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package entropy
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceRead(t *testing.T) {
+	s := NewService(rand.Reader)
+
+	a := make([]byte, 32)
+	b := make([]byte, 32)
+
+	n, err := s.Read(a)
+	require.NoError(t, err)
+	require.Equal(t, len(a), n)
+
+	_, err = s.Read(b)
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+	require.NotEqual(t, make([]byte, 32), a)
+
+	// reads larger than MaxRequestSize are split
+	large := make([]byte, 2*MaxRequestSize+1)
+	n, err = s.Read(large)
+	require.NoError(t, err)
+	require.Equal(t, len(large), n)
+	require.NotEqual(t, large[:MaxRequestSize], large[MaxRequestSize:2*MaxRequestSize])
+}
+
+func TestServiceFailsClosed(t *testing.T) {
+	r := &patternReader{pattern: counterPattern(0)}
+	s := NewService(r)
+
+	_, err := s.Read(make([]byte, 32))
+	require.NoError(t, err)
+
+	// the noise source gets stuck
+	r.pattern = []byte{0}
+
+	p := make([]byte, 32)
+	_, err = s.Read(p)
+	require.ErrorIs(t, err, ErrHealthTest)
+	require.Equal(t, make([]byte, 32), p)
+	require.Equal(t, make([]byte, 32), s.drbg.k)
+
+	r.pattern = counterPattern(0)
+	_, err = s.Read(p)
+	require.ErrorIs(t, err, ErrHealthTest)
+}
+
+func TestRead(t *testing.T) {
+	p := make([]byte, 64)
+	n, err := Read(p)
+	require.NoError(t, err)
+	require.Equal(t, len(p), n)
+}
@@ -0,0 +1,123 @@
+package entropy
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// the health tests assume the noise source has at least 1 bit of min-entropy per byte, and trip
+	// with a false positive probability of 2^-20 per sample, following SP 800-90B 4.4.
+
+	// repetitionCutoff is the amount of identical consecutive samples the repetition count test
+	// trips at: 1 + ceil(20 / 1).
+	repetitionCutoff = 21
+
+	// proportionWindow is the amount of samples of each adaptive proportion test window.
+	proportionWindow = 512
+
+	// proportionCutoff is the amount of samples identical to the first of a window the adaptive
+	// proportion test trips at.
+	proportionCutoff = 311
+
+	// startupSamples is the amount of samples tested and discarded before a Source is used.
+	startupSamples = 1024
+)
+
+// ErrHealthTest is returned by Sources whose noise source failed a health test.
+var ErrHealthTest = errors.New("entropy source failed its health tests")
+
+// Source runs the SP 800-90B repetition count and adaptive proportion tests on every byte read out
+// of a noise source, like the TRNG of the board.
+// Once a test trips, the Source fails closed: every read returns ErrHealthTest.
+// It isn't safe for concurrent use.
+type Source struct {
+	r   io.Reader
+	err error
+
+	started bool
+
+	repeated byte
+	rcCount  int
+
+	proportioned byte
+	apCount      int
+	apSeen       int
+}
+
+// NewSource returns a Source reading samples out of r.
+func NewSource(r io.Reader) *Source {
+	return &Source{
+		r: r,
+	}
+}
+
+// Read fills p with samples which passed the health tests, running the startup tests on the first read.
+func (s *Source) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	if !s.started {
+		startup := make([]byte, startupSamples)
+		err := s.read(startup)
+		wipe(startup)
+
+		if err != nil {
+			return 0, err
+		}
+
+		s.started = true
+	}
+
+	if err := s.read(p); err != nil {
+		wipe(p)
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *Source) read(p []byte) error {
+	if _, err := io.ReadFull(s.r, p); err != nil {
+		s.err = err
+		return err
+	}
+
+	for _, sample := range p {
+		if !s.test(sample) {
+			s.err = ErrHealthTest
+			return s.err
+		}
+	}
+
+	return nil
+}
+
+// test runs both health tests on sample, returning false if one of them tripped.
+func (s *Source) test(sample byte) bool {
+	if s.rcCount > 0 && sample == s.repeated {
+		s.rcCount++
+	} else {
+		s.repeated = sample
+		s.rcCount = 1
+	}
+
+	if s.rcCount >= repetitionCutoff {
+		return false
+	}
+
+	if s.apSeen == 0 {
+		s.proportioned = sample
+		s.apCount = 1
+	} else if sample == s.proportioned {
+		s.apCount++
+	}
+
+	s.apSeen++
+	if s.apSeen == proportionWindow {
+		s.apSeen = 0
+	}
+
+	return s.apCount < proportionCutoff
+}
@@ -0,0 +1,128 @@
+package entropy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// patternReader endlessly repeats pattern.
+type patternReader struct {
+	pattern []byte
+	off     int
+}
+
+func (pr *patternReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = pr.pattern[pr.off%len(pr.pattern)]
+		pr.off++
+	}
+
+	return len(p), nil
+}
+
+// counterPattern returns a pattern made of every byte value but 0, each preceded by prefix.
+func counterPattern(prefix ...byte) []byte {
+	ret := []byte{}
+	for i := 1; i < 256; i++ {
+		ret = append(ret, prefix...)
+		ret = append(ret, byte(i))
+	}
+
+	return ret
+}
+
+// runsPattern returns a pattern made of runs of length identical samples, one per byte value.
+func runsPattern(length int) []byte {
+	ret := []byte{}
+	for i := 0; i < 256; i++ {
+		ret = append(ret, bytes.Repeat([]byte{byte(i)}, length)...)
+	}
+
+	return ret
+}
+
+func TestSource(t *testing.T) {
+	s := NewSource(rand.Reader)
+
+	for i := 0; i < 100; i++ {
+		p := make([]byte, 1024)
+		n, err := s.Read(p)
+		require.NoError(t, err)
+		require.Equal(t, len(p), n)
+	}
+}
+
+func TestSourceRepetitionCount(t *testing.T) {
+	// repetitionCutoff-1 identical samples in a row are fine
+	s := NewSource(&patternReader{pattern: runsPattern(repetitionCutoff - 1)})
+	_, err := s.Read(make([]byte, 4096))
+	require.NoError(t, err)
+
+	s = NewSource(&patternReader{pattern: runsPattern(repetitionCutoff)})
+	_, err = s.Read(make([]byte, 4096))
+	require.ErrorIs(t, err, ErrHealthTest)
+}
+
+func TestSourceAdaptiveProportion(t *testing.T) {
+	// half of the samples are zeroes
+	s := NewSource(&patternReader{pattern: counterPattern(0)})
+	_, err := s.Read(make([]byte, 4096))
+	require.NoError(t, err)
+
+	// two thirds of the samples are zeroes, never repeated more than twice in a row
+	s = NewSource(&patternReader{pattern: counterPattern(0, 0)})
+	_, err = s.Read(make([]byte, 4096))
+	require.ErrorIs(t, err, ErrHealthTest)
+}
+
+func TestSourceRunsStartupTests(t *testing.T) {
+	// the noise source fails during the startup tests, no samples are ever returned
+	pattern := counterPattern()
+	pattern = append(pattern, bytes.Repeat([]byte{0}, repetitionCutoff)...)
+
+	s := NewSource(&patternReader{pattern: pattern})
+	p := make([]byte, 1)
+	_, err := s.Read(p)
+	require.ErrorIs(t, err, ErrHealthTest)
+}
+
+func TestSourceFailsClosed(t *testing.T) {
+	r := &patternReader{pattern: []byte{0x42}}
+	s := NewSource(r)
+
+	p := make([]byte, 32)
+	_, err := s.Read(p)
+	require.ErrorIs(t, err, ErrHealthTest)
+	require.Equal(t, make([]byte, 32), p)
+
+	// the noise source recovering doesn't make the Source usable again
+	s.r = rand.Reader
+	_, err = s.Read(p)
+	require.ErrorIs(t, err, ErrHealthTest)
+}
+
+type failingReader struct{}
+
+var errNoiseSource = errors.New("noise source failure")
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errNoiseSource
+}
+
+func TestSourceReaderErrors(t *testing.T) {
+	s := NewSource(io.MultiReader(&io.LimitedReader{R: rand.Reader, N: 2000}, failingReader{}))
+
+	_, err := s.Read(make([]byte, 512))
+	require.NoError(t, err)
+
+	_, err = s.Read(make([]byte, 512))
+	require.ErrorIs(t, err, errNoiseSource)
+
+	_, err = s.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errNoiseSource)
+}
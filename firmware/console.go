@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/f-secure-foundry/tamago/soc/imx6"
+)
+
+// consoleInput serializes reads of the debug accessory serial console, so that confirmations
+// aren't answered by anything else reading it.
+var consoleInput sync.Mutex
+
+// clearConsole clears the terminal attached to the console, along with its scrollback.
+const clearConsole = "\x1b[2J\x1b[3J\x1b[H"
+
+// consoleConfirm asks the user sitting at the debug accessory serial console to approve an operation.
+// The board has neither a screen nor a button, so the console is the only way to reach the user
+// which doesn't go through the host.
+func consoleConfirm(prompt string) (bool, error) {
+	consoleInput.Lock()
+	defer consoleInput.Unlock()
+
+	imx6.UART2.Write([]byte(fmt.Sprintf("\r\n%s [y/N] ", prompt)))
+
+	var answer []byte
+	for {
+		c, valid := imx6.UART2.Rx()
+		if !valid {
+			runtime.Gosched()
+			continue
+		}
+
+		if c == '\r' || c == '\n' {
+			break
+		}
+
+		imx6.UART2.Tx(c)
+		answer = append(answer, c)
+	}
+
+	imx6.UART2.Write([]byte("\r\n"))
+
+	return bytes.EqualFold(bytes.TrimSpace(answer), []byte("y")), nil
+}
+
+// consoleConfirmSecret shows a secret on the debug accessory serial console, numbering the words of
+// mnemonics, and asks the user to confirm it has been backed up.
+// The terminal is cleared once the user answered, so that the secret doesn't linger in its scrollback.
+func consoleConfirmSecret(prompt string, secret []byte) (bool, error) {
+	imx6.UART2.Write([]byte("\r\n"))
+
+	words := bytes.Fields(secret)
+	for i, w := range words {
+		if len(words) > 1 {
+			imx6.UART2.Write([]byte(fmt.Sprintf("%2d. ", i+1)))
+		}
+
+		imx6.UART2.Write(w)
+		imx6.UART2.Write([]byte("\r\n"))
+	}
+
+	defer imx6.UART2.Write([]byte(clearConsole))
+
+	return consoleConfirm(prompt)
+}
@@ -7,7 +7,14 @@ import (
 
 	"github.com/f-secure-foundry/tamago/soc/imx6"
 	"github.com/wallera-computer/wallera/apps"
+	"github.com/wallera-computer/wallera/apps/age"
 	"github.com/wallera-computer/wallera/apps/cosmos"
+	"github.com/wallera-computer/wallera/apps/device"
+	"github.com/wallera-computer/wallera/apps/nostr"
+	"github.com/wallera-computer/wallera/apps/oath"
+	"github.com/wallera-computer/wallera/apps/openpgp"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/pin"
 	"go.uber.org/zap"
 )
 
@@ -40,12 +47,33 @@ func main() {
 
 	l := logger()
 
-	t := tokenImpl()
+	s, err := storageImpl()
+	notErr(err, l)
 
-	ah := apps.NewHandler()
-	ah.Register(&cosmos.Cosmos{
-		Token: t,
-	})
+	t := tokenImpl(s)
+
+	// refuse to run on faulty hardware, before hosts can talk to the device
+	notErr(crypto.SelfTest(t), l)
+
+	pm := pin.NewManager(pinStorageImpl(s), t.Wipe)
+	dev := &device.Device{
+		Token:    t,
+		PIN:      pm,
+		Confirm:  apps.ConfirmFunc(consoleConfirm),
+		Reveal:   apps.SecretConfirmFunc(consoleConfirmSecret),
+		Revision: Revision,
+	}
+
+	ah := apps.NewHandler(t)
+	ah.Protect(pm, dev)
+	ah.Register(dev, &cosmos.Cosmos{}, apps.NewSelector(&openpgp.OpenPGP{
+		Storage: s,
+	}, &oath.OATH{
+		Storage: s,
+		Confirm: apps.ConfirmFunc(consoleConfirm),
+	}), &nostr.Nostr{
+		Confirm: apps.ConfirmFunc(consoleConfirm),
+	}, &age.Age{})
 
 	hh := newHidHandler(l, ah)
 
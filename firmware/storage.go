@@ -0,0 +1,28 @@
+package main
+
+import (
+	usbarmory "github.com/f-secure-foundry/tamago/board/f-secure/usbarmory/mark-two"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+// storageBlocks is the amount of blocks reserved for device state at the end of the internal eMMC.
+const storageBlocks = 64
+
+// storageImpl returns a storage.Storage persisted on the internal eMMC, protected by protectStorage.
+func storageImpl() (storage.Storage, error) {
+	card := usbarmory.MMC
+
+	card.Init(usbarmory.MMC_BUS_WIDTH)
+	if err := card.Detect(); err != nil {
+		return nil, err
+	}
+
+	info := card.Info()
+
+	s, err := storage.NewBlock(card, info.Blocks-storageBlocks, storageBlocks, info.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return protectStorage(s)
+}
@@ -3,10 +3,13 @@
 package main
 
 import (
+	"crypto/aes"
 	"time"
 
 	usbarmory "github.com/f-secure-foundry/tamago/board/f-secure/usbarmory/mark-two"
+	"github.com/f-secure-foundry/tamago/soc/imx6/dcp"
 	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
 )
 
 // this file defines functions needed when the TEE is disabled
@@ -20,6 +23,26 @@ func resetBoard() {
 	usbarmory.Reset()
 }
 
-func tokenImpl() crypto.Token {
-	return crypto.NewDumbToken()
+func tokenImpl(s storage.Storage) crypto.DeviceToken {
+	return crypto.NewDumbToken(s)
+}
+
+// pinStorageImpl returns s, which protectStorage encrypted along with the seeds.
+func pinStorageImpl(s storage.Storage) storage.Storage {
+	return s
+}
+
+// protectStorage encrypts s with a key derived by the DCP from the SoC unique key, since it holds the seeds,
+// the PIN verifiers and the attestation key: reading the eMMC out of the device doesn't reveal them, and
+// they can only be read back by the device which wrote them.
+// The diversifier differs from the one of the Trusted OS secure storage, so that the keys do too.
+func protectStorage(s storage.Storage) (storage.Storage, error) {
+	dcp.Init()
+
+	key, err := dcp.DeriveKey(crypto.Diversifier()[aes.BlockSize:2*aes.BlockSize], make([]byte, aes.BlockSize), -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewEncrypted(s, key)
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/f-secure-foundry/GoTEE/syscall"
 	"github.com/f-secure-foundry/tamago/soc/imx6"
 	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
 	cryptoapplet "github.com/wallera-computer/wallera/tee/cryptography_applet/token/client"
 	"github.com/wallera-computer/wallera/tee/mem"
 	_ "unsafe"
@@ -46,6 +47,19 @@ func resetBoard() {
 	imx6.Reset()
 }
 
-func tokenImpl() crypto.Token {
+// tokenImpl ignores s, since the seed is kept in the Trusted OS secure storage.
+func tokenImpl(_ storage.Storage) crypto.DeviceToken {
 	return &cryptoapplet.TEEToken{}
 }
+
+// pinStorageImpl ignores s, since the PIN verifiers and the retry counter are kept in the Trusted OS
+// secure storage along with the seeds: verifiers read out of the eMMC can't be brute forced offline.
+func pinStorageImpl(_ storage.Storage) storage.Storage {
+	return cryptoapplet.PINStorage{}
+}
+
+// protectStorage returns s as it is: seeds, PIN verifiers and the attestation key are kept in the
+// Trusted OS secure storage, which the Trusted OS encrypts with a DCP key of its own.
+func protectStorage(s storage.Storage) (storage.Storage, error) {
+	return s, nil
+}
@@ -23,7 +23,10 @@ func rebootWatcher() {
 
 	for {
 		runtime.Gosched()
+
+		consoleInput.Lock()
 		imx6.UART2.Read(buf)
+		consoleInput.Unlock()
 		if buf[0] == 0 {
 			continue
 		}
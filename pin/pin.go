@@ -0,0 +1,348 @@
+// Package pin handles the PINs protecting access to the device.
+package pin
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wallera-computer/wallera/storage"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	pinKey       = "pin/verifier"
+	duressPinKey = "pin/duress-verifier"
+	retriesKey   = "pin/retries"
+
+	// MaxRetries is the amount of consecutive wrong PINs after which the device is wiped.
+	MaxRetries = 10
+
+	// MinLength and MaxLength bound the length of PINs, in bytes.
+	MinLength = 4
+	MaxLength = 32
+
+	saltSize   = 16
+	hashSize   = 32
+	iterations = 20000
+)
+
+var (
+	// ErrNotSet is returned when verifying a PIN before one has been set.
+	ErrNotSet = errors.New("no pin has been set")
+
+	// ErrWrongPIN is returned when a PIN doesn't match.
+	ErrWrongPIN = errors.New("wrong pin")
+
+	// ErrWiped is returned when the last retry has been used, and the device has been wiped.
+	ErrWiped = errors.New("too many wrong pins, device has been wiped")
+)
+
+//go:generate stringer -type State
+type State int
+
+// Lock states of the device.
+const (
+	Locked State = iota
+	Unlocked
+
+	// Duress is entered through the duress PIN. It must look exactly like Unlocked to the host,
+	// while the device operates on the decoy wallet.
+	Duress
+)
+
+// verifier is a salted and stretched PIN hash.
+type verifier struct {
+	Salt []byte
+	Hash []byte
+}
+
+func stretch(pin, salt []byte) []byte {
+	return pbkdf2.Key(pin, salt, iterations, hashSize, sha256.New)
+}
+
+func newVerifier(pin []byte) (verifier, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return verifier{}, fmt.Errorf("cannot generate pin salt, %w", err)
+	}
+
+	return verifier{
+		Salt: salt,
+		Hash: stretch(pin, salt),
+	}, nil
+}
+
+func (v verifier) matches(pin []byte) bool {
+	return hmac.Equal(stretch(pin, v.Salt), v.Hash)
+}
+
+// validPIN returns an error if pin can't be used as a PIN.
+func validPIN(pin []byte) error {
+	if len(pin) < MinLength || len(pin) > MaxLength {
+		return fmt.Errorf("pin must be between %v and %v bytes long", MinLength, MaxLength)
+	}
+
+	return nil
+}
+
+// Manager keeps track of the PINs and of the lock state of the device.
+type Manager struct {
+	s     storage.Storage
+	wipe  func() error
+	state State
+}
+
+// NewManager returns a Manager keeping PIN verifiers and the retry counter in s, which calls wipe
+// to erase the device secrets after MaxRetries consecutive wrong PINs.
+// The device starts Locked.
+func NewManager(s storage.Storage, wipe func() error) *Manager {
+	return &Manager{
+		s:     s,
+		wipe:  wipe,
+		state: Locked,
+	}
+}
+
+// State returns the current lock state.
+func (m *Manager) State() State {
+	return m.state
+}
+
+// Unlocked implements the apps.Lock interface.
+func (m *Manager) Unlocked() bool {
+	return m.state != Locked
+}
+
+// RetriesLeft returns the amount of wrong PINs which can still be entered before the device is wiped.
+func (m *Manager) RetriesLeft() (int, error) {
+	raw, err := m.s.Get(retriesKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return MaxRetries, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("cannot read pin retry counter, %w", err)
+	}
+
+	if len(raw) != 1 || int(raw[0]) > MaxRetries {
+		return 0, fmt.Errorf("malformed pin retry counter")
+	}
+
+	return int(raw[0]), nil
+}
+
+func (m *Manager) setRetriesLeft(retries int) error {
+	if err := m.s.Set(retriesKey, []byte{byte(retries)}); err != nil {
+		return fmt.Errorf("cannot write pin retry counter, %w", err)
+	}
+
+	return nil
+}
+
+// wipeDevice erases the device secrets, and then the PINs, leaving the device as fresh.
+// The PINs and the exhausted retry counter are only deleted once the secrets are gone, so that
+// a wipe interrupted by a reboot is carried out on the next verification.
+func (m *Manager) wipeDevice() error {
+	m.state = Locked
+
+	if err := m.wipe(); err != nil {
+		return fmt.Errorf("cannot wipe device, %w", err)
+	}
+
+	for _, key := range []string{pinKey, duressPinKey, retriesKey} {
+		if err := m.s.Delete(key); err != nil {
+			return fmt.Errorf("cannot wipe pin, %w", err)
+		}
+	}
+
+	return ErrWiped
+}
+
+func (m *Manager) readVerifier(key string) (verifier, bool, error) {
+	raw, err := m.s.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return verifier{}, false, nil
+	}
+
+	if err != nil {
+		return verifier{}, false, fmt.Errorf("cannot read pin verifier, %w", err)
+	}
+
+	v := verifier{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return verifier{}, false, fmt.Errorf("cannot unmarshal pin verifier, %w", err)
+	}
+
+	return v, true, nil
+}
+
+func (m *Manager) writeVerifier(key string, pin []byte) error {
+	v, err := newVerifier(pin)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot marshal pin verifier, %w", err)
+	}
+
+	return m.s.Set(key, raw)
+}
+
+// IsSet returns true if a PIN has been set.
+func (m *Manager) IsSet() (bool, error) {
+	_, found, err := m.readVerifier(pinKey)
+	return found, err
+}
+
+// Set sets the PIN of a device which has none, and unlocks it.
+func (m *Manager) Set(pin []byte) error {
+	if err := validPIN(pin); err != nil {
+		return err
+	}
+
+	found, err := m.IsSet()
+	if err != nil {
+		return err
+	}
+
+	if found {
+		return fmt.Errorf("pin has already been set")
+	}
+
+	if err := m.writeVerifier(pinKey, pin); err != nil {
+		return err
+	}
+
+	if err := m.setRetriesLeft(MaxRetries); err != nil {
+		return err
+	}
+
+	m.state = Unlocked
+	return nil
+}
+
+// SetDuress sets the duress PIN, which unlocks the device into the Duress state.
+// The device must have been unlocked with the PIN, and the duress PIN must differ from it.
+func (m *Manager) SetDuress(pin []byte) error {
+	if m.state != Unlocked {
+		return fmt.Errorf("device must be unlocked with the pin")
+	}
+
+	if err := validPIN(pin); err != nil {
+		return err
+	}
+
+	v, found, err := m.readVerifier(pinKey)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrNotSet
+	}
+
+	if v.matches(pin) {
+		return fmt.Errorf("duress pin must differ from the pin")
+	}
+
+	return m.writeVerifier(duressPinKey, pin)
+}
+
+// Verify checks pin against the PIN and the duress PIN, and returns the state it unlocked.
+// Both verifiers are always computed, so that the time it takes doesn't tell which one matched.
+// A retry is used up before checking pin, so that cutting power mid-check doesn't save it:
+// when the last one is used by a wrong PIN, the device is wiped and ErrWiped is returned.
+func (m *Manager) Verify(pin []byte) (State, error) {
+	v, found, err := m.readVerifier(pinKey)
+	if err != nil {
+		return m.state, err
+	}
+
+	if !found {
+		return m.state, ErrNotSet
+	}
+
+	dv, duressFound, err := m.readVerifier(duressPinKey)
+	if err != nil {
+		return m.state, err
+	}
+
+	if !duressFound {
+		// stretch against a dummy verifier
+		dv = verifier{
+			Salt: make([]byte, saltSize),
+		}
+	}
+
+	retries, err := m.RetriesLeft()
+	if err != nil {
+		return m.state, err
+	}
+
+	if retries == 0 {
+		return Locked, m.wipeDevice()
+	}
+
+	if err := m.setRetriesLeft(retries - 1); err != nil {
+		return m.state, err
+	}
+
+	pinMatches := v.matches(pin)
+	duressMatches := dv.matches(pin) && duressFound
+
+	switch {
+	case pinMatches:
+		m.state = Unlocked
+	case duressMatches:
+		m.state = Duress
+	case retries == 1:
+		return Locked, m.wipeDevice()
+	default:
+		return m.state, ErrWrongPIN
+	}
+
+	if err := m.setRetriesLeft(MaxRetries); err != nil {
+		return m.state, err
+	}
+
+	return m.state, nil
+}
+
+// Change replaces the PIN old unlocks with newPIN, using up a retry like Verify does.
+// Under duress, the duress PIN is the one being changed: newPIN is never checked against the PIN,
+// which would give away whether it's the PIN, and that a duress PIN exists at all.
+// The PIN still can't be changed to the duress PIN.
+func (m *Manager) Change(old, newPIN []byte) error {
+	if err := validPIN(newPIN); err != nil {
+		return err
+	}
+
+	state, err := m.Verify(old)
+	if err != nil {
+		return err
+	}
+
+	if state == Duress {
+		// stretch against a dummy verifier, taking as long as checking the duress pin does
+		_ = verifier{Salt: make([]byte, saltSize)}.matches(newPIN)
+
+		return m.writeVerifier(duressPinKey, newPIN)
+	}
+
+	duress, found, err := m.readVerifier(duressPinKey)
+	if err != nil {
+		return err
+	}
+
+	if found && duress.matches(newPIN) {
+		return fmt.Errorf("pin and duress pin must differ")
+	}
+
+	return m.writeVerifier(pinKey, newPIN)
+}
@@ -0,0 +1,194 @@
+package pin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+func noWipe() error {
+	return nil
+}
+
+func TestManagerSetAndVerify(t *testing.T) {
+	s := storage.NewMemory()
+	m := NewManager(s, noWipe)
+	require.Equal(t, Locked, m.State())
+
+	_, err := m.Verify([]byte("1234"))
+	require.ErrorIs(t, err, ErrNotSet)
+
+	require.Error(t, m.Set([]byte("123")))
+	require.NoError(t, m.Set([]byte("1234")))
+	require.Equal(t, Unlocked, m.State())
+	require.Error(t, m.Set([]byte("5678")))
+
+	// the verifier doesn't hold the pin in clear
+	raw, err := s.Get(pinKey)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "1234")
+
+	// verifiers persist across instances
+	m = NewManager(s, noWipe)
+	require.Equal(t, Locked, m.State())
+
+	state, err := m.Verify([]byte("0000"))
+	require.ErrorIs(t, err, ErrWrongPIN)
+	require.Equal(t, Locked, state)
+
+	state, err = m.Verify([]byte("1234"))
+	require.NoError(t, err)
+	require.Equal(t, Unlocked, state)
+}
+
+func TestManagerDuress(t *testing.T) {
+	s := storage.NewMemory()
+	m := NewManager(s, noWipe)
+
+	require.Error(t, m.SetDuress([]byte("9999")))
+
+	require.NoError(t, m.Set([]byte("1234")))
+	require.Error(t, m.SetDuress([]byte("1234")))
+	require.NoError(t, m.SetDuress([]byte("9999")))
+
+	m = NewManager(s, noWipe)
+	require.Error(t, m.SetDuress([]byte("8888")))
+
+	state, err := m.Verify([]byte("9999"))
+	require.NoError(t, err)
+	require.Equal(t, Duress, state)
+
+	// the duress pin can't be changed while under duress
+	require.Error(t, m.SetDuress([]byte("8888")))
+
+	state, err = m.Verify([]byte("1234"))
+	require.NoError(t, err)
+	require.Equal(t, Unlocked, state)
+}
+
+func TestManagerChange(t *testing.T) {
+	s := storage.NewMemory()
+	m := NewManager(s, noWipe)
+
+	require.NoError(t, m.Set([]byte("1234")))
+	require.NoError(t, m.SetDuress([]byte("9999")))
+
+	require.ErrorIs(t, m.Change([]byte("0000"), []byte("5678")), ErrWrongPIN)
+	require.Error(t, m.Change([]byte("1234"), []byte("9999")))
+	require.NoError(t, m.Change([]byte("1234"), []byte("5678")))
+
+	_, err := m.Verify([]byte("1234"))
+	require.ErrorIs(t, err, ErrWrongPIN)
+
+	state, err := m.Verify([]byte("5678"))
+	require.NoError(t, err)
+	require.Equal(t, Unlocked, state)
+
+	// under duress, the duress pin is changed
+	require.NoError(t, m.Change([]byte("9999"), []byte("4321")))
+
+	state, err = m.Verify([]byte("4321"))
+	require.NoError(t, err)
+	require.Equal(t, Duress, state)
+
+	state, err = m.Verify([]byte("5678"))
+	require.NoError(t, err)
+	require.Equal(t, Unlocked, state)
+}
+
+func TestManagerChangeUnderDuressTellsNothingAboutThePIN(t *testing.T) {
+	s := storage.NewMemory()
+	m := NewManager(s, noWipe)
+
+	require.NoError(t, m.Set([]byte("1234")))
+	require.NoError(t, m.SetDuress([]byte("9999")))
+
+	// try every candidate as the new duress pin, the pin last
+	duressPIN := []byte("9999")
+	for _, candidate := range []string{"0000", "1111", "4321", "5678", "1234"} {
+		require.NoError(t, m.Change(duressPIN, []byte(candidate)))
+		require.Equal(t, Duress, m.State())
+
+		duressPIN = []byte(candidate)
+	}
+
+	left, err := m.RetriesLeft()
+	require.NoError(t, err)
+	require.Equal(t, MaxRetries, left)
+
+	// the pin still unlocks the real wallet
+	state, err := m.Verify([]byte("1234"))
+	require.NoError(t, err)
+	require.Equal(t, Unlocked, state)
+}
+
+func TestManagerRetries(t *testing.T) {
+	s := storage.NewMemory()
+	m := NewManager(s, noWipe)
+	require.NoError(t, m.Set([]byte("1234")))
+
+	for i := 0; i < MaxRetries-1; i++ {
+		_, err := m.Verify([]byte("0000"))
+		require.ErrorIs(t, err, ErrWrongPIN)
+	}
+
+	// the counter survives reboots
+	m = NewManager(s, noWipe)
+	left, err := m.RetriesLeft()
+	require.NoError(t, err)
+	require.Equal(t, 1, left)
+
+	// and is reset by the right pin
+	_, err = m.Verify([]byte("1234"))
+	require.NoError(t, err)
+
+	left, err = m.RetriesLeft()
+	require.NoError(t, err)
+	require.Equal(t, MaxRetries, left)
+}
+
+func TestManagerWipesAfterTooManyFailures(t *testing.T) {
+	s := storage.NewMemory()
+
+	wiped := 0
+	failWipe := true
+	wipe := func() error {
+		if failWipe {
+			return errors.New("interrupted")
+		}
+
+		wiped++
+		return nil
+	}
+
+	m := NewManager(s, wipe)
+	require.NoError(t, m.Set([]byte("1234")))
+
+	for i := 0; i < MaxRetries-1; i++ {
+		_, err := m.Verify([]byte("0000"))
+		require.ErrorIs(t, err, ErrWrongPIN)
+	}
+
+	// an interrupted wipe is carried out on the next verification, even with the right pin
+	state, err := m.Verify([]byte("0000"))
+	require.Error(t, err)
+	require.Equal(t, Locked, state)
+
+	failWipe = false
+
+	m = NewManager(s, wipe)
+	state, err = m.Verify([]byte("1234"))
+	require.ErrorIs(t, err, ErrWiped)
+	require.Equal(t, Locked, state)
+	require.Equal(t, 1, wiped)
+
+	found, err := m.IsSet()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	left, err := m.RetriesLeft()
+	require.NoError(t, err)
+	require.Equal(t, MaxRetries, left)
+}
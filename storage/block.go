@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BlockDevice is a storage medium accessed in fixed-size blocks, like the
+// USB armory eMMC.
+type BlockDevice interface {
+	ReadBlocks(lba int, buf []byte) error
+	WriteBlocks(lba int, buf []byte) error
+}
+
+// Compile-time check which fails if block doesn't comply with
+// Storage interface.
+var _ Storage = (*block)(nil)
+
+type block struct {
+	m         sync.Mutex
+	dev       BlockDevice
+	lba       int
+	blocks    int
+	blockSize int
+	data      map[string][]byte
+}
+
+// NewBlock returns a Storage which persists its content on dev, in the area
+// starting at lba and spanning the given amount of blocks.
+func NewBlock(dev BlockDevice, lba, blocks, blockSize int) (Storage, error) {
+	if blocks <= 0 || blockSize <= 0 {
+		return nil, fmt.Errorf("invalid storage area size")
+	}
+
+	raw := make([]byte, blocks*blockSize)
+	if err := dev.ReadBlocks(lba, raw); err != nil {
+		return nil, fmt.Errorf("cannot read storage area, %w", err)
+	}
+
+	data, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block{
+		dev:       dev,
+		lba:       lba,
+		blocks:    blocks,
+		blockSize: blockSize,
+		data:      data,
+	}, nil
+}
+
+func (b *block) Get(key string) ([]byte, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	v, found := b.data[key]
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte{}, v...), nil
+}
+
+func (b *block) Set(key string, value []byte) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	old, found := b.data[key]
+
+	b.data[key] = append([]byte{}, value...)
+
+	if err := b.flush(); err != nil {
+		if found {
+			b.data[key] = old
+		} else {
+			delete(b.data, key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (b *block) Delete(key string) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	old, found := b.data[key]
+	if !found {
+		return nil
+	}
+
+	delete(b.data, key)
+
+	if err := b.flush(); err != nil {
+		b.data[key] = old
+		return err
+	}
+
+	return nil
+}
+
+// flush writes the whole storage content on the underlying device.
+func (b *block) flush() error {
+	raw, err := encode(b.data)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) > b.blocks*b.blockSize {
+		return fmt.Errorf("storage content exceeds %v bytes", b.blocks*b.blockSize)
+	}
+
+	// only write the blocks we need
+	used := (len(raw) + b.blockSize - 1) / b.blockSize
+	buf := make([]byte, used*b.blockSize)
+	copy(buf, raw)
+
+	return b.dev.WriteBlocks(b.lba, buf)
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// Compile-time check which fails if encrypted doesn't comply with
+// Storage interface.
+var _ Storage = (*encrypted)(nil)
+
+type encrypted struct {
+	s    Storage
+	aead cipher.AEAD
+}
+
+// NewEncrypted returns a Storage which encrypts values with AES-GCM under key before
+// writing them to s. Keys are left in clear, and authenticated along with their value.
+func NewEncrypted(s Storage, key []byte) (Storage, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encrypted{
+		s:    s,
+		aead: aead,
+	}, nil
+}
+
+func (e *encrypted) Get(key string) ([]byte, error) {
+	raw, err := e.s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := e.aead.NonceSize()
+	if len(raw) < ns {
+		return nil, fmt.Errorf("encrypted value for %v is too short", key)
+	}
+
+	value, err := e.aead.Open(nil, raw[:ns], raw[ns:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt value for %v, %w", key, err)
+	}
+
+	return value, nil
+}
+
+func (e *encrypted) Set(key string, value []byte) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	return e.s.Set(key, e.aead.Seal(nonce, nonce, value, []byte(key)))
+}
+
+func (e *encrypted) Delete(key string) error {
+	return e.s.Delete(key)
+}
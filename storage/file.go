@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Compile-time check which fails if file doesn't comply with
+// Storage interface.
+var _ Storage = (*file)(nil)
+
+type file struct {
+	m    sync.Mutex
+	path string
+	data map[string][]byte
+}
+
+// NewFile returns a Storage which persists its content in the file at path.
+// The file is created on the first write if it doesn't exist.
+func NewFile(path string) (Storage, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cannot read storage file, %w", err)
+	}
+
+	data, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{
+		path: path,
+		data: data,
+	}, nil
+}
+
+func (f *file) Get(key string) ([]byte, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	v, found := f.data[key]
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte{}, v...), nil
+}
+
+func (f *file) Set(key string, value []byte) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	old, found := f.data[key]
+
+	f.data[key] = append([]byte{}, value...)
+
+	if err := f.flush(); err != nil {
+		if found {
+			f.data[key] = old
+		} else {
+			delete(f.data, key)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (f *file) Delete(key string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	old, found := f.data[key]
+	if !found {
+		return nil
+	}
+
+	delete(f.data, key)
+
+	if err := f.flush(); err != nil {
+		f.data[key] = old
+		return err
+	}
+
+	return nil
+}
+
+// flush atomically replaces the storage file with the current content.
+func (f *file) flush() error {
+	raw, err := encode(f.data)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path))
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,31 @@
+package storage
+
+// Compile-time check which fails if prefixed doesn't comply with
+// Storage interface.
+var _ Storage = (*prefixed)(nil)
+
+type prefixed struct {
+	s      Storage
+	prefix string
+}
+
+// NewPrefixed returns a Storage which prepends prefix to every key before accessing s,
+// so that several users can keep their content under the same keys in a single Storage.
+func NewPrefixed(s Storage, prefix string) Storage {
+	return &prefixed{
+		s:      s,
+		prefix: prefix,
+	}
+}
+
+func (p *prefixed) Get(key string) ([]byte, error) {
+	return p.s.Get(p.prefix + key)
+}
+
+func (p *prefixed) Set(key string, value []byte) error {
+	return p.s.Set(p.prefix+key, value)
+}
+
+func (p *prefixed) Delete(key string) error {
+	return p.s.Delete(p.prefix + key)
+}
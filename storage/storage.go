@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when the requested key doesn't exist.
+var ErrNotFound = errors.New("key not found")
+
+// Storage is a key-value store for data which must persist across device reboots,
+// like application state and retry counters.
+type Storage interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// Compile-time check which fails if memory doesn't comply with
+// Storage interface.
+var _ Storage = (*memory)(nil)
+
+type memory struct {
+	m    sync.Mutex
+	data map[string][]byte
+}
+
+// NewMemory returns a Storage which keeps its content in memory only.
+func NewMemory() Storage {
+	return &memory{
+		data: map[string][]byte{},
+	}
+}
+
+func (mm *memory) Get(key string) ([]byte, error) {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	v, found := mm.data[key]
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte{}, v...), nil
+}
+
+func (mm *memory) Set(key string, value []byte) error {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	mm.data[key] = append([]byte{}, value...)
+	return nil
+}
+
+func (mm *memory) Delete(key string) error {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	delete(mm.data, key)
+	return nil
+}
+
+var magic = [4]byte{'W', 'L', 'S', 'T'}
+
+// header precedes the serialized storage content.
+type header struct {
+	Magic    [4]byte
+	Length   uint32
+	Checksum [sha256.Size]byte
+}
+
+// encode serializes data prefixed by a header.
+func encode(data map[string][]byte) ([]byte, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		Magic:    magic,
+		Length:   uint32(len(content)),
+		Checksum: sha256.Sum256(content),
+	}
+
+	buf := &bytes.Buffer{}
+	if err := binary.Write(buf, binary.BigEndian, h); err != nil {
+		return nil, err
+	}
+
+	buf.Write(content)
+
+	return buf.Bytes(), nil
+}
+
+// decode deserializes data produced by encode.
+// If raw doesn't contain a valid header, an empty map is returned.
+func decode(raw []byte) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	h := header{}
+	if err := binary.Read(bytes.NewReader(raw), binary.BigEndian, &h); err != nil {
+		return data, nil
+	}
+
+	if h.Magic != magic {
+		return data, nil
+	}
+
+	start := binary.Size(h)
+	if int(h.Length) > len(raw)-start {
+		return nil, fmt.Errorf("storage content length %v exceeds available space", h.Length)
+	}
+
+	content := raw[start : start+int(h.Length)]
+	if sha256.Sum256(content) != h.Checksum {
+		return nil, fmt.Errorf("storage content checksum mismatch")
+	}
+
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal storage content, %w", err)
+	}
+
+	return data, nil
+}
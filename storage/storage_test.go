@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testBlockSize = 512
+
+type memoryBlockDevice struct {
+	data []byte
+}
+
+func (m *memoryBlockDevice) ReadBlocks(lba int, buf []byte) error {
+	copy(buf, m.data[lba*testBlockSize:])
+	return nil
+}
+
+func (m *memoryBlockDevice) WriteBlocks(lba int, buf []byte) error {
+	if len(buf)%testBlockSize != 0 {
+		return fmt.Errorf("write size must be %d bytes aligned", testBlockSize)
+	}
+
+	copy(m.data[lba*testBlockSize:], buf)
+	return nil
+}
+
+func testStorage(t *testing.T, s Storage) {
+	t.Helper()
+
+	_, err := s.Get("missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Set("key", []byte("value")))
+
+	v, err := s.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), v)
+
+	require.NoError(t, s.Delete("key"))
+
+	_, err = s.Get("key")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+}
+
+func TestBlockPersistsAcrossInstances(t *testing.T) {
+	dev := &memoryBlockDevice{data: make([]byte, 16*testBlockSize)}
+
+	s, err := NewBlock(dev, 8, 8, testBlockSize)
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	require.NoError(t, s.Set("persistent", []byte{1, 2, 3}))
+
+	reloaded, err := NewBlock(dev, 8, 8, testBlockSize)
+	require.NoError(t, err)
+
+	v, err := reloaded.Get("persistent")
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, v)
+
+	// the area before lba must be left untouched
+	require.Equal(t, make([]byte, 8*testBlockSize), dev.data[:8*testBlockSize])
+}
+
+func TestBlockRejectsOversizedContent(t *testing.T) {
+	dev := &memoryBlockDevice{data: make([]byte, testBlockSize)}
+
+	s, err := NewBlock(dev, 0, 1, testBlockSize)
+	require.NoError(t, err)
+
+	require.Error(t, s.Set("big", make([]byte, testBlockSize)))
+
+	_, err = s.Get("big")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFilePersistsAcrossInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage")
+
+	s, err := NewFile(path)
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	require.NoError(t, s.Set("persistent", []byte{1, 2, 3}))
+
+	reloaded, err := NewFile(path)
+	require.NoError(t, err)
+
+	v, err := reloaded.Get("persistent")
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, v)
+}
+
+func TestEncryptedHidesAndAuthenticatesValues(t *testing.T) {
+	backend := NewMemory()
+
+	s, err := NewEncrypted(backend, make([]byte, 16))
+	require.NoError(t, err)
+	testStorage(t, s)
+
+	require.NoError(t, s.Set("secret", []byte("value")))
+
+	raw, err := backend.Get("secret")
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "value")
+
+	// values are bound to their key
+	require.NoError(t, backend.Set("moved", raw))
+	_, err = s.Get("moved")
+	require.Error(t, err)
+
+	other, err := NewEncrypted(backend, []byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	_, err = other.Get("secret")
+	require.Error(t, err)
+}
+
+func TestPrefixedIsolatesKeys(t *testing.T) {
+	backend := NewMemory()
+
+	s := NewPrefixed(backend, "prefix/")
+	testStorage(t, s)
+
+	require.NoError(t, s.Set("key", []byte("prefixed")))
+	require.NoError(t, backend.Set("key", []byte("plain")))
+
+	v, err := s.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("prefixed"), v)
+
+	v, err = backend.Get("prefix/key")
+	require.NoError(t, err)
+	require.Equal(t, []byte("prefixed"), v)
+}
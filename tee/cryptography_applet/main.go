@@ -11,10 +11,11 @@ import (
 	"runtime"
 	_ "unsafe"
 
-	"github.com/f-secure-foundry/GoTEE/applet"
 	_ "github.com/f-secure-foundry/GoTEE/applet"
 	"github.com/f-secure-foundry/GoTEE/syscall"
+	"github.com/wallera-computer/wallera/crypto"
 	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/storage"
 	"github.com/wallera-computer/wallera/tee/cryptography_applet/info"
 	"github.com/wallera-computer/wallera/tee/cryptography_applet/token"
 	"github.com/wallera-computer/wallera/tee/mem"
@@ -31,6 +32,9 @@ var ramSize uint32 = mem.AppletSize
 //go:linkname ramStackOffset runtime.ramStackOffset
 var ramStackOffset uint32 = 0x100
 
+// pinPrefix namespaces the PIN state of the nonsecure world in the secure storage, apart from the seeds.
+const pinPrefix = "nonsecure-pin/"
+
 var l *zap.SugaredLogger
 
 func init() {
@@ -48,28 +52,35 @@ func main() {
 
 	l.Infof("PL0 %s/%s (%s) • TEE user applet (Secure World)", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
-	mail, err := client.SecureRPC{}.RetrieveMail(info.AppletID)
-	if err != nil {
-		panic(err)
-	}
+	// the token, and its key cache, live as long as the applet does: the Trusted OS resumes it for
+	// every mail rather than loading it anew
+	t := token.NewToken(client.SecureStorage{})
+	pins := storage.NewPrefixed(client.SecureStorage{}, pinPrefix)
 
-	t := token.NewToken()
+	for {
+		mail, err := client.SecureRPC{}.RetrieveMail(info.AppletID)
+		if err != nil {
+			panic(err)
+		}
 
-	resp, err := token.Dispatch(mail.Payload, t)
-	if err != nil {
-		l.Fatalw("cannot dispatch:", "error", err)
-	}
+		resp, err := token.Dispatch(mail.Payload, t, pins, client.SecureRPC{}.NonsecureRevision)
+		crypto.Wipe(mail.Payload)
+		if err != nil {
+			l.Fatalw("cannot dispatch:", "error", err)
+		}
 
-	mail.Payload = resp
+		mail.Payload = resp
 
-	err = client.SecureRPC{}.WriteResponse(mail)
-	if err != nil {
-		panic(err)
-	}
+		err = client.SecureRPC{}.WriteResponse(mail)
+		crypto.Wipe(resp)
+		if err != nil {
+			panic(err)
+		}
 
-	l.Info("written response from trusted applet")
+		l.Info("written response from trusted applet")
 
-	applet.Exit()
+		client.WaitMail()
+	}
 }
 
 func handlePanic() {
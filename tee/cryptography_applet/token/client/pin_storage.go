@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"github.com/wallera-computer/wallera/storage"
+	teetoken "github.com/wallera-computer/wallera/tee/cryptography_applet/token"
+)
+
+// Compile-time check which fails if PINStorage doesn't comply with
+// storage.Storage interface.
+var _ storage.Storage = PINStorage{}
+
+// PINStorage is the Storage the nonsecure world keeps its PIN verifiers and retry counter in.
+// It lives in the secure storage of the applet, apart from the seeds: unlike the nonsecure storage, it
+// is encrypted with a key only the Trusted OS can derive, so that PIN verifiers read out of the eMMC
+// can't be brute forced offline.
+type PINStorage struct{}
+
+func (PINStorage) Get(key string) ([]byte, error) {
+	req := teetoken.PINStorageRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestPINStorageGet,
+		},
+		Key: key,
+	}
+
+	resp := teetoken.PINStorageResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	if !resp.Found {
+		return nil, storage.ErrNotFound
+	}
+
+	return resp.Value, nil
+}
+
+func (PINStorage) Set(key string, value []byte) error {
+	req := teetoken.PINStorageRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestPINStorageSet,
+		},
+		Key:   key,
+		Value: value,
+	}
+
+	resp := teetoken.PINStorageResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (PINStorage) Delete(key string) error {
+	req := teetoken.PINStorageRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestPINStorageDelete,
+		},
+		Key: key,
+	}
+
+	resp := teetoken.PINStorageResponse{}
+
+	return doRequest(req, &resp)
+}
@@ -1,8 +1,6 @@
 package crypto
 
 import (
-	"crypto/sha256"
-
 	"github.com/wallera-computer/wallera/crypto"
 	"github.com/wallera-computer/wallera/tee/cryptography_applet/info"
 	teetoken "github.com/wallera-computer/wallera/tee/cryptography_applet/token"
@@ -11,11 +9,43 @@ import (
 )
 
 // Compile-time check which fails if TEEToken doesn't comply with
-// crypto.Token interface.
-var _ crypto.Token = (*TEEToken)(nil)
+// crypto.DeviceToken interface.
+var _ crypto.DeviceToken = (*TEEToken)(nil)
+
+// Compile-time check which fails if TEEToken doesn't comply with
+// crypto.ScopedTokenProvider interface.
+var _ crypto.ScopedTokenProvider = (*TEEToken)(nil)
+
+// Compile-time check which fails if TEEToken doesn't comply with
+// crypto.MeasuredAttester interface.
+var _ crypto.MeasuredAttester = (*TEEToken)(nil)
 
 type TEEToken struct {
-	path crypto.DerivationPath
+	session crypto.Session
+
+	// scoped handles share the session of the TEEToken they have been created from, and send the
+	// identifier of their scope along with their key operations
+	parent *TEEToken
+	scope  crypto.ScopeID
+}
+
+// WithScope returns a handle of tt whose key operations are refused by the applet outside of the
+// Scope identified by id.
+// Key operations of tt itself carry crypto.ScopeNone, and are refused.
+func (tt *TEEToken) WithScope(id crypto.ScopeID) crypto.Token {
+	return &TEEToken{
+		parent: tt.root(),
+		scope:  id,
+	}
+}
+
+// root returns the TEEToken holding the session of tt.
+func (tt *TEEToken) root() *TEEToken {
+	if tt.parent != nil {
+		return tt.parent
+	}
+
+	return tt
 }
 
 func (tt *TEEToken) RandomBytes(amount uint64) ([]byte, error) {
@@ -35,25 +65,315 @@ func (tt *TEEToken) RandomBytes(amount uint64) ([]byte, error) {
 	return resp.Data, nil
 }
 
-func (tt *TEEToken) DeriveSecret() ([32]byte, error) {
-	return [32]byte{}, nil // TODO: implement secret derivation (do we need it?)
+func (tt *TEEToken) HasSeed() (bool, error) {
+	req := teetoken.HasSeedRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestHasSeed,
+		},
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.HasSeedResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return false, err
+	}
+
+	return resp.HasSeed, nil
+}
+
+func (tt *TEEToken) HasAnySeed() (bool, error) {
+	req := teetoken.HasAnySeedRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestHasAnySeed,
+		},
+	}
+
+	resp := teetoken.HasAnySeedResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return false, err
+	}
+
+	return resp.HasAnySeed, nil
+}
+
+func (tt *TEEToken) GenerateSeed(entropyBits int) error {
+	req := teetoken.GenerateSeedRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestGenerateSeed,
+		},
+		EntropyBits: entropyBits,
+		Session:     tt.root().session,
+	}
+
+	resp := teetoken.GenerateSeedResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) ImportSeed(words []string) error {
+	indexes, err := crypto.MnemonicIndexesOf(words)
+	if err != nil {
+		return err
+	}
+
+	defer crypto.WipeIndexes(indexes)
+
+	req := teetoken.ImportSeedRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestImportSeed,
+		},
+		Indexes: indexes,
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.ImportSeedResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) ImportSLIP39Shares(shares [][]string) error {
+	indexes := make([][]uint16, 0, len(shares))
+	defer func() {
+		for _, share := range indexes {
+			crypto.WipeIndexes(share)
+		}
+	}()
+
+	for _, words := range shares {
+		share, err := crypto.SLIP39IndexesOf(words)
+		if err != nil {
+			return err
+		}
+
+		indexes = append(indexes, share)
+	}
+
+	req := teetoken.ImportSLIP39SharesRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestImportSLIP39Shares,
+		},
+		Indexes: indexes,
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.ImportSLIP39SharesResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) SetPassphrase(passphrase []byte) error {
+	if err := crypto.ValidPassphrase(passphrase); err != nil {
+		return err
+	}
+
+	crypto.Wipe(tt.root().session.Passphrase)
+	tt.root().session.Passphrase = append([]byte{}, passphrase...)
+	return nil
+}
+
+func (tt *TEEToken) UseDecoy(decoy bool) {
+	tt.root().session.Decoy = decoy
+}
+
+func (tt *TEEToken) Wipe() error {
+	tt.root().session.Wipe()
+
+	req := teetoken.WipeRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestWipe,
+		},
+	}
+
+	resp := teetoken.WipeResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) Profiles() ([]string, string, error) {
+	req := teetoken.ProfilesRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestProfiles,
+		},
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.ProfilesResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, "", err
+	}
+
+	return resp.Labels, resp.Active, nil
 }
 
-func (tt *TEEToken) Initialize(path crypto.DerivationPath) error {
-	tt.path = path
+func (tt *TEEToken) SelectProfile(label string) error {
+	req := teetoken.SelectProfileRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestSelectProfile,
+		},
+		Label:   label,
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.SelectProfileResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return err
+	}
+
+	// the applet resets the passphrase of the profile it switched to, so must the session
+	crypto.Wipe(tt.root().session.Passphrase)
+	tt.root().session.Passphrase = nil
 	return nil
 }
 
-func (tt *TEEToken) Sign(data []byte, algorithm crypto.Algorithm) ([]byte, error) {
-	hs := sha256.Sum256(data)
+func (tt *TEEToken) DeleteProfile(label string) error {
+	req := teetoken.DeleteProfileRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestDeleteProfile,
+		},
+		Label:   label,
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.DeleteProfileResponse{}
+
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) AttestationCSR() ([]byte, error) {
+	req := teetoken.AttestationCSRRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestAttestationCSR,
+		},
+	}
+
+	resp := teetoken.AttestationCSRResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.CSR, nil
+}
+
+func (tt *TEEToken) SetAttestationChain(chain []byte) error {
+	req := teetoken.SetAttestationChainRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestSetAttestationChain,
+		},
+		Chain: chain,
+	}
+
+	resp := teetoken.SetAttestationChainResponse{}
 
+	return doRequest(req, &resp)
+}
+
+func (tt *TEEToken) AttestationChain() ([]byte, error) {
+	req := teetoken.AttestationChainRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestAttestationChain,
+		},
+	}
+
+	resp := teetoken.AttestationChainResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Chain, nil
+}
+
+func (tt *TEEToken) Attest(challenge []byte, revision string) ([]byte, error) {
+	req := teetoken.AttestRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestAttest,
+		},
+		Challenge: challenge,
+		Revision:  revision,
+	}
+
+	resp := teetoken.AttestResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Signature, nil
+}
+
+// MeasuredRevision returns the revision of the nonsecure world image, as measured by the Trusted OS
+// which loaded it: the applet refuses to attest any other.
+func (tt *TEEToken) MeasuredRevision() (string, error) {
+	req := teetoken.MeasuredRevisionRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestMeasuredRevision,
+		},
+	}
+
+	resp := teetoken.MeasuredRevisionResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Revision, nil
+}
+
+func (tt *TEEToken) Fingerprint() ([]byte, error) {
+	req := teetoken.FingerprintRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestFingerprint,
+		},
+		Session: tt.root().session,
+	}
+
+	resp := teetoken.FingerprintResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+// SignDigest sends digest to the applet as-is: the applet never hashes digests.
+func (tt *TEEToken) SignDigest(path crypto.DerivationPath, algorithm crypto.Algorithm, digest []byte) ([]byte, error) {
 	req := teetoken.SignRequest{
 		Request: teetoken.Request{
 			ID: teetoken.RequestSign,
 		},
-		Data:           hs[:],
-		DerivationPath: tt.path,
-		Algorithm:      crypto.AlgoSecp256K1,
+		Digest:         digest,
+		DerivationPath: path,
+		Session:        tt.root().session,
+		Scope:          tt.scope,
+		Algorithm:      algorithm,
+	}
+
+	resp := teetoken.SignResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+func (tt *TEEToken) SignMessage(path crypto.DerivationPath, algorithm crypto.Algorithm, hash crypto.Hash, message []byte) ([]byte, error) {
+	req := teetoken.SignMessageRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestSignMessage,
+		},
+		Message:        message,
+		Hash:           hash,
+		DerivationPath: path,
+		Session:        tt.root().session,
+		Scope:          tt.scope,
+		Algorithm:      algorithm,
 	}
 
 	resp := teetoken.SignResponse{}
@@ -65,12 +385,37 @@ func (tt *TEEToken) Sign(data []byte, algorithm crypto.Algorithm) ([]byte, error
 	return resp.Data, nil
 }
 
-func (tt *TEEToken) PublicKey() ([]byte, error) {
+func (tt *TEEToken) ECDH(path crypto.DerivationPath, algorithm crypto.Algorithm, kdf crypto.KDF, peerPublicKey []byte) ([]byte, error) {
+	req := teetoken.ECDHRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestECDH,
+		},
+		PeerPublicKey:  peerPublicKey,
+		DerivationPath: path,
+		Session:        tt.root().session,
+		Scope:          tt.scope,
+		Algorithm:      algorithm,
+		KDF:            kdf,
+	}
+
+	resp := teetoken.ECDHResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Data, nil
+}
+
+func (tt *TEEToken) PublicKey(path crypto.DerivationPath, algorithm crypto.Algorithm) ([]byte, error) {
 	req := teetoken.PublicKeyRequest{
 		Request: teetoken.Request{
 			ID: teetoken.RequestPublicKey,
 		},
-		DerivationPath: tt.path,
+		DerivationPath: path,
+		Algorithm:      algorithm,
+		Session:        tt.root().session,
+		Scope:          tt.scope,
 	}
 
 	resp := teetoken.PublicKeyResponse{}
@@ -82,12 +427,31 @@ func (tt *TEEToken) PublicKey() ([]byte, error) {
 	return resp.Data, nil
 }
 
+func (tt *TEEToken) AccountKey(path crypto.DerivationPath) (crypto.AccountKey, error) {
+	req := teetoken.AccountKeyRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestAccountKey,
+		},
+		DerivationPath: path,
+		Session:        tt.root().session,
+		Scope:          tt.scope,
+	}
+
+	resp := teetoken.AccountKeyResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return crypto.AccountKey{}, err
+	}
+
+	return resp.Key, nil
+}
+
 func (tt *TEEToken) Mnemonic() ([]string, error) {
 	req := teetoken.MnemonicRequest{
 		Request: teetoken.Request{
 			ID: teetoken.RequestMnemonic,
 		},
-		DerivationPath: tt.path,
+		Session: tt.root().session,
 	}
 
 	resp := teetoken.MnemonicResponse{}
@@ -96,26 +460,98 @@ func (tt *TEEToken) Mnemonic() ([]string, error) {
 		return nil, err
 	}
 
-	return resp.Words, nil
+	defer crypto.WipeIndexes(resp.Indexes)
+
+	return crypto.MnemonicWords(resp.Indexes)
 }
 
-func (tt *TEEToken) SupportedSignAlgorithms() []crypto.Algorithm {
-	return []crypto.Algorithm{
-		crypto.AlgoSecp256K1,
+func (tt *TEEToken) SLIP39Shares(groupThreshold int, groups []crypto.SLIP39Group) ([][][]string, error) {
+	req := teetoken.SLIP39SharesRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestSLIP39Shares,
+		},
+		GroupThreshold: groupThreshold,
+		Groups:         groups,
+		Session:        tt.root().session,
+	}
+
+	resp := teetoken.SLIP39SharesResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		for _, group := range resp.Indexes {
+			for _, share := range group {
+				crypto.WipeIndexes(share)
+			}
+		}
+	}()
+
+	shares := make([][][]string, len(resp.Indexes))
+	for gi, group := range resp.Indexes {
+		for _, share := range group {
+			words, err := crypto.SLIP39Words(share)
+			if err != nil {
+				return nil, err
+			}
+
+			shares[gi] = append(shares[gi], words)
+		}
 	}
+
+	return shares, nil
 }
 
-func (tt *TEEToken) Clone() crypto.Token {
-	cl := *tt
-	return &cl
+func (tt *TEEToken) BIP85(app crypto.BIP85Application, length, index uint32) ([]byte, error) {
+	req := teetoken.BIP85Request{
+		Request: teetoken.Request{
+			ID: teetoken.RequestBIP85,
+		},
+		Application: app,
+		Length:      length,
+		Index:       index,
+		Session:     tt.root().session,
+	}
+
+	resp := teetoken.BIP85Response{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Secret, nil
+}
+
+// SupportedSignAlgorithms asks the applet which algorithms it signs with, and returns nil
+// if it can't be reached.
+func (tt *TEEToken) SupportedSignAlgorithms() []crypto.Algorithm {
+	req := teetoken.SupportedSignAlgorithmsRequest{
+		Request: teetoken.Request{
+			ID: teetoken.RequestSupportedSignAlgorithms,
+		},
+	}
+
+	resp := teetoken.SupportedSignAlgorithmsResponse{}
+
+	if err := doRequest(req, &resp); err != nil {
+		return nil
+	}
+
+	return resp.Algorithms
 }
 
+// doRequest sends input to the applet, and unpacks its response in output.
+// Both the marshaled request and response are wiped, since they may hold secrets.
 func doRequest(input interface{}, output interface{}) error {
 	reqBytes, err := teetoken.PackageRequest(input)
 	if err != nil {
 		return err
 	}
 
+	defer crypto.Wipe(reqBytes)
+
 	m := tztypes.Mail{
 		AppID:   info.AppletID,
 		Payload: reqBytes,
@@ -131,6 +567,8 @@ func doRequest(input interface{}, output interface{}) error {
 		return err
 	}
 
+	defer crypto.Wipe(res.Payload)
+
 	if err := teetoken.UnpackResponse(res.Payload, output); err != nil {
 		return err
 	}
@@ -3,9 +3,11 @@ package token
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
 )
 
 const (
@@ -14,6 +16,29 @@ const (
 	RequestPublicKey
 	RequestMnemonic
 	RequestSupportedSignAlgorithms
+	RequestECDH
+	RequestHasSeed
+	RequestGenerateSeed
+	RequestImportSeed
+	RequestFingerprint
+	RequestWipe
+	RequestAccountKey
+	RequestSignMessage
+	RequestSLIP39Shares
+	RequestImportSLIP39Shares
+	RequestBIP85
+	RequestProfiles
+	RequestSelectProfile
+	RequestDeleteProfile
+	RequestAttestationCSR
+	RequestSetAttestationChain
+	RequestAttestationChain
+	RequestAttest
+	RequestMeasuredRevision
+	RequestHasAnySeed
+	RequestPINStorageGet
+	RequestPINStorageSet
+	RequestPINStorageDelete
 )
 
 type Request struct {
@@ -30,20 +55,25 @@ type RandomBytesResponse struct {
 	Data []byte
 }
 
+// SignRequest signs a digest, as crypto.Token.SignDigest does.
 type SignRequest struct {
 	Request
-	Data           []byte
+	Digest         []byte
 	DerivationPath crypto.DerivationPath
+	Session        crypto.Session
 	Algorithm      crypto.Algorithm
+	Scope          crypto.ScopeID
 }
 type signRequestInternal struct {
-	Data           string
+	Digest         string
 	DerivationPath crypto.DerivationPath
+	Session        crypto.Session
 	Algorithm      crypto.Algorithm
+	Scope          crypto.ScopeID
 }
 
 func (sri signRequestInternal) Bytes() []byte {
-	b, err := base64.StdEncoding.DecodeString(sri.Data)
+	b, err := base64.StdEncoding.DecodeString(sri.Digest)
 	if err != nil {
 		panic(err)
 	}
@@ -56,9 +86,38 @@ type SignResponse struct {
 	Data []byte
 }
 
+// SignMessageRequest signs a message, as crypto.Token.SignMessage does.
+type SignMessageRequest struct {
+	Request
+	Message        []byte
+	Hash           crypto.Hash
+	DerivationPath crypto.DerivationPath
+	Session        crypto.Session
+	Algorithm      crypto.Algorithm
+	Scope          crypto.ScopeID
+}
+
+type ECDHRequest struct {
+	Request
+	PeerPublicKey  []byte
+	DerivationPath crypto.DerivationPath
+	Session        crypto.Session
+	Algorithm      crypto.Algorithm
+	KDF            crypto.KDF
+	Scope          crypto.ScopeID
+}
+
+type ECDHResponse struct {
+	Response
+	Data []byte
+}
+
 type PublicKeyRequest struct {
 	Request
 	DerivationPath crypto.DerivationPath
+	Algorithm      crypto.Algorithm
+	Session        crypto.Session
+	Scope          crypto.ScopeID
 }
 
 type PublicKeyResponse struct {
@@ -66,14 +125,219 @@ type PublicKeyResponse struct {
 	Data []byte
 }
 
-type MnemonicRequest struct {
+type AccountKeyRequest struct {
 	Request
 	DerivationPath crypto.DerivationPath
+	Session        crypto.Session
+	Scope          crypto.ScopeID
 }
 
+type AccountKeyResponse struct {
+	Response
+	Key crypto.AccountKey
+}
+
+type MnemonicRequest struct {
+	Request
+	Session crypto.Session
+}
+
+// MnemonicResponse carries the wordlist indexes of the mnemonic words, so that they're never
+// unmarshaled into strings which can't be wiped.
 type MnemonicResponse struct {
 	Response
-	Words []string
+	Indexes []uint16
+}
+
+type HasSeedRequest struct {
+	Request
+	Session crypto.Session
+}
+
+type HasSeedResponse struct {
+	Response
+	HasSeed bool
+}
+
+type HasAnySeedRequest struct {
+	Request
+}
+
+type HasAnySeedResponse struct {
+	Response
+	HasAnySeed bool
+}
+
+type GenerateSeedRequest struct {
+	Request
+	EntropyBits int
+	Session     crypto.Session
+}
+
+type GenerateSeedResponse struct {
+	Response
+}
+
+// ImportSeedRequest carries the wordlist indexes of the mnemonic words, like MnemonicResponse.
+type ImportSeedRequest struct {
+	Request
+	Indexes []uint16
+	Session crypto.Session
+}
+
+type ImportSeedResponse struct {
+	Response
+}
+
+type SLIP39SharesRequest struct {
+	Request
+	GroupThreshold int
+	Groups         []crypto.SLIP39Group
+	Session        crypto.Session
+}
+
+// SLIP39SharesResponse carries the wordlist indexes of the words of each share, per group.
+type SLIP39SharesResponse struct {
+	Response
+	Indexes [][][]uint16
+}
+
+// ImportSLIP39SharesRequest carries the wordlist indexes of the words of each share.
+type ImportSLIP39SharesRequest struct {
+	Request
+	Indexes [][]uint16
+	Session crypto.Session
+}
+
+type ImportSLIP39SharesResponse struct {
+	Response
+}
+
+type BIP85Request struct {
+	Request
+	Application crypto.BIP85Application
+	Length      uint32
+	Index       uint32
+	Session     crypto.Session
+}
+
+// BIP85Response carries the child secret formatted as ASCII text, which can be wiped unlike strings.
+type BIP85Response struct {
+	Response
+	Secret []byte
+}
+
+type ProfilesRequest struct {
+	Request
+	Session crypto.Session
+}
+
+type ProfilesResponse struct {
+	Response
+	Labels []string
+	Active string
+}
+
+type SelectProfileRequest struct {
+	Request
+	Label   string
+	Session crypto.Session
+}
+
+type SelectProfileResponse struct {
+	Response
+}
+
+type DeleteProfileRequest struct {
+	Request
+	Label   string
+	Session crypto.Session
+}
+
+type DeleteProfileResponse struct {
+	Response
+}
+
+type AttestationCSRRequest struct {
+	Request
+}
+
+type AttestationCSRResponse struct {
+	Response
+	CSR []byte
+}
+
+type SetAttestationChainRequest struct {
+	Request
+	Chain []byte
+}
+
+type SetAttestationChainResponse struct {
+	Response
+}
+
+type AttestationChainRequest struct {
+	Request
+}
+
+type AttestationChainResponse struct {
+	Response
+	Chain []byte
+}
+
+// AttestRequest signs challenge along with revision, as crypto.Attester.Attest does.
+// Revision must be the one MeasuredRevisionRequest returns.
+type AttestRequest struct {
+	Request
+	Challenge []byte
+	Revision  string
+}
+
+type AttestResponse struct {
+	Response
+	Signature []byte
+}
+
+type MeasuredRevisionRequest struct {
+	Request
+}
+
+type MeasuredRevisionResponse struct {
+	Response
+	Revision string
+}
+
+// PINStorageRequest reads, writes or deletes Key out of the PIN storage of the applet, depending on
+// its ID. Value is only used by RequestPINStorageSet.
+type PINStorageRequest struct {
+	Request
+	Key   string
+	Value []byte
+}
+
+// PINStorageResponse carries the value of the key read by RequestPINStorageGet, if Found.
+type PINStorageResponse struct {
+	Response
+	Value []byte
+	Found bool
+}
+
+type FingerprintRequest struct {
+	Request
+	Session crypto.Session
+}
+
+type FingerprintResponse struct {
+	Response
+	Data []byte
+}
+
+type WipeRequest struct {
+	Request
+}
+
+type WipeResponse struct {
+	Response
 }
 
 // SupportedSignAlgorithms doesn't have inputs
@@ -113,10 +377,54 @@ func UnpackResponse(resp []byte, dest interface{}) error {
 	return unmarshal(resp, dest)
 }
 
-func Dispatch(data []byte, t crypto.Token) ([]byte, error) {
+// useSession sets t up for session, and wipes session once t holds its own copy of it.
+// The session is held by the nonsecure world and sent along with every request, since the applet
+// is loaded anew for each of them.
+func useSession(t crypto.DeviceToken, session *crypto.Session) error {
+	defer session.Wipe()
+
+	if err := t.SetPassphrase(session.Passphrase); err != nil {
+		return err
+	}
+
+	t.UseDecoy(session.Decoy)
+	return nil
+}
+
+// scoped returns t restricted to the Scope identified by id, out of the fixed table of the applet.
+// Every key operation must carry the identifier of a scope: requests carrying none, or an unknown
+// one, are refused.
+// The nonsecure world only names the scope: it can't describe key domains of its own, nor use
+// keys out of every scope, like the ones of other coin types or algorithms.
+func scoped(t crypto.DeviceToken, id crypto.ScopeID) (crypto.Token, error) {
+	if _, err := id.Scope(); err != nil {
+		return nil, fmt.Errorf("key operations must carry a known scope, %w", err)
+	}
+
+	return crypto.NewScopedToken(t, id), nil
+}
+
+// endSession wipes the session t has been set up with.
+func endSession(t crypto.DeviceToken) {
+	_ = t.SetPassphrase(nil)
+	t.UseDecoy(false)
+}
+
+// RevisionFunc returns the revision of the nonsecure world, as measured by the Trusted OS which
+// loaded it.
+type RevisionFunc func() (string, error)
+
+// Dispatch runs the request held in data on t, and returns the marshaled response.
+// PIN storage requests run on pins, which the nonsecure world keeps its PIN verifiers and retry
+// counter in: it must be a part of the secure storage apart from the one of t.
+// Attestations are only signed for the revision returned by revision.
+// Requests may carry secrets, and responses may too: the caller should wipe both once done.
+func Dispatch(data []byte, t crypto.DeviceToken, pins storage.Storage, revision RevisionFunc) ([]byte, error) {
 	var resp []byte
 	var dispatchErr error
 
+	defer endSession(t)
+
 	reqID, err := RequestedOp(data)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read op, %w", err)
@@ -148,12 +456,44 @@ func Dispatch(data []byte, t crypto.Token) ([]byte, error) {
 			return nil, err
 		}
 
-		tt := t.Clone()
-		if err := tt.Initialize(r.DerivationPath); err != nil {
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		st, err := scoped(t, r.Scope)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := st.SignDigest(r.DerivationPath, r.Algorithm, r.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		sResp := SignResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Data: data,
+		}
+
+		resp, dispatchErr = marshal(sResp)
+	case RequestSignMessage:
+		r := SignMessageRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		st, err := scoped(t, r.Scope)
+		if err != nil {
 			return nil, err
 		}
 
-		data, err := tt.Sign(r.Bytes(), r.Algorithm)
+		data, err := st.SignMessage(r.DerivationPath, r.Algorithm, r.Hash, r.Message)
 		if err != nil {
 			return nil, err
 		}
@@ -166,18 +506,52 @@ func Dispatch(data []byte, t crypto.Token) ([]byte, error) {
 		}
 
 		resp, dispatchErr = marshal(sResp)
+	case RequestECDH:
+		r := ECDHRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		st, err := scoped(t, r.Scope)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := st.ECDH(r.DerivationPath, r.Algorithm, r.KDF, r.PeerPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.Wipe(data)
+
+		ecdhResp := ECDHResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Data: data,
+		}
+
+		resp, dispatchErr = marshal(ecdhResp)
 	case RequestPublicKey:
 		r := PublicKeyRequest{}
 		if err := json.Unmarshal(data, &r); err != nil {
 			return nil, err
 		}
 
-		tt := t.Clone()
-		if err := tt.Initialize(r.DerivationPath); err != nil {
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		st, err := scoped(t, r.Scope)
+		if err != nil {
 			return nil, err
 		}
 
-		data, err := tt.PublicKey()
+		data, err := st.PublicKey(r.DerivationPath, r.Algorithm)
 		if err != nil {
 			return nil, err
 		}
@@ -190,30 +564,490 @@ func Dispatch(data []byte, t crypto.Token) ([]byte, error) {
 		}
 
 		resp, dispatchErr = marshal(pkResp)
+	case RequestAccountKey:
+		r := AccountKeyRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		st, err := scoped(t, r.Scope)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := st.AccountKey(r.DerivationPath)
+		if err != nil {
+			return nil, err
+		}
+
+		akResp := AccountKeyResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Key: key,
+		}
+
+		resp, dispatchErr = marshal(akResp)
 	case RequestMnemonic:
 		r := MnemonicRequest{}
 		if err := json.Unmarshal(data, &r); err != nil {
 			return nil, err
 		}
 
-		tt := t.Clone()
-		if err := tt.Initialize(r.DerivationPath); err != nil {
+		if err := useSession(t, &r.Session); err != nil {
 			return nil, err
 		}
 
-		data, err := tt.Mnemonic()
+		words, err := t.Mnemonic()
 		if err != nil {
 			return nil, err
 		}
 
+		defer crypto.WipeWords(words)
+
+		indexes, err := crypto.MnemonicIndexesOf(words)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeIndexes(indexes)
+
 		mnResp := MnemonicResponse{
 			Response: Response{
 				ID: reqID,
 			},
-			Words: data,
+			Indexes: indexes,
 		}
 
 		resp, dispatchErr = marshal(mnResp)
+	case RequestHasSeed:
+		r := HasSeedRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		found, err := t.HasSeed()
+		if err != nil {
+			return nil, err
+		}
+
+		hsResp := HasSeedResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			HasSeed: found,
+		}
+
+		resp, dispatchErr = marshal(hsResp)
+	case RequestHasAnySeed:
+		found, err := t.HasAnySeed()
+		if err != nil {
+			return nil, err
+		}
+
+		hasResp := HasAnySeedResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			HasAnySeed: found,
+		}
+
+		resp, dispatchErr = marshal(hasResp)
+	case RequestGenerateSeed:
+		r := GenerateSeedRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		if err := t.GenerateSeed(r.EntropyBits); err != nil {
+			return nil, err
+		}
+
+		gsResp := GenerateSeedResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(gsResp)
+	case RequestImportSeed:
+		r := ImportSeedRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeIndexes(r.Indexes)
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		words, err := crypto.MnemonicWords(r.Indexes)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeWords(words)
+
+		if err := t.ImportSeed(words); err != nil {
+			return nil, err
+		}
+
+		isResp := ImportSeedResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(isResp)
+	case RequestSLIP39Shares:
+		r := SLIP39SharesRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		shares, err := t.SLIP39Shares(r.GroupThreshold, r.Groups)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes := make([][][]uint16, len(shares))
+		defer func() {
+			for _, group := range indexes {
+				for _, share := range group {
+					crypto.WipeIndexes(share)
+				}
+			}
+		}()
+
+		for gi, group := range shares {
+			for _, words := range group {
+				share, err := crypto.SLIP39IndexesOf(words)
+				crypto.WipeWords(words)
+
+				if err != nil {
+					return nil, err
+				}
+
+				indexes[gi] = append(indexes[gi], share)
+			}
+		}
+
+		ssResp := SLIP39SharesResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Indexes: indexes,
+		}
+
+		resp, dispatchErr = marshal(ssResp)
+	case RequestImportSLIP39Shares:
+		r := ImportSLIP39SharesRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			for _, share := range r.Indexes {
+				crypto.WipeIndexes(share)
+			}
+		}()
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		shares := make([][]string, 0, len(r.Indexes))
+		defer func() {
+			for _, words := range shares {
+				crypto.WipeWords(words)
+			}
+		}()
+
+		for _, share := range r.Indexes {
+			words, err := crypto.SLIP39Words(share)
+			if err != nil {
+				return nil, err
+			}
+
+			shares = append(shares, words)
+		}
+
+		if err := t.ImportSLIP39Shares(shares); err != nil {
+			return nil, err
+		}
+
+		isResp := ImportSLIP39SharesResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(isResp)
+	case RequestBIP85:
+		r := BIP85Request{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		secret, err := t.BIP85(r.Application, r.Length, r.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.Wipe(secret)
+
+		bResp := BIP85Response{
+			Response: Response{
+				ID: reqID,
+			},
+			Secret: secret,
+		}
+
+		resp, dispatchErr = marshal(bResp)
+	case RequestProfiles:
+		r := ProfilesRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		labels, active, err := t.Profiles()
+		if err != nil {
+			return nil, err
+		}
+
+		pResp := ProfilesResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Labels: labels,
+			Active: active,
+		}
+
+		resp, dispatchErr = marshal(pResp)
+	case RequestSelectProfile:
+		r := SelectProfileRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		if err := t.SelectProfile(r.Label); err != nil {
+			return nil, err
+		}
+
+		spResp := SelectProfileResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(spResp)
+	case RequestDeleteProfile:
+		r := DeleteProfileRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		if err := t.DeleteProfile(r.Label); err != nil {
+			return nil, err
+		}
+
+		dpResp := DeleteProfileResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(dpResp)
+	case RequestAttestationCSR:
+		csr, err := t.AttestationCSR()
+		if err != nil {
+			return nil, err
+		}
+
+		acResp := AttestationCSRResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			CSR: csr,
+		}
+
+		resp, dispatchErr = marshal(acResp)
+	case RequestSetAttestationChain:
+		r := SetAttestationChainRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := t.SetAttestationChain(r.Chain); err != nil {
+			return nil, err
+		}
+
+		sacResp := SetAttestationChainResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(sacResp)
+	case RequestAttestationChain:
+		chain, err := t.AttestationChain()
+		if err != nil {
+			return nil, err
+		}
+
+		chResp := AttestationChainResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Chain: chain,
+		}
+
+		resp, dispatchErr = marshal(chResp)
+	case RequestAttest:
+		r := AttestRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		measured, err := revision()
+		if err != nil {
+			return nil, fmt.Errorf("cannot measure nonsecure world, %w", err)
+		}
+
+		if r.Revision != measured {
+			return nil, fmt.Errorf("revision %q isn't the one of the nonsecure world", r.Revision)
+		}
+
+		signature, err := t.Attest(r.Challenge, measured)
+		if err != nil {
+			return nil, err
+		}
+
+		aResp := AttestResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Signature: signature,
+		}
+
+		resp, dispatchErr = marshal(aResp)
+	case RequestMeasuredRevision:
+		measured, err := revision()
+		if err != nil {
+			return nil, fmt.Errorf("cannot measure nonsecure world, %w", err)
+		}
+
+		mrResp := MeasuredRevisionResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Revision: measured,
+		}
+
+		resp, dispatchErr = marshal(mrResp)
+	case RequestPINStorageGet, RequestPINStorageSet, RequestPINStorageDelete:
+		r := PINStorageRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		defer crypto.Wipe(r.Value)
+
+		psResp := PINStorageResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		switch reqID {
+		case RequestPINStorageGet:
+			value, err := pins.Get(r.Key)
+			switch {
+			case err == nil:
+				defer crypto.Wipe(value)
+				psResp.Value, psResp.Found = value, true
+			case !errors.Is(err, storage.ErrNotFound):
+				return nil, err
+			}
+		case RequestPINStorageSet:
+			if err := pins.Set(r.Key, r.Value); err != nil {
+				return nil, err
+			}
+		default:
+			if err := pins.Delete(r.Key); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, dispatchErr = marshal(psResp)
+	case RequestFingerprint:
+		r := FingerprintRequest{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+
+		if err := useSession(t, &r.Session); err != nil {
+			return nil, err
+		}
+
+		fp, err := t.Fingerprint()
+		if err != nil {
+			return nil, err
+		}
+
+		fpResp := FingerprintResponse{
+			Response: Response{
+				ID: reqID,
+			},
+			Data: fp,
+		}
+
+		resp, dispatchErr = marshal(fpResp)
+	case RequestWipe:
+		if err := t.Wipe(); err != nil {
+			return nil, err
+		}
+
+		wResp := WipeResponse{
+			Response: Response{
+				ID: reqID,
+			},
+		}
+
+		resp, dispatchErr = marshal(wResp)
 	case RequestSupportedSignAlgorithms:
 		data := t.SupportedSignAlgorithms()
 
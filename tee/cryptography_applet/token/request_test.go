@@ -0,0 +1,152 @@
+package token
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+var testRevision = crypto.ImageRevision([]byte("nonsecure os"))
+
+func measure() (string, error) {
+	return testRevision, nil
+}
+
+func seededToken(t *testing.T) crypto.DeviceToken {
+	t.Helper()
+
+	dt := crypto.NewDumbToken(storage.NewMemory())
+	require.NoError(t, dt.ImportSeed(strings.Fields(testMnemonic)))
+
+	return dt
+}
+
+func dispatch(t *testing.T, dt crypto.DeviceToken, req interface{}, resp interface{}) error {
+	t.Helper()
+
+	data, err := PackageRequest(req)
+	require.NoError(t, err)
+
+	raw, err := Dispatch(data, dt, storage.NewMemory(), measure)
+	if err != nil {
+		return err
+	}
+
+	require.NoError(t, UnpackResponse(raw, resp))
+	return nil
+}
+
+func TestDispatchSignScope(t *testing.T) {
+	dt := seededToken(t)
+	digest := sha256.Sum256([]byte("message"))
+
+	sign := func(path crypto.DerivationPath, scope crypto.ScopeID) error {
+		return dispatch(t, dt, SignRequest{
+			Request:        Request{ID: RequestSign},
+			Digest:         digest[:],
+			DerivationPath: path,
+			Algorithm:      crypto.AlgoSecp256K1,
+			Scope:          scope,
+		}, &SignResponse{})
+	}
+
+	require.NoError(t, sign(crypto.BIP44Path(118, 0, 0, 0), crypto.ScopeCosmos))
+
+	// out of scope
+	require.Error(t, sign(crypto.BIP44Path(1237, 0, 0, 0), crypto.ScopeCosmos))
+
+	// key operations must carry a scope
+	require.Error(t, sign(crypto.BIP44Path(118, 0, 0, 0), crypto.ScopeNone))
+}
+
+func TestDispatchECDHScope(t *testing.T) {
+	dt := seededToken(t)
+
+	peer, err := dt.PublicKey(crypto.BIP44Path(118, 1, 0, 0), crypto.AlgoSecp256K1)
+	require.NoError(t, err)
+
+	ecdh := func(path crypto.DerivationPath, algorithm crypto.Algorithm, scope crypto.ScopeID) error {
+		return dispatch(t, dt, ECDHRequest{
+			Request:        Request{ID: RequestECDH},
+			PeerPublicKey:  peer,
+			DerivationPath: path,
+			Algorithm:      algorithm,
+			KDF:            crypto.KDF{Algorithm: crypto.KDFSHA256},
+			Scope:          scope,
+		}, &ECDHResponse{})
+	}
+
+	require.NoError(t, ecdh(crypto.BIP44Path(118, 0, 0, 0), crypto.AlgoSecp256K1, crypto.ScopeCosmos))
+	require.Error(t, ecdh(crypto.BIP44Path(6383461, 0, 0, 0), crypto.AlgoSecp256K1, crypto.ScopeCosmos))
+	require.Error(t, ecdh(crypto.BIP44Path(118, 0, 0, 0), crypto.AlgoX25519, crypto.ScopeCosmos))
+	require.Error(t, ecdh(crypto.BIP44Path(118, 0, 0, 0), crypto.AlgoSecp256K1, crypto.ScopeNone))
+}
+
+func TestDispatchPublicKeyScope(t *testing.T) {
+	dt := seededToken(t)
+
+	publicKey := func(path crypto.DerivationPath, scope crypto.ScopeID) error {
+		return dispatch(t, dt, PublicKeyRequest{
+			Request:        Request{ID: RequestPublicKey},
+			DerivationPath: path,
+			Algorithm:      crypto.AlgoSecp256K1,
+			Scope:          scope,
+		}, &PublicKeyResponse{})
+	}
+
+	require.NoError(t, publicKey(crypto.BIP44Path(118, 0, 0, 0), crypto.ScopeCosmos))
+	require.Error(t, publicKey(crypto.BIP44Path(1237, 0, 0, 0), crypto.ScopeCosmos))
+
+	// public keys are never handed out of scope
+	require.Error(t, publicKey(crypto.BIP44Path(1237, 0, 0, 0), crypto.ScopeNone))
+	require.Error(t, publicKey(crypto.BIP44Path(118, 0, 0, 0), crypto.ScopeAccountExport+1))
+}
+
+func TestDispatchAccountKeyScope(t *testing.T) {
+	dt := seededToken(t)
+
+	accountKey := func(path crypto.DerivationPath, scope crypto.ScopeID) error {
+		return dispatch(t, dt, AccountKeyRequest{
+			Request:        Request{ID: RequestAccountKey},
+			DerivationPath: path,
+			Scope:          scope,
+		}, &AccountKeyResponse{})
+	}
+
+	bip84 := crypto.DerivationPath{crypto.Hardened(84), crypto.Hardened(0), crypto.Hardened(0)}
+
+	require.NoError(t, accountKey(bip84, crypto.ScopeAccountExport))
+	require.Error(t, accountKey(bip84, crypto.ScopeCosmos))
+	require.Error(t, accountKey(bip84, crypto.ScopeNone))
+}
+
+func TestDispatchAttestMeasuredRevision(t *testing.T) {
+	dt := seededToken(t)
+
+	resp := MeasuredRevisionResponse{}
+	require.NoError(t, dispatch(t, dt, MeasuredRevisionRequest{
+		Request: Request{ID: RequestMeasuredRevision},
+	}, &resp))
+	require.Equal(t, testRevision, resp.Revision)
+
+	attest := func(revision string) error {
+		return dispatch(t, dt, AttestRequest{
+			Request:   Request{ID: RequestAttest},
+			Challenge: make([]byte, crypto.MinChallengeSize),
+			Revision:  revision,
+		}, &AttestResponse{})
+	}
+
+	err := attest("v1.0.0")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, crypto.ErrNotProvisioned)
+
+	// the measured revision gets through to the token, which isn't provisioned
+	require.ErrorIs(t, attest(testRevision), crypto.ErrNotProvisioned)
+}
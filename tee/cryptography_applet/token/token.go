@@ -1,41 +1,46 @@
 package token
 
 import (
-	"crypto/hmac"
-	"crypto/rand"
-	"crypto/sha256"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"fmt"
-	"strings"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcutil/hdkeychain"
-	"github.com/cosmos/go-bip39"
 	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/entropy"
+	"github.com/wallera-computer/wallera/storage"
+	"golang.org/x/crypto/curve25519"
 )
 
 // Compile-time check which fails if Token doesn't comply with
-// crypto.Token interface.
-var _ crypto.Token = (*Token)(nil)
-
-var defaultEntropy = []byte{
-	118, 252, 209, 103,
-	94, 240, 60, 245,
-	18, 224, 156, 240,
-	11, 232, 52, 25,
-	31, 134, 125, 135,
-	192, 2, 31, 206,
-	216, 100, 159, 234,
-	150, 9, 236, 57,
-}
+// crypto.DeviceToken interface.
+var _ crypto.DeviceToken = (*Token)(nil)
 
+// Token keeps no key material between operations: seeds and keys are derived in buffers owned by
+// each operation, which wipes them before returning.
+// Only its session, holding the passphrase, and its KeyCache outlive operations.
 type Token struct {
-	privKey *hdkeychain.ExtendedKey
+	storage storage.Storage
+	session crypto.Session
+
+	cache *crypto.KeyCache
 }
 
-// NewToken returns a new instance of Token.
-// Callers should Clone() this instance and then call Initialize().
-func NewToken() crypto.Token {
-	return &Token{}
+// NewToken returns a new instance of Token, which keeps its seed in s.
+func NewToken(s storage.Storage) crypto.DeviceToken {
+	cache, err := crypto.NewKeyCache(crypto.DefaultKeyCacheSize)
+	if err != nil {
+		// only fails if the random number generator does
+		panic(err)
+	}
+
+	return &Token{
+		storage: s,
+		cache:   cache,
+	}
 }
 
 func (dt *Token) RandomBytes(amount uint64) ([]byte, error) {
@@ -44,7 +49,7 @@ func (dt *Token) RandomBytes(amount uint64) ([]byte, error) {
 	}
 
 	b := make([]byte, amount)
-	_, err := rand.Read(b)
+	_, err := entropy.Read(b)
 	if err != nil {
 		return nil, err
 	}
@@ -52,94 +57,452 @@ func (dt *Token) RandomBytes(amount uint64) ([]byte, error) {
 	return b, nil
 }
 
-func (dt *Token) DeriveSecret() ([32]byte, error) {
-	h := hmac.New(sha256.New, crypto.Diversifier())
-	if _, err := h.Write(defaultEntropy); err != nil {
-		return [32]byte{}, fmt.Errorf("cannot generate secret, %w", err)
+func (dt *Token) HasSeed() (bool, error) {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return false, err
 	}
 
-	res := h.Sum(nil)
-	if res == nil {
-		panic(fmt.Errorf("hmac.SHA256 sum result empty"))
-	}
+	return crypto.HasSeed(s)
+}
+
+func (dt *Token) HasAnySeed() (bool, error) {
+	return crypto.AnySeed(dt.storage)
+}
 
-	ret := [32]byte{}
-	copy(ret[:], res)
+func (dt *Token) GenerateSeed(entropyBits int) error {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return err
+	}
 
-	return ret, nil
+	return crypto.GenerateSeed(dt, s, entropyBits)
 }
 
-func (dt *Token) Initialize(path crypto.DerivationPath) error {
-	secret, err := dt.DeriveSecret()
+func (dt *Token) ImportSeed(words []string) error {
+	s, err := dt.seedStorage()
 	if err != nil {
 		return err
 	}
 
-	params := chaincfg.MainNetParams
-	params.HDCoinType = path.CoinType
+	dt.cache.Purge()
+	return crypto.ImportSeed(s, words)
+}
 
-	sb, err := hdkeychain.NewMaster(secret[:], &params)
+func (dt *Token) ImportSLIP39Shares(shares [][]string) error {
+	s, err := dt.seedStorage()
 	if err != nil {
 		return err
 	}
 
-	dt.privKey, err = crypto.KeyFromPath(sb, path)
+	dt.cache.Purge()
+	return crypto.ImportSLIP39Seed(s, shares)
+}
+
+func (dt *Token) Profiles() ([]string, string, error) {
+	s := crypto.SeedStorage(dt.storage, dt.session)
+
+	labels, err := crypto.Profiles(s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	active, err := crypto.ActiveProfile(s)
 	if err != nil {
+		return nil, "", err
+	}
+
+	return labels, active, nil
+}
+
+func (dt *Token) SelectProfile(label string) error {
+	if err := crypto.SelectProfile(crypto.SeedStorage(dt.storage, dt.session), label); err != nil {
+		return err
+	}
+
+	// passphrases select wallets of the seed they have been set for
+	crypto.Wipe(dt.session.Passphrase)
+	dt.session.Passphrase = nil
+	return nil
+}
+
+func (dt *Token) DeleteProfile(label string) error {
+	dt.cache.Purge()
+	return crypto.DeleteProfile(crypto.SeedStorage(dt.storage, dt.session), label)
+}
+
+func (dt *Token) SetPassphrase(passphrase []byte) error {
+	if err := crypto.ValidPassphrase(passphrase); err != nil {
 		return err
 	}
 
+	crypto.Wipe(dt.session.Passphrase)
+	dt.session.Passphrase = append([]byte{}, passphrase...)
 	return nil
 }
 
-func (dt *Token) Sign(data []byte, algorithm crypto.Algorithm) ([]byte, error) {
-	pk, err := dt.privKey.ECPrivKey()
+func (dt *Token) UseDecoy(decoy bool) {
+	dt.session.Decoy = decoy
+}
+
+func (dt *Token) Wipe() error {
+	dt.session.Wipe()
+	dt.cache.Purge()
+	return crypto.WipeSeed(dt.storage)
+}
+
+// seedStorage returns the storage holding the seed of the active profile, selected by the current session.
+func (dt *Token) seedStorage() (storage.Storage, error) {
+	return crypto.ActiveProfileStorage(crypto.SeedStorage(dt.storage, dt.session))
+}
+
+// readSeed returns the seed of the active profile, selected by the current session.
+// The caller must wipe it once done.
+func (dt *Token) readSeed() (crypto.Seed, error) {
+	s, err := dt.seedStorage()
+	if err != nil {
+		return crypto.Seed{}, err
+	}
+
+	return crypto.ReadSeed(s)
+}
+
+func (dt *Token) Fingerprint() ([]byte, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.Wipe(seed)
+
+	return crypto.Fingerprint(seed)
+}
+
+// masterSeed returns the BIP-32 seed of the device, for the current passphrase.
+// The caller must wipe it once done.
+func (dt *Token) masterSeed() ([]byte, error) {
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	return dt.cache.MasterSeed(seed, dt.session.Passphrase)
+}
+
+// key returns the secp256k1 extended key at path.
+// The caller must wipe it once done.
+func (dt *Token) key(path crypto.DerivationPath) (*hdkeychain.ExtendedKey, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.Wipe(seed)
+
+	return dt.cache.Derive(seed, path)
+}
+
+// ecKey returns the secp256k1 private key at path.
+// The caller must wipe it once done.
+func (dt *Token) ecKey(path crypto.DerivationPath) (*btcec.PrivateKey, error) {
+	key, err := dt.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.WipeExtendedKey(key)
+
+	return key.ECPrivKey()
+}
+
+func (dt *Token) SignDigest(path crypto.DerivationPath, algorithm crypto.Algorithm, digest []byte) ([]byte, error) {
+	if err := crypto.CheckDigest(algorithm, digest); err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case crypto.AlgoSecp256K1, crypto.AlgoSecp256K1Schnorr:
+		pk, err := dt.ecKey(path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeECPrivateKey(pk)
+
+		publicKey := pk.PubKey().SerializeCompressed()
+
+		if algorithm == crypto.AlgoSecp256K1Schnorr {
+			aux, err := dt.RandomBytes(32)
+			if err != nil {
+				return nil, err
+			}
+
+			signature, err := crypto.SignSchnorr(pk, digest, aux)
+			if err != nil {
+				return nil, err
+			}
+
+			return crypto.ReleaseSignature(algorithm, publicKey, digest, signature)
+		}
+
+		signature, err := pk.Sign(digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return crypto.ReleaseSignature(algorithm, publicKey, digest, signature.Serialize())
+	case crypto.AlgoP256:
+		seed, err := dt.masterSeed()
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.Wipe(seed)
+
+		key, err := crypto.SLIP10P256Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeECDSAPrivateKey(key)
+
+		signature, err := ecdsa.SignASN1(entropy.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey := elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+
+		return crypto.ReleaseSignature(algorithm, publicKey, digest, signature)
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm %v", algorithm)
+	}
+}
+
+func (dt *Token) SignMessage(path crypto.DerivationPath, algorithm crypto.Algorithm, hash crypto.Hash, message []byte) ([]byte, error) {
+	digest, err := crypto.MessageDigest(algorithm, hash, message)
 	if err != nil {
 		return nil, err
 	}
 
-	signature, err := pk.Sign(data)
+	if digest != nil {
+		return dt.SignDigest(path, algorithm, digest)
+	}
+
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.Wipe(seed)
+
+	key, err := crypto.SLIP10Ed25519Key(seed, path)
 	if err != nil {
 		return nil, err
 	}
 
-	return signature.Serialize(), nil
+	defer crypto.WipeEd25519PrivateKey(key)
+
+	return crypto.ReleaseSignature(algorithm, key.Public().(ed25519.PublicKey), message, ed25519.Sign(key, message))
 }
 
-func (dt *Token) PublicKey() ([]byte, error) {
-	epubk, err := dt.privKey.Neuter()
+func (dt *Token) ECDH(path crypto.DerivationPath, algorithm crypto.Algorithm, kdf crypto.KDF, peerPublicKey []byte) ([]byte, error) {
+	if err := kdf.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := dt.key(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.WipeExtendedKey(key)
+
+	var secret []byte
+
+	switch algorithm {
+	case crypto.AlgoSecp256K1:
+		secret, err = crypto.SharedPoint(key, peerPublicKey)
+	case crypto.AlgoX25519:
+		secret, err = crypto.X25519SharedSecret(key, peerPublicKey)
+	default:
+		return nil, fmt.Errorf("unsupported ECDH algorithm %v", algorithm)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	pp, err := epubk.ECPubKey()
+	defer crypto.Wipe(secret)
+
+	return kdf.Derive(algorithm, secret)
+}
+
+func (dt *Token) PublicKey(path crypto.DerivationPath, algorithm crypto.Algorithm) ([]byte, error) {
+	seed, err := dt.masterSeed()
 	if err != nil {
 		return nil, err
 	}
 
-	return pp.SerializeCompressed(), nil
+	defer crypto.Wipe(seed)
+
+	switch algorithm {
+	case crypto.AlgoSecp256K1, crypto.AlgoSecp256K1Schnorr:
+		key, err := dt.cache.Derive(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeExtendedKey(key)
+
+		pp, err := key.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+
+		return pp.SerializeCompressed(), nil
+	case crypto.AlgoX25519:
+		key, err := dt.cache.Derive(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeExtendedKey(key)
+
+		return crypto.X25519SharedSecret(key, curve25519.Basepoint)
+	case crypto.AlgoEd25519:
+		key, err := crypto.SLIP10Ed25519Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeEd25519PrivateKey(key)
+
+		return append([]byte{}, key.Public().(ed25519.PublicKey)...), nil
+	case crypto.AlgoP256:
+		key, err := crypto.SLIP10P256Key(seed, path)
+		if err != nil {
+			return nil, err
+		}
+
+		defer crypto.WipeECDSAPrivateKey(key)
+
+		return elliptic.MarshalCompressed(key.Curve, key.X, key.Y), nil
+	default:
+		return nil, fmt.Errorf("unsupported public key algorithm %v", algorithm)
+	}
+}
+
+func (dt *Token) AccountKey(path crypto.DerivationPath) (crypto.AccountKey, error) {
+	seed, err := dt.masterSeed()
+	if err != nil {
+		return crypto.AccountKey{}, err
+	}
+
+	defer crypto.Wipe(seed)
+
+	return crypto.NewAccountKey(seed, path)
 }
 
 func (dt *Token) Mnemonic() ([]string, error) {
-	secret, err := dt.DeriveSecret()
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	if seed.Type != crypto.SeedBIP39 {
+		return nil, fmt.Errorf("seeds recovered from SLIP-39 shares have no BIP-39 mnemonic")
+	}
+
+	indexes, err := crypto.MnemonicIndexes(seed.Entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.WipeIndexes(indexes)
+
+	return crypto.MnemonicWords(indexes)
+}
+
+func (dt *Token) SLIP39Shares(groupThreshold int, groups []crypto.SLIP39Group) ([][][]string, error) {
+	seed, err := dt.readSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	defer seed.Wipe()
+
+	// SLIP-39 shares of BIP-39 entropy would restore another wallet on any other SLIP-39 device
+	if seed.Type != crypto.SeedSLIP39 {
+		return nil, fmt.Errorf("BIP-39 seeds can't be split into SLIP-39 shares, back up their mnemonic instead")
+	}
+
+	return crypto.SLIP39SplitEncrypted(entropy.Reader, seed.SLIP39, groupThreshold, groups)
+}
+
+func (dt *Token) BIP85(app crypto.BIP85Application, length, index uint32) ([]byte, error) {
+	path, err := crypto.BIP85Path(app, length, index)
 	if err != nil {
 		return nil, err
 	}
 
-	mnemonic, err := bip39.NewMnemonic(secret[:])
+	seed, err := dt.masterSeed()
 	if err != nil {
 		return nil, err
 	}
 
-	return strings.Split(mnemonic, " "), nil
+	defer crypto.Wipe(seed)
+
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.WipeExtendedKey(master)
+
+	// child secrets are derived outside of the KeyCache, which would keep their nodes around
+	key, err := crypto.KeyFromPath(master, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.WipeExtendedKey(key)
+
+	entropy, err := crypto.BIP85Entropy(key)
+	if err != nil {
+		return nil, err
+	}
+
+	defer crypto.Wipe(entropy)
+
+	return crypto.BIP85Secret(app, length, entropy)
 }
 
 func (dt *Token) SupportedSignAlgorithms() []crypto.Algorithm {
 	return []crypto.Algorithm{
 		crypto.AlgoSecp256K1,
+		crypto.AlgoSecp256K1Schnorr,
+		crypto.AlgoEd25519,
+		crypto.AlgoP256,
 	}
 }
 
-func (dt *Token) Clone() crypto.Token {
-	cl := *dt
-	return &cl
+func (dt *Token) AttestationCSR() ([]byte, error) {
+	return crypto.AttestationCSR(dt.storage)
+}
+
+func (dt *Token) SetAttestationChain(chain []byte) error {
+	return crypto.SetAttestationChain(dt.storage, chain)
+}
+
+func (dt *Token) AttestationChain() ([]byte, error) {
+	return crypto.AttestationChain(dt.storage)
+}
+
+func (dt *Token) Attest(challenge []byte, revision string) ([]byte, error) {
+	return crypto.Attest(dt.storage, challenge, revision)
 }
@@ -67,13 +67,6 @@ func mnemonic() error {
 		Request: token.Request{
 			ID: token.RequestMnemonic,
 		},
-		DerivationPath: crypto.DerivationPath{
-			Purpose:      44,
-			CoinType:     118,
-			Account:      0,
-			Change:       0,
-			AddressIndex: 0,
-		},
 	}
 
 	resp := token.MnemonicResponse{}
@@ -82,7 +75,14 @@ func mnemonic() error {
 		return err
 	}
 
-	l.Debugw("generated mnemonic", "words", resp.Words)
+	defer crypto.WipeIndexes(resp.Indexes)
+
+	words, err := crypto.MnemonicWords(resp.Indexes)
+	if err != nil {
+		return err
+	}
+
+	l.Debugw("generated mnemonic", "words", words)
 
 	return nil
 }
@@ -92,13 +92,7 @@ func pubkey() error {
 		Request: token.Request{
 			ID: token.RequestPublicKey,
 		},
-		DerivationPath: crypto.DerivationPath{
-			Purpose:      44,
-			CoinType:     118,
-			Account:      0,
-			Change:       0,
-			AddressIndex: 0,
-		},
+		DerivationPath: crypto.BIP44Path(118, 0, 0, 0),
 	}
 
 	resp := token.PublicKeyResponse{}
@@ -139,15 +133,9 @@ func sign() error {
 		Request: token.Request{
 			ID: token.RequestSign,
 		},
-		Data: hs[:],
-		DerivationPath: crypto.DerivationPath{
-			Purpose:      44,
-			CoinType:     118,
-			Account:      0,
-			Change:       0,
-			AddressIndex: 0,
-		},
-		Algorithm: crypto.AlgoSecp256K1,
+		Digest:         hs[:],
+		DerivationPath: crypto.BIP44Path(118, 0, 0, 0),
+		Algorithm:      crypto.AlgoSecp256K1,
 	}
 
 	resp := token.SignResponse{}
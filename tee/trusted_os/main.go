@@ -17,6 +17,7 @@ import (
 	"github.com/f-secure-foundry/tamago/soc/imx6/dcp"
 	"go.uber.org/zap"
 
+	"github.com/wallera-computer/wallera/entropy"
 	"github.com/wallera-computer/wallera/log"
 	"github.com/wallera-computer/wallera/tee/cryptography_applet/info"
 	"github.com/wallera-computer/wallera/tee/mem"
@@ -63,7 +64,18 @@ func init() {
 func main() {
 
 	defer panicHandler()
-	tzCtx := tz.NewContext()
+
+	// SYS_GETRANDOM is served by the entropy service
+	if err := entropy.SelfTest(); err != nil {
+		panic(err)
+	}
+
+	s, err := secureStorage()
+	if err != nil {
+		panic(err)
+	}
+
+	tzCtx := tz.NewContext(s)
 
 	if err := tzCtx.RegisterApp(taELF, info.AppletID); err != nil {
 		panic(err)
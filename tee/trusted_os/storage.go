@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/aes"
+
+	usbarmory "github.com/f-secure-foundry/tamago/board/f-secure/usbarmory/mark-two"
+	"github.com/f-secure-foundry/tamago/soc/imx6"
+	"github.com/f-secure-foundry/tamago/soc/imx6/dcp"
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/storage"
+)
+
+const (
+	// secureStorageBlocks is the amount of blocks reserved for the secure storage on the internal eMMC,
+	// right before the ones used by the Nonsecure World.
+	secureStorageBlocks = 64
+
+	nonsecureStorageBlocks = 64
+)
+
+// secureStorage returns the storage.Storage made available to trusted applets.
+// Its content is encrypted with a key derived from the SoC unique key, so that it
+// can only be read back by the device which wrote it.
+func secureStorage() (storage.Storage, error) {
+	// no eMMC nor unique key when emulated
+	if !imx6.Native {
+		return storage.NewMemory(), nil
+	}
+
+	dcp.Init()
+
+	key, err := dcp.DeriveKey(crypto.Diversifier()[:aes.BlockSize], make([]byte, aes.BlockSize), -1)
+	if err != nil {
+		return nil, err
+	}
+
+	card := usbarmory.MMC
+
+	card.Init(usbarmory.MMC_BUS_WIDTH)
+	if err := card.Detect(); err != nil {
+		return nil, err
+	}
+
+	info := card.Info()
+
+	s, err := storage.NewBlock(card, info.Blocks-nonsecureStorageBlocks-secureStorageBlocks, secureStorageBlocks, info.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewEncrypted(s, key)
+}
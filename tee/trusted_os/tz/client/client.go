@@ -12,6 +12,9 @@ import (
 	tztypes "github.com/wallera-computer/wallera/tee/trusted_os/tz/types"
 )
 
+// SYS_WAIT_MAIL suspends the calling trusted applet until the Trusted OS delivers its next mail.
+const SYS_WAIT_MAIL = 667
+
 type rpcCallFunc func(serviceMethod string, args interface{}, reply interface{}) error
 
 func callRPC(callFunc rpcCallFunc, funcName string, arg, dest interface{}) error {
@@ -45,6 +48,24 @@ func (s SecureRPC) WriteResponse(mail tztypes.Mail) error {
 	)
 }
 
+// NonsecureRevision returns the measurement of the nonsecure world image, taken by the Trusted OS
+// when loading it.
+func (s SecureRPC) NonsecureRevision() (string, error) {
+	var revision string
+	return revision, callRPC(
+		syscall.Call,
+		"SecureRPC.NonsecureRevision",
+		uint(0),
+		&revision,
+	)
+}
+
+// WaitMail suspends the trusted applet until its next mail: the Trusted OS resumes it as it left it,
+// instead of loading it anew.
+func WaitMail() {
+	syscall.Write(SYS_WAIT_MAIL, nil, 0)
+}
+
 type NonsecureRPC struct{}
 
 func (ns NonsecureRPC) SendMail(mail tztypes.Mail) error {
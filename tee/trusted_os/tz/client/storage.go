@@ -0,0 +1,52 @@
+package client
+
+import (
+	"github.com/f-secure-foundry/GoTEE/syscall"
+	"github.com/wallera-computer/wallera/storage"
+	tztypes "github.com/wallera-computer/wallera/tee/trusted_os/tz/types"
+)
+
+// Compile-time check which fails if SecureStorage doesn't comply with
+// storage.Storage interface.
+var _ storage.Storage = SecureStorage{}
+
+// SecureStorage is a storage.Storage backed by the Trusted OS, only available to trusted applets.
+type SecureStorage struct{}
+
+func (s SecureStorage) Get(key string) ([]byte, error) {
+	var value []byte
+	err := callRPC(
+		syscall.Call,
+		"SecureRPC.StorageGet",
+		key,
+		&value,
+	)
+
+	// errors lose their identity when crossing the RPC boundary
+	if err != nil && err.Error() == storage.ErrNotFound.Error() {
+		return nil, storage.ErrNotFound
+	}
+
+	return value, err
+}
+
+func (s SecureStorage) Set(key string, value []byte) error {
+	return callRPC(
+		syscall.Call,
+		"SecureRPC.StorageSet",
+		tztypes.StorageEntry{
+			Key:   key,
+			Value: value,
+		},
+		nil,
+	)
+}
+
+func (s SecureStorage) Delete(key string) error {
+	return callRPC(
+		syscall.Call,
+		"SecureRPC.StorageDelete",
+		key,
+		nil,
+	)
+}
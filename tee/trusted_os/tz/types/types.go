@@ -9,3 +9,9 @@ func (m *Mail) CopyFrom(o Mail) {
 	m.AppID = o.AppID
 	m.Payload = o.Payload
 }
+
+// StorageEntry is a key-value pair written to the Trusted OS secure storage.
+type StorageEntry struct {
+	Key   string
+	Value []byte
+}
@@ -22,7 +22,10 @@ import (
 	"github.com/f-secure-foundry/tamago/soc/imx6/tzasc"
 	"go.uber.org/zap"
 
+	"github.com/wallera-computer/wallera/crypto"
+	"github.com/wallera-computer/wallera/entropy"
 	"github.com/wallera-computer/wallera/log"
+	"github.com/wallera-computer/wallera/storage"
 	"github.com/wallera-computer/wallera/tee/mem"
 	"github.com/wallera-computer/wallera/tee/trusted_os/tz/client"
 	"github.com/wallera-computer/wallera/tee/trusted_os/tz/types"
@@ -34,6 +37,7 @@ var ErrNoResult = errors.New("no result")
 var ErrMailboxFull = errors.New("mailbox full")
 var ErrResultBoxFull = errors.New("result box full")
 var ErrTAExit = errors.New("ta exit")
+var ErrTAWaiting = errors.New("ta waiting for mail")
 var ErrNonsecureExit = errors.New("nonsecure exit")
 
 var l *zap.SugaredLogger = log.Development().Sugar()
@@ -62,6 +66,39 @@ func (srpc *SecureRPC) WriteResponse(response types.Mail, _ *struct{}) error {
 	return nil
 }
 
+// NonsecureRevision returns the measurement of the nonsecure world image, which attestations sign.
+func (srpc *SecureRPC) NonsecureRevision(_ uint, out *string) error {
+	if srpc.ctx.NonsecureRevision == "" {
+		return fmt.Errorf("nonsecure world hasn't been loaded")
+	}
+
+	*out = srpc.ctx.NonsecureRevision
+
+	return nil
+}
+
+// StorageGet returns the value of key in the secure storage.
+func (srpc *SecureRPC) StorageGet(key string, out *[]byte) error {
+	value, err := srpc.ctx.Storage.Get(key)
+	if err != nil {
+		return err
+	}
+
+	*out = value
+
+	return nil
+}
+
+// StorageSet writes entry in the secure storage.
+func (srpc *SecureRPC) StorageSet(entry types.StorageEntry, _ *struct{}) error {
+	return srpc.ctx.Storage.Set(entry.Key, entry.Value)
+}
+
+// StorageDelete deletes key from the secure storage.
+func (srpc *SecureRPC) StorageDelete(key string, _ *struct{}) error {
+	return srpc.ctx.Storage.Delete(key)
+}
+
 type NonsecureRPC struct {
 	ctx *Context
 }
@@ -85,14 +122,27 @@ type Context struct {
 	Apps           map[uint]*exec.ELFImage
 	NonsecureWorld *monitor.ExecCtx
 
+	// Storage is only available to trusted applets, through SecureRPC.
+	Storage storage.Storage
+
+	// NonsecureRevision is the measurement of the image LoadNonsecureWorld loaded, as
+	// crypto.ImageRevision formats it.
+	NonsecureRevision string
+
+	// running holds the trusted applets waiting for their next mail, which are resumed rather
+	// than loaded anew, so that their state outlives a single mail.
+	running map[uint]*monitor.ExecCtx
+
 	mailbox   sync.Map
 	resultBox sync.Map
 }
 
-func NewContext() *Context {
+func NewContext(s storage.Storage) *Context {
 	return &Context{
 		Apps:           map[uint]*exec.ELFImage{},
+		Storage:        s,
 		NonsecureWorld: &monitor.ExecCtx{},
+		running:        map[uint]*monitor.ExecCtx{},
 		mailbox:        sync.Map{},
 	}
 }
@@ -229,6 +279,7 @@ func (c *Context) LoadNonsecureWorld(appContent []byte) error {
 	os.Debug = true
 
 	c.NonsecureWorld = os
+	c.NonsecureRevision = crypto.ImageRevision(appContent)
 
 	return nil
 }
@@ -244,12 +295,20 @@ func (c *Context) dispatchAppsCalls() {
 			panic("somehow a mailbox key isn't uint")
 		}
 
-		ta, err := c.loadTA(key)
-		if err != nil {
-			panic(fmt.Errorf("cannot load ta %v, %w", key, err))
+		ta, found := c.running[key]
+		if !found {
+			var err error
+			if ta, err = c.loadTA(key); err != nil {
+				panic(fmt.Errorf("cannot load ta %v, %w", key, err))
+			}
+
+			c.running[key] = ta
 		}
 
-		run(ta)
+		// applets which exited are loaded anew on their next mail
+		if err := run(ta); !errors.Is(err, ErrTAWaiting) {
+			delete(c.running, key)
+		}
 
 		return true
 	})
@@ -393,7 +452,8 @@ func grantPeripheralAccess() (err error) {
 	return
 }
 
-func run(ctx *monitor.ExecCtx) {
+// run runs ctx until it exits, or waits for its next mail, and returns the error it stopped with.
+func run(ctx *monitor.ExecCtx) error {
 	mode := arm.ModeName(int(ctx.SPSR) & 0x1f)
 	ns := ctx.NonSecure()
 
@@ -407,7 +467,7 @@ func run(ctx *monitor.ExecCtx) {
 		errTemplate = ErrNonsecureExit
 	}
 
-	if err != nil && !errors.Is(err, errTemplate) {
+	if err != nil && !errors.Is(err, errTemplate) && !errors.Is(err, ErrTAWaiting) {
 		ce := client.ClientPanic{}
 		if errors.As(err, &ce) {
 			panic(ce)
@@ -415,6 +475,8 @@ func run(ctx *monitor.ExecCtx) {
 
 		panic(err)
 	}
+
+	return err
 }
 
 // logHandler allows to override the GoTEE default handler and avoid
@@ -434,6 +496,10 @@ func logHandler(ctx *monitor.ExecCtx) (err error) {
 		return ErrNonsecureExit
 	case ctx.R0 == syscall.SYS_EXIT:
 		return ErrTAExit
+	case !ctx.NonSecure() && ctx.R0 == client.SYS_WAIT_MAIL:
+		return ErrTAWaiting
+	case ctx.R0 == syscall.SYS_GETRANDOM:
+		err = getRandom(ctx)
 
 	// TODO: clean this
 	case ctx.R0 == 666: // syscall.SYS_EXIT_ERROR:
@@ -463,6 +529,26 @@ func logHandler(ctx *monitor.ExecCtx) (err error) {
 	return
 }
 
+// getRandom serves SYS_GETRANDOM out of entropy.Reader, in place of the raw TRNG output of the GoTEE
+// default handler.
+// Once the entropy service fails closed, so does the system call.
+func getRandom(ctx *monitor.ExecCtx) error {
+	off := int(ctx.R1 - ctx.Memory.Start)
+	buf := make([]byte, ctx.R2)
+
+	if !(off >= 0 && off < (ctx.Memory.Size-len(buf))) {
+		return errors.New("invalid read offset")
+	}
+
+	if _, err := entropy.Read(buf); err != nil {
+		return fmt.Errorf("cannot generate random bytes, %w", err)
+	}
+
+	ctx.Memory.Write(ctx.Memory.Start, off, buf)
+
+	return nil
+}
+
 func logHandlerCopy(ctx *monitor.ExecCtx) (err error) {
 	defaultHandler := monitor.SecureHandler
 
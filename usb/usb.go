@@ -310,10 +310,17 @@ func chunk(data []byte) [][]byte {
 	return chunkFunc(data, nextChunkSize)
 }
 
+// FormatResponse splits data in HID frames on the channel of s.
 func (s *Session) FormatResponse(data []byte) [][]byte {
+	return Frames(s.channelID, data)
+}
+
+// Frames splits data in HID frames for channelID, in the format expected by LedgerJS.
+// Since the same framing is used in both directions, hosts can use it to format requests.
+func Frames(channelID uint16, data []byte) [][]byte {
 	if len(data) <= defaultChunkSize {
 		hf := HIDFrame{
-			ChannelIDInner:   s.channelID,
+			ChannelIDInner:   channelID,
 			TagInner:         hidFrameTag,
 			PacketIndexInner: uint16(0),
 			// DataLength is only present in the first HID response frame, and is composed by the
@@ -341,7 +348,7 @@ func (s *Session) FormatResponse(data []byte) [][]byte {
 
 	// serialize first 57 bytes
 	hf := HIDFrame{
-		ChannelIDInner:   s.channelID,
+		ChannelIDInner:   channelID,
 		TagInner:         hidFrameTag,
 		PacketIndexInner: uint16(0),
 		// DataLength is only present in the first HID response frame, and is composed by the
@@ -358,7 +365,7 @@ func (s *Session) FormatResponse(data []byte) [][]byte {
 
 	for i, chunk := range chunks {
 		hf := HIDFrameNext{
-			ChannelIDInner:   s.channelID,
+			ChannelIDInner:   channelID,
 			TagInner:         hidFrameTag,
 			PacketIndexInner: uint16(i + 1),
 			DataInner:        [59]byte{},